@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var customerCollection *mongo.Collection = database.OpenCollection(database.Client, "customer")
+
+func GetCustomers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if phone := c.Query("phone"); phone != "" {
+			filter["phone"] = normalizePhone(phone)
+		}
+		if email := c.Query("email"); email != "" {
+			filter["email"] = strings.ToLower(email)
+		}
+		if q := c.Query("q"); q != "" {
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+			filter["$or"] = bson.A{
+				bson.M{"first_name": pattern},
+				bson.M{"last_name": pattern},
+				bson.M{"email": pattern},
+				bson.M{"phone": pattern},
+			}
+		}
+
+		result, err := customerCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allCustomers []models.Customer
+		if err = result.All(ctx, &allCustomers); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allCustomers)
+	}
+}
+
+func GetCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+
+		customerId := c.Param("customer_id")
+
+		err := customerCollection.FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, customer)
+	}
+}
+
+func CreateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+
+		if err := c.BindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(customer)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if customer.Email != nil {
+			email := strings.ToLower(*customer.Email)
+			customer.Email = &email
+		}
+		if customer.Phone != nil {
+			phone := normalizePhone(*customer.Phone)
+			customer.Phone = &phone
+		}
+		if msg := customerConflict(ctx, customer, ""); msg != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+
+		customer.Points_balance = 0
+		customer.Lifetime_points = 0
+		customer.Tier = nil
+		customer.Visit_count = 0
+		customer.Lifetime_spend = nil
+		customer.Last_visit_at = nil
+		customer.Consent_updated_at = nil
+		customer.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if customer.Marketing_consent != nil {
+			customer.Consent_updated_at = &customer.Created_at
+		}
+		customer.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		customer.ID = primitive.NewObjectID()
+		customer.Customer_id = customer.ID.Hex()
+
+		result, insertErr := customerCollection.InsertOne(ctx, customer)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+		customerId := c.Param("customer_id")
+
+		if err := c.BindJSON(&customer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if customer.First_name != nil {
+			if err := validate.Var(*customer.First_name, "min=2,max=100"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"first_name", customer.First_name})
+		}
+
+		if customer.Last_name != nil {
+			updateObj = append(updateObj, bson.E{"last_name", customer.Last_name})
+		}
+
+		if customer.Email != nil {
+			if err := validate.Var(*customer.Email, "email"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			email := strings.ToLower(*customer.Email)
+			customer.Email = &email
+			updateObj = append(updateObj, bson.E{"email", customer.Email})
+		}
+
+		if customer.Phone != nil {
+			if err := validate.Var(*customer.Phone, "min=6,max=20"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			phone := normalizePhone(*customer.Phone)
+			customer.Phone = &phone
+			updateObj = append(updateObj, bson.E{"phone", customer.Phone})
+		}
+
+		if customer.Birthday != nil {
+			if err := validate.Var(*customer.Birthday, "datetime=2006-01-02"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"birthday", customer.Birthday})
+		}
+
+		if customer.Preferences != nil {
+			updateObj = append(updateObj, bson.E{"preferences", customer.Preferences})
+		}
+
+		if customer.Allergies != nil {
+			updateObj = append(updateObj, bson.E{"allergies", customer.Allergies})
+		}
+
+		customer.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if customer.Marketing_consent != nil {
+			updateObj = append(updateObj, bson.E{"marketing_consent", customer.Marketing_consent})
+			updateObj = append(updateObj, bson.E{"consent_updated_at", customer.Updated_at})
+		}
+
+		if msg := customerConflict(ctx, customer, customerId); msg != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+
+		updateObj = append(updateObj, bson.E{"updated_at", customer.Updated_at})
+
+		result, err := customerCollection.UpdateOne(
+			ctx,
+			bson.M{"customer_id": customerId},
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetCustomerVisits lists a customer's orders, newest first, each with the
+// invoices raised for it.
+func GetCustomerVisits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+
+		matchStage := bson.D{{"$match", bson.D{{"customer_id", customerId}}}}
+		sortStage := bson.D{{"$sort", bson.D{{"created_at", -1}}}}
+		lookupStage := bson.D{{"$lookup", bson.D{{"from", "invoice"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "invoices"}}}}
+		projectStage := bson.D{{"$project", bson.D{
+			{"_id", 0},
+			{"order_id", 1},
+			{"order_date", 1},
+			{"table_id", 1},
+			{"order_type", 1},
+			{"invoices.invoice_id", 1},
+			{"invoices.invoice_number", 1},
+			{"invoices.payment_status", 1},
+			{"invoices.total", 1},
+			{"invoices.amount_paid", 1},
+			{"invoices.created_at", 1},
+		}}}
+
+		result, err := orderCollection.Aggregate(ctx, mongo.Pipeline{matchStage, sortStage, lookupStage, projectStage})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var visits []bson.M
+		if err = result.All(ctx, &visits); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, visits)
+	}
+}
+
+// normalizePhone strips formatting so the same number is always stored and
+// searched the same way.
+func normalizePhone(phone string) string {
+	var normalized strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			normalized.WriteRune(r)
+		}
+	}
+	return normalized.String()
+}
+
+// customerConflict reports when another customer already uses the email or
+// phone number, so a guest is always found under a single profile.
+func customerConflict(ctx context.Context, customer models.Customer, customerId string) string {
+	if customer.Email != nil {
+		count, _ := customerCollection.CountDocuments(ctx, bson.M{"email": customer.Email, "customer_id": bson.M{"$ne": customerId}})
+		if count > 0 {
+			return "a customer with this email already exists"
+		}
+	}
+	if customer.Phone != nil {
+		count, _ := customerCollection.CountDocuments(ctx, bson.M{"phone": customer.Phone, "customer_id": bson.M{"$ne": customerId}})
+		if count > 0 {
+			return "a customer with this phone number already exists"
+		}
+	}
+	return ""
+}
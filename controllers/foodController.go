@@ -3,9 +3,9 @@ package controllers
 import (
 	"context"
 	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
 	"log"
-	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -94,15 +94,6 @@ func GetFood() gin.HandlerFunc {
 
 	}
 }
-func round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
-}
-
-func tofixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	return float64(round(num*output)) / output
-}
-
 func CreateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -124,12 +115,27 @@ func CreateFood() gin.HandlerFunc {
 			return
 		}
 
+		if food.Station_id != nil && !stationExists(ctx, *food.Station_id) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "station not found"})
+			return
+		}
+
+		if err := helpers.PrepareModifierGroups(food.Modifier_groups); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := helpers.PrepareVariants(food.Variants); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		food.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		food.ID = primitive.NewObjectID()
 		food.Food_id = food.ID.Hex()
-		var num = tofixed(*food.Price, 2)
-		food.Price = &num
+		var price = models.NewMoney(food.Price.Amount, food.Price.Currency)
+		food.Price = &price
 
 		result, insertErr := foodCollection.InsertOne(ctx, food)
 		if insertErr != nil {
@@ -144,6 +150,7 @@ func CreateFood() gin.HandlerFunc {
 func UpdateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 		var food models.Food
 		var menu models.Menu
 
@@ -160,7 +167,44 @@ func UpdateFood() gin.HandlerFunc {
 		}
 
 		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{"name", food.Price})
+			var price = models.NewMoney(food.Price.Amount, food.Price.Currency)
+			updateObj = append(updateObj, bson.E{"price", price})
+		}
+
+		if food.Tax_category != nil {
+			updateObj = append(updateObj, bson.E{"tax_category", food.Tax_category})
+		}
+
+		if food.Modifier_groups != nil {
+			if err := validate.Var(food.Modifier_groups, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := helpers.PrepareModifierGroups(food.Modifier_groups); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"modifier_groups", food.Modifier_groups})
+		}
+
+		if food.Variants != nil {
+			if err := validate.Var(food.Variants, "dive"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err := helpers.PrepareVariants(food.Variants); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"variants", food.Variants})
+		}
+
+		if food.Station_id != nil {
+			if !stationExists(ctx, *food.Station_id) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "station not found"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"station_id", food.Station_id})
 		}
 
 		if food.Food_image != nil {
@@ -169,7 +213,6 @@ func UpdateFood() gin.HandlerFunc {
 
 		if food.Menu_id != nil {
 			err := menuCollection.FindOne(ctx, bson.M{"menuid": food.Menu_id}).Decode(&menu)
-			defer cancel()
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type reloadRequest struct {
+	Amount        *models.Money `json:"amount" validate:"required"`
+	Manager_token *string       `json:"manager_token"`
+}
+
+var giftCardCollection *mongo.Collection = database.OpenCollection(database.Client, "giftcard")
+var giftCardTransactionCollection *mongo.Collection = database.OpenCollection(database.Client, "giftcardtransaction")
+
+func GetGiftCards() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		result, err := giftCardCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allGiftCards []models.GiftCard
+		if err = result.All(ctx, &allGiftCards); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for i := range allGiftCards {
+			allGiftCards[i].Code = helpers.MaskGiftCardCode(allGiftCards[i].Code)
+		}
+		c.JSON(http.StatusOK, allGiftCards)
+	}
+}
+
+// GetGiftCard looks a card up by its code and returns it with its ledger,
+// newest entry first.
+func GetGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var giftCard models.GiftCard
+
+		code := helpers.NormalizeGiftCardCode(c.Param("code"))
+
+		err := giftCardCollection.FindOne(ctx, bson.M{"code": code}).Decode(&giftCard)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "gift card not found"})
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		result, err := giftCardTransactionCollection.Find(ctx, bson.M{"gift_card_id": giftCard.Gift_card_id}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var transactions []models.GiftCardTransaction
+		if err = result.All(ctx, &transactions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"gift_card": giftCard, "transactions": transactions})
+	}
+}
+
+// IssueGiftCard creates a card with money on it, so a manager has to
+// approve it.
+func IssueGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var giftCard models.GiftCard
+
+		if err := c.BindJSON(&giftCard); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(giftCard)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		approvedBy, msg := helpers.ManagerApproval(ctx, giftCard.Manager_token)
+		if msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		balance := models.NewMoney(giftCard.Initial_balance.Amount, giftCard.Initial_balance.Currency)
+		if balance.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "initial balance must be positive"})
+			return
+		}
+		if giftCard.Expires_at != nil && !giftCard.Expires_at.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+			return
+		}
+
+		code, err := helpers.GenerateGiftCardCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		status := "ACTIVE"
+		giftCard.Code = code
+		giftCard.Initial_balance = &balance
+		giftCard.Balance = &balance
+		giftCard.Status = &status
+		giftCard.Issued_by = c.GetString("user_id")
+		giftCard.Approved_by = approvedBy
+		giftCard.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		giftCard.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		giftCard.ID = primitive.NewObjectID()
+		giftCard.Gift_card_id = giftCard.ID.Hex()
+
+		if _, err = giftCardCollection.InsertOne(ctx, giftCard); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err = helpers.RecordGiftCardIssue(ctx, giftCard); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, giftCard)
+	}
+}
+
+// ReloadGiftCard adds value to a card. Like issuing one, it needs a
+// manager's approval.
+func ReloadGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request reloadRequest
+		var giftCard models.GiftCard
+
+		code := helpers.NormalizeGiftCardCode(c.Param("code"))
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		amount := models.NewMoney(request.Amount.Amount, request.Amount.Currency)
+		if amount.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reload amount must be positive"})
+			return
+		}
+
+		if _, msg := helpers.ManagerApproval(ctx, request.Manager_token); msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		err := giftCardCollection.FindOne(ctx, bson.M{"code": code}).Decode(&giftCard)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "gift card not found"})
+			return
+		}
+		if giftCard.Balance.Currency != amount.Currency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reload currency does not match the card"})
+			return
+		}
+
+		giftCard, err = helpers.CreditGiftCard(ctx, giftCard.Gift_card_id, amount, "RELOAD", nil, nil, c.GetString("user_id"))
+		if errors.Is(err, helpers.ErrGiftCardInactive) || errors.Is(err, helpers.ErrGiftCardExpired) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, giftCard)
+	}
+}
+
+// UpdateGiftCard lets a manager disable or re-enable a card and move its
+// expiry date. Expired cards have had their balance written off and stay
+// expired.
+func UpdateGiftCard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var giftCard models.GiftCard
+		var existing models.GiftCard
+
+		code := helpers.NormalizeGiftCardCode(c.Param("code"))
+
+		if err := c.BindJSON(&giftCard); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := giftCardCollection.FindOne(ctx, bson.M{"code": code}).Decode(&existing)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "gift card not found"})
+			return
+		}
+		if _, msg := helpers.ManagerApproval(ctx, giftCard.Manager_token); msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+		if existing.Status != nil && *existing.Status == "EXPIRED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "gift card has expired"})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if giftCard.Status != nil {
+			if *giftCard.Status != "ACTIVE" && *giftCard.Status != "DISABLED" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "status must be ACTIVE or DISABLED"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"status", giftCard.Status})
+		}
+
+		if giftCard.Expires_at != nil {
+			if !giftCard.Expires_at.After(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"expires_at", giftCard.Expires_at})
+		}
+
+		giftCard.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", giftCard.Updated_at})
+
+		result, err := giftCardCollection.UpdateOne(
+			ctx,
+			bson.M{"code": code, "status": bson.M{"$ne": "EXPIRED"}},
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -4,27 +4,61 @@ import (
 	"context"
 	"fmt"
 	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type InvoiceViewFormat struct {
-	invoice_id       string
-	payment_method   string
-	order_id         string
-	payment_status   string
-	table_number     interface{}
-	payment_due      interface{}
-	payment_due_date time.Time
-	order_details    interface{}
+	Invoice_id       string                     `json:"invoice_id"`
+	Payment_method   string                     `json:"payment_method"`
+	Order_id         string                     `json:"order_id"`
+	Payment_status   string                     `json:"payment_status"`
+	Table_number     interface{}                `json:"table_number"`
+	Payment_due      interface{}                `json:"payment_due"`
+	Payment_due_date time.Time                  `json:"payment_due_date"`
+	Order_details    interface{}                `json:"order_details"`
+	Line_items       []models.InvoiceLine       `json:"line_items"`
+	Taxes            []models.InvoiceTax        `json:"taxes"`
+	Subtotal         *models.Money              `json:"subtotal"`
+	Discount_total   *models.Money              `json:"discount_total"`
+	Service_charge   *models.Money              `json:"service_charge"`
+	Tax_total        *models.Money              `json:"tax_total"`
+	Tip              *models.Money              `json:"tip"`
+	Amount_paid      *models.Money              `json:"amount_paid"`
+	Amount_refunded  *models.Money              `json:"amount_refunded"`
+	Balance_due      *models.Money              `json:"balance_due"`
+	Adjustments      []models.InvoiceAdjustment `json:"adjustments"`
+}
+
+// invoiceRequest is what a client may set on an invoice. Amounts paid,
+// statuses, numbers and loyalty redemptions are only ever set by the server.
+type invoiceRequest struct {
+	Order_id        *string       `json:"order_id" validate:"required"`
+	Payment_method  *string       `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Location        *string       `json:"location"`
+	Customer_id     *string       `json:"customer_id"`
+	Discount_bps    *int64        `json:"discount_bps" validate:"omitempty,min=0,max=10000"`
+	Discount_amount *models.Money `json:"discount_amount"`
+	Tip             *models.Money `json:"tip"`
+	Coupon_codes    []string      `json:"coupon_codes"`
+}
+
+type invoiceUpdateRequest struct {
+	Order_id        *string       `json:"order_id"`
+	Payment_method  *string       `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Discount_bps    *int64        `json:"discount_bps" validate:"omitempty,min=0,max=10000"`
+	Discount_amount *models.Money `json:"discount_amount"`
+	Tip             *models.Money `json:"tip"`
+	Coupon_codes    []string      `json:"coupon_codes"`
 }
 
 var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
@@ -60,149 +94,446 @@ func GetInvoices() gin.HandlerFunc {
 func GetInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		var invoice models.Invoice
 
 		invoiceId := c.Param("invoice_id")
 
 		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
-
 		if err != nil {
-			defer cancel()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
 		var invoiceView InvoiceViewFormat
 
-		allOrderItems, err := ItemsByOrder(*invoice.Order_id)
-		if err != nil {
-			log.Fatal(err)
+		if invoice.Order_id != nil {
+			allOrderItems, err := ItemsByOrder(*invoice.Order_id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			invoiceView.Order_id = *invoice.Order_id
+			if len(allOrderItems) > 0 {
+				invoiceView.Table_number = allOrderItems[0]["table_number"]
+				invoiceView.Payment_due = allOrderItems[0]["payment_due"]
+				invoiceView.Order_details = allOrderItems[0]["order_items"]
+			}
 		}
-		invoiceView.order_id = *invoice.Order_id
-		invoiceView.payment_due_date = invoice.Payment_due_date
+		invoiceView.Payment_due_date = invoice.Payment_due_date
 
-		invoiceView.payment_method = "null"
+		invoiceView.Payment_method = "null"
 		if invoice.Payment_method != nil {
-			invoiceView.payment_method = *invoice.Payment_method
+			invoiceView.Payment_method = *invoice.Payment_method
+		}
+		invoiceView.Invoice_id = invoice.Invoice_id
+		if invoice.Payment_status != nil {
+			invoiceView.Payment_status = *invoice.Payment_status
 		}
-		invoiceView.invoice_id = invoice.Invoice_id
-		invoiceView.payment_status = *invoice.Payment_status
-		invoiceView.table_number = allOrderItems[0]["table_number"]
-		invoiceView.payment_due = allOrderItems[0]["payment_due"]
-		invoiceView.order_details = allOrderItems[0]["order_details"]
+		if invoice.Total != nil {
+			invoiceView.Payment_due = invoice.Total
+		}
+		invoiceView.Line_items = invoice.Line_items
+		invoiceView.Taxes = invoice.Taxes
+		invoiceView.Subtotal = invoice.Subtotal
+		invoiceView.Discount_total = invoice.Discount_total
+		invoiceView.Service_charge = invoice.Service_charge
+		invoiceView.Tax_total = invoice.Tax_total
+		invoiceView.Tip = invoice.Tip
+		invoiceView.Amount_paid = invoice.Amount_paid
+		invoiceView.Amount_refunded = invoice.Amount_refunded
+		invoiceView.Balance_due = invoice.Balance_due
+		invoiceView.Adjustments = invoice.Adjustments
 
-		defer cancel()
 		c.JSON(http.StatusOK, invoiceView)
 	}
 }
 
+func GetInvoiceReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		receipt, err := loadReceipt(ctx, invoiceId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+
+		switch c.DefaultQuery("format", "html") {
+		case "pdf":
+			c.Header("Content-Disposition", "inline; filename=invoice-"+invoiceId+".pdf")
+			c.Data(http.StatusOK, "application/pdf", helpers.ReceiptPDF(receipt))
+		case "html":
+			page, err := helpers.ReceiptHTML(receipt)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		case "txt":
+			c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(helpers.ReceiptText(receipt, helpers.ReceiptWidth)))
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be pdf, html or txt"})
+		}
+	}
+}
+
+func loadReceipt(ctx context.Context, invoiceId string) (helpers.Receipt, error) {
+	var receipt helpers.Receipt
+
+	err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&receipt.Invoice)
+	if err != nil {
+		return receipt, err
+	}
+
+	var setting models.Setting
+	if err = settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting); err == nil && setting.Branding != nil {
+		receipt.Branding = *setting.Branding
+	}
+
+	if receipt.Invoice.Order_id != nil {
+		var order models.Order
+		var table models.Table
+		if err = orderCollection.FindOne(ctx, bson.M{"order_id": receipt.Invoice.Order_id}).Decode(&order); err == nil && order.Table_id != nil {
+			if err = tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil {
+				receipt.Table_number = table.Table_number
+			}
+		}
+	}
+
+	cursor, err := paymentCollection.Find(ctx, bson.M{"invoice_id": invoiceId})
+	if err != nil {
+		return receipt, err
+	}
+	err = cursor.All(ctx, &receipt.Payments)
+	return receipt, err
+}
+
 func CreateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var invoice models.Invoice
+		var request invoiceRequest
 		var order models.Order
 
-		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		validateErr := validate.Struct(invoice)
+		validateErr := validate.Struct(request)
 		if validateErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": validateErr.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": validateErr.Error()})
+			return
 		}
 
-		if invoice.Order_id != nil {
-			err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
-			if err != nil {
-				msg := fmt.Sprintln("not able to fetch order id")
-				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		invoice := models.Invoice{
+			Order_id:        request.Order_id,
+			Payment_method:  request.Payment_method,
+			Location:        request.Location,
+			Customer_id:     request.Customer_id,
+			Discount_bps:    request.Discount_bps,
+			Discount_amount: request.Discount_amount,
+			Tip:             request.Tip,
+			Coupon_codes:    normalizeCoupons(request.Coupon_codes),
+		}
+
+		err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+		if err != nil {
+			msg := fmt.Sprintln("not able to fetch order id")
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+		if status := helpers.OrderStatus(order); status == "CANCELLED" || status == "VOID" || status == "MERGED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + strings.ToLower(status)})
+			return
+		}
+
+		if invoice.Customer_id != nil {
+			count, err := customerCollection.CountDocuments(ctx, bson.M{"customer_id": invoice.Customer_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "customer not found"})
+				return
 			}
 		}
 
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		if invoice.Location == nil {
+			if location := defaultLocation(ctx); location != "" {
+				invoice.Location = &location
+			}
+		}
+		if helpers.BusinessDayClosed(ctx, invoice.Location, time.Now()) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the business day is already closed"})
+			return
+		}
+
+		if err = helpers.CheckCoupons(ctx, invoice.Coupon_codes, *invoice.Order_id, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err = helpers.BuildInvoice(ctx, &invoice); err == helpers.ErrInvoiceCurrency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		redeemed, err := helpers.RedeemCoupons(ctx, invoice)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
 		}
 
+		status := "PENDING"
+		invoice.Payment_status = &status
+
 		invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.ID = primitive.NewObjectID()
 		invoice.Invoice_id = invoice.ID.Hex()
 
-		result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
+		result, insertErr := helpers.InsertInvoice(ctx, &invoice)
 		if insertErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
+			if err = helpers.ReleaseRedeemedCoupons(ctx, *invoice.Order_id, redeemed); err != nil {
+				log.Println("could not release coupons of order", *invoice.Order_id, err)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, result)
+		c.JSON(http.StatusOK, gin.H{"InsertedID": result.InsertedID, "invoice_id": invoice.Invoice_id, "invoice_number": invoice.Invoice_number})
 
 	}
 }
 
+// UpdateInvoice changes the payment method or the adjustments of an
+// invoice and reprices it. The payment status only ever follows from
+// payments, refunds and voids.
 func UpdateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
-		var invoice models.Invoice
+		var request invoiceUpdateRequest
 		var order models.Order
+		var redeemed []string
+		var orderId string
 
 		invoiceId := c.Param("invoice_id")
 
-		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
-		var UpdateInv primitive.D
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
 
-		if invoice.Payment_method != nil {
-			UpdateInv = append(UpdateInv, bson.E{"payment_method", invoice.Payment_method})
+		locked, err := invoiceCollection.CountDocuments(ctx, bson.M{"invoice_id": invoiceId, "z_report_id": bson.M{"$ne": nil}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if locked > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+			return
 		}
 
-		if invoice.Payment_status != nil {
-			UpdateInv = append(UpdateInv, bson.E{"payment_status", invoice.Payment_status})
+		var UpdateInv primitive.D
+
+		if request.Payment_method != nil {
+			UpdateInv = append(UpdateInv, bson.E{"payment_method", request.Payment_method})
 		}
 
-		if invoice.Order_id != nil {
-			err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+		if request.Order_id != nil {
+			err := orderCollection.FindOne(ctx, bson.M{"order_id": request.Order_id}).Decode(&order)
 			if err != nil {
 				msg := fmt.Sprintln("not able to fetch order id")
 				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
 			}
-			UpdateInv = append(UpdateInv, bson.E{"order_id", invoice.Order_id})
+			UpdateInv = append(UpdateInv, bson.E{"order_id", request.Order_id})
 		}
 
-		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		UpdateInv = append(UpdateInv, bson.E{"update_at", invoice.Updated_at})
+		if request.Order_id != nil || request.Discount_bps != nil || request.Discount_amount != nil || request.Tip != nil || request.Coupon_codes != nil {
+			var existing models.Invoice
+			err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&existing)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+				return
+			}
+			mergeInvoiceAdjustments(&existing, request)
 
-		Upsert := true
+			if existing.Order_id == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invoice has no order to price"})
+				return
+			}
+			if request.Coupon_codes != nil {
+				if err = helpers.CheckCoupons(ctx, existing.Coupon_codes, *existing.Order_id, time.Now()); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
 
-		filter := bson.M{"invoice_id": invoiceId}
+			if err = helpers.BuildInvoice(ctx, &existing); err == helpers.ErrInvoiceCurrency {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 
-		opt := options.UpdateOptions{
-			Upsert: &Upsert,
+			redeemed, err = helpers.RedeemCoupons(ctx, existing)
+			if err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
+			orderId = *existing.Order_id
+			UpdateInv = append(UpdateInv, invoiceBreakdown(existing)...)
+			if existing.Amount_paid != nil {
+				UpdateInv = append(UpdateInv, bson.E{"payment_status", existing.Payment_status})
+			}
 		}
 
-		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
-		}
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		UpdateInv = append(UpdateInv, bson.E{"updated_at", updated_at})
+
+		filter := bson.M{"invoice_id": invoiceId}
 
 		result, err := invoiceCollection.UpdateOne(
 			ctx,
 			filter,
 			bson.D{{"$set", UpdateInv}},
-			&opt,
 		)
+		if err != nil || result.MatchedCount == 0 {
+			if releaseErr := helpers.ReleaseRedeemedCoupons(ctx, orderId, redeemed); releaseErr != nil {
+				log.Println("could not release coupons of order", orderId, releaseErr)
+			}
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+
+	}
+}
+
+func RecalculateInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
+		var invoice models.Invoice
+		invoiceId := c.Param("invoice_id")
+
+		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
 		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+		if helpers.InvoiceLocked(invoice) {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+			return
+		}
+
+		if err = helpers.BuildInvoice(ctx, &invoice); err == helpers.ErrInvoiceCurrency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := append(invoiceBreakdown(invoice), bson.E{"payment_status", invoice.Payment_status}, bson.E{"updated_at", invoice.Updated_at})
+
+		_, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoiceId}, bson.D{{"$set", updateObj}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoice)
+	}
+}
+
+func mergeInvoiceAdjustments(existing *models.Invoice, changes invoiceUpdateRequest) {
+	if changes.Order_id != nil {
+		existing.Order_id = changes.Order_id
+	}
+	if changes.Discount_bps != nil {
+		existing.Discount_bps = changes.Discount_bps
+	}
+	if changes.Discount_amount != nil {
+		existing.Discount_amount = changes.Discount_amount
+	}
+	if changes.Tip != nil {
+		existing.Tip = changes.Tip
+	}
+	if changes.Coupon_codes != nil {
+		existing.Coupon_codes = normalizeCoupons(changes.Coupon_codes)
+	}
+}
+
+func normalizeCoupons(codes []string) []string {
+	normalized := []string{}
+	for _, code := range codes {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code != "" {
+			normalized = append(normalized, code)
 		}
+	}
+	return normalized
+}
 
+func invoiceBreakdown(invoice models.Invoice) primitive.D {
+	return primitive.D{
+		{"discount_bps", invoice.Discount_bps},
+		{"discount_amount", invoice.Discount_amount},
+		{"tip", invoice.Tip},
+		{"customer_id", invoice.Customer_id},
+		{"coupon_codes", invoice.Coupon_codes},
+		{"promotions", invoice.Promotions},
+		{"line_items", invoice.Line_items},
+		{"taxes", invoice.Taxes},
+		{"subtotal", invoice.Subtotal},
+		{"discount_total", invoice.Discount_total},
+		{"service_charge", invoice.Service_charge},
+		{"tax_total", invoice.Tax_total},
+		{"total", invoice.Total},
+		{"balance_due", invoice.Balance_due},
+	}
+}
+
+func GetInvoiceByNumber() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
-		c.JSON(http.StatusOK, result)
 
+		var invoice models.Invoice
+
+		invoiceNumber := c.Param("invoice_number")
+
+		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_number": invoiceNumber}).Decode(&invoice)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+		c.JSON(http.StatusOK, invoice)
 	}
 }
@@ -0,0 +1,425 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type invoiceSplitRequest struct {
+	Order_id       *string    `json:"order_id" validate:"required"`
+	Mode           *string    `json:"mode" validate:"required,eq=ITEMS|eq=SEATS|eq=EVEN"`
+	Groups         [][]string `json:"groups"`
+	Payers         int        `json:"payers" validate:"omitempty,min=2,max=50"`
+	Payment_method *string    `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+}
+
+type invoiceMergeRequest struct {
+	Invoice_ids []string `json:"invoice_ids" validate:"required,min=2"`
+}
+
+func SplitInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request invoiceSplitRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		var order models.Order
+		err := orderCollection.FindOne(ctx, bson.M{"order_id": request.Order_id}).Decode(&order)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "not able to fetch order id"})
+			return
+		}
+
+		paid, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": request.Order_id, "payment_status": bson.M{"$in": bson.A{"PARTIALLY_PAID", "PAID", "REFUNDED"}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if paid > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "order already has payments against it"})
+			return
+		}
+
+		var orderItems []models.OrderItem
+		cursor, err := orderitemCollection.Find(ctx, bson.M{"order_id": request.Order_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err = cursor.All(ctx, &orderItems); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		locked, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": request.Order_id, "z_report_id": bson.M{"$ne": nil}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if locked > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "order has invoices in a closed business day"})
+			return
+		}
+
+		var template models.Invoice
+		err = invoiceCollection.FindOne(ctx, bson.M{"order_id": request.Order_id, "split_group_id": nil, "payment_status": bson.M{"$ne": "VOID"}}).Decode(&template)
+		if err != nil {
+			template = models.Invoice{}
+		}
+
+		splitGroupId := primitive.NewObjectID().Hex()
+		var invoices []models.Invoice
+		spread := false
+
+		switch *request.Mode {
+		case "ITEMS":
+			groups, msg := splitByItems(orderItems, request.Groups)
+			if msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+			for _, group := range groups {
+				invoices = append(invoices, models.Invoice{Order_item_ids: group, Discount_bps: template.Discount_bps, Coupon_codes: template.Coupon_codes})
+			}
+			spread = true
+		case "SEATS":
+			for _, group := range splitBySeat(orderItems) {
+				invoices = append(invoices, models.Invoice{Order_item_ids: group, Discount_bps: template.Discount_bps, Coupon_codes: template.Coupon_codes})
+			}
+			spread = true
+		case "EVEN":
+			if request.Payers < 2 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "payers must be at least 2"})
+				return
+			}
+			var tips []models.Money
+			if template.Tip != nil {
+				tips = template.Tip.Allocate(request.Payers)
+			}
+			for i := 0; i < request.Payers; i++ {
+				invoice := models.Invoice{
+					Split_count:     request.Payers,
+					Split_shares:    []int{i},
+					Discount_bps:    template.Discount_bps,
+					Coupon_codes:    template.Coupon_codes,
+					Discount_amount: template.Discount_amount,
+				}
+				if tips != nil {
+					invoice.Tip = &tips[i]
+				}
+				invoices = append(invoices, invoice)
+			}
+		}
+
+		if len(invoices) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a split needs at least two invoices"})
+			return
+		}
+
+		for i := range invoices {
+			invoices[i].Order_id = request.Order_id
+			invoices[i].Split_group_id = &splitGroupId
+			invoices[i].Payment_method = request.Payment_method
+			if err = newInvoice(ctx, &invoices[i]); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if spread {
+			if err = spreadAdjustments(ctx, invoices, template); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		for i := range invoices {
+			if err = insertInvoice(ctx, &invoices[i]); err != nil {
+				if voidErr := supersedeInvoices(ctx, bson.M{"split_group_id": splitGroupId}, "SPLIT_FAILED", c.GetString("user_id")); voidErr != nil {
+					err = voidErr
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		err = supersedeInvoices(ctx, bson.M{"order_id": request.Order_id, "payment_status": "PENDING", "split_group_id": bson.M{"$ne": splitGroupId}}, "SPLIT", c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+func MergeInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request invoiceMergeRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		var invoices []models.Invoice
+		cursor, err := invoiceCollection.Find(ctx, bson.M{"invoice_id": bson.M{"$in": request.Invoice_ids}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err = cursor.All(ctx, &invoices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(invoices) != len(request.Invoice_ids) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "one or more invoices were not found"})
+			return
+		}
+
+		first := invoices[0]
+		if first.Split_group_id == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only invoices from the same split can be merged"})
+			return
+		}
+		for _, invoice := range invoices {
+			if invoice.Split_group_id == nil || *invoice.Split_group_id != *first.Split_group_id {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "only invoices from the same split can be merged"})
+				return
+			}
+			if helpers.InvoiceLocked(invoice) {
+				c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+				return
+			}
+			if invoice.Payment_status == nil || *invoice.Payment_status != "PENDING" {
+				c.JSON(http.StatusConflict, gin.H{"error": "only unpaid invoices can be merged"})
+				return
+			}
+		}
+
+		groupSize, err := invoiceCollection.CountDocuments(ctx, bson.M{"split_group_id": first.Split_group_id, "payment_status": bson.M{"$ne": "VOID"}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		merged := models.Invoice{
+			Order_id:        first.Order_id,
+			Payment_method:  first.Payment_method,
+			Discount_bps:    first.Discount_bps,
+			Coupon_codes:    first.Coupon_codes,
+			Discount_amount: first.Discount_amount,
+		}
+		tip := models.Money{}
+		for _, invoice := range invoices {
+			merged.Order_item_ids = append(merged.Order_item_ids, invoice.Order_item_ids...)
+			merged.Split_shares = append(merged.Split_shares, invoice.Split_shares...)
+			if invoice.Tip != nil {
+				tip = tip.Add(*invoice.Tip)
+			}
+		}
+		merged.Tip = &tip
+		sort.Ints(merged.Split_shares)
+
+		if int64(len(invoices)) == groupSize {
+			merged.Order_item_ids = nil
+			merged.Split_shares = nil
+		} else {
+			merged.Split_group_id = first.Split_group_id
+			merged.Split_count = first.Split_count
+		}
+
+		if err = newInvoice(ctx, &merged); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err = insertInvoice(ctx, &merged); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = supersedeInvoices(ctx, bson.M{"invoice_id": bson.M{"$in": request.Invoice_ids}}, "MERGED", c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, merged)
+	}
+}
+
+func newInvoice(ctx context.Context, invoice *models.Invoice) error {
+	status := "PENDING"
+	invoice.Payment_status = &status
+	invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.ID = primitive.NewObjectID()
+	invoice.Invoice_id = invoice.ID.Hex()
+	return helpers.BuildInvoice(ctx, invoice)
+}
+
+// spreadAdjustments shares the fixed discount and tip of the invoice being
+// split across the new item or seat invoices in proportion to their
+// subtotals, so together they still take off and add what the bill did.
+func spreadAdjustments(ctx context.Context, invoices []models.Invoice, template models.Invoice) error {
+	if template.Discount_amount == nil && template.Tip == nil {
+		return nil
+	}
+
+	weights := make([]int64, len(invoices))
+	for i, invoice := range invoices {
+		if invoice.Subtotal != nil {
+			weights[i] = invoice.Subtotal.Amount
+		}
+	}
+
+	var discounts, tips []models.Money
+	if template.Discount_amount != nil {
+		discounts = template.Discount_amount.AllocateBy(weights)
+	}
+	if template.Tip != nil {
+		tips = template.Tip.AllocateBy(weights)
+	}
+
+	for i := range invoices {
+		if discounts != nil {
+			invoices[i].Discount_amount = &discounts[i]
+		}
+		if tips != nil {
+			invoices[i].Tip = &tips[i]
+		}
+		if err := helpers.BuildInvoice(ctx, &invoices[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertInvoice gives a new invoice its fiscal number and stores it.
+func insertInvoice(ctx context.Context, invoice *models.Invoice) error {
+	_, err := helpers.InsertInvoice(ctx, invoice)
+	return err
+}
+
+// supersedeInvoices voids the invoices replaced by a split or merge rather
+// than deleting them, so their fiscal numbers stay accounted for. Loyalty
+// points spent on them go back to the customer.
+func supersedeInvoices(ctx context.Context, filter bson.M, reason string, userId string) error {
+	var superseded []models.Invoice
+	cursor, err := invoiceCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &superseded); err != nil {
+		return err
+	}
+
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = invoiceCollection.UpdateMany(ctx, filter, bson.D{{"$set", bson.D{
+		{"payment_status", "VOID"},
+		{"void_reason", reason},
+		{"voided_by", userId},
+		{"balance_due", nil},
+		{"updated_at", updatedAt},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	for _, invoice := range superseded {
+		if err = helpers.ReturnLoyaltyPoints(ctx, invoice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitByItems(orderItems []models.OrderItem, groups [][]string) ([][]string, string) {
+	belongs := map[string]bool{}
+	for _, item := range orderItems {
+		belongs[item.Order_item_id] = true
+	}
+
+	used := map[string]bool{}
+	result := [][]string{}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		for _, id := range group {
+			if !belongs[id] {
+				return nil, "order item " + id + " does not belong to the order"
+			}
+			if used[id] {
+				return nil, "order item " + id + " is in more than one group"
+			}
+			used[id] = true
+		}
+		result = append(result, group)
+	}
+
+	leftover := []string{}
+	for _, item := range orderItems {
+		if !used[item.Order_item_id] {
+			leftover = append(leftover, item.Order_item_id)
+		}
+	}
+	if len(leftover) > 0 {
+		result = append(result, leftover)
+	}
+	return result, ""
+}
+
+func splitBySeat(orderItems []models.OrderItem) [][]string {
+	bySeat := map[int][]string{}
+	seats := []int{}
+	shared := []string{}
+	for _, item := range orderItems {
+		if item.Seat_number == nil {
+			shared = append(shared, item.Order_item_id)
+			continue
+		}
+		if _, ok := bySeat[*item.Seat_number]; !ok {
+			seats = append(seats, *item.Seat_number)
+		}
+		bySeat[*item.Seat_number] = append(bySeat[*item.Seat_number], item.Order_item_id)
+	}
+	sort.Ints(seats)
+
+	result := [][]string{}
+	for _, seat := range seats {
+		result = append(result, bySeat[seat])
+	}
+	if len(shared) > 0 {
+		result = append(result, shared)
+	}
+	return result
+}
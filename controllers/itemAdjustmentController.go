@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type itemAdjustmentRequest struct {
+	Reason_code   *string `json:"reason_code" validate:"required"`
+	Comment       *string `json:"comment" validate:"omitempty,max=500"`
+	Manager_token *string `json:"manager_token"`
+	Manager_id    *string `json:"manager_id"`
+	Manager_pin   *string `json:"manager_pin"`
+}
+
+func GetReasonCodes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		reasons := []models.ReasonCode{}
+		for _, reason := range helpers.ReasonCodes(ctx) {
+			if kind := c.Query("kind"); kind == "" || strings.EqualFold(kind, reason.Kind) {
+				reasons = append(reasons, reason)
+			}
+		}
+		c.JSON(http.StatusOK, reasons)
+	}
+}
+
+// VoidOrderItem takes an item off the order before the kitchen starts on it.
+func VoidOrderItem() gin.HandlerFunc {
+	return adjustOrderItem("VOID")
+}
+
+// CompOrderItem gives away an item the kitchen has already started on.
+func CompOrderItem() gin.HandlerFunc {
+	return adjustOrderItem("COMP")
+}
+
+// adjustOrderItem voids or comps an item with a reason code and a manager's
+// approval, then reprices the order's open invoices without it. Orders that
+// have taken payment have to be refunded instead.
+func adjustOrderItem(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request itemAdjustmentRequest
+		var orderItem models.OrderItem
+		var order models.Order
+
+		orderItemId := c.Param("orderItem_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			return
+		}
+
+		err = orderCollection.FindOne(ctx, bson.M{"order_id": orderItem.Order_id}).Decode(&order)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + strings.ToLower(status)})
+			return
+		}
+
+		if orderItem.Adjustment != nil || helpers.OrderItemStatus(orderItem) == "VOIDED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "order item has already been voided or comped"})
+			return
+		}
+		if kind == "VOID" && !helpers.CanVoidOrderItem(orderItem) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the kitchen has already started on this item, comp it instead"})
+			return
+		}
+		if kind == "COMP" && !helpers.CanCompOrderItem(orderItem) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the kitchen has not started on this item, void it instead"})
+			return
+		}
+
+		reason, ok := helpers.FindReasonCode(ctx, kind, strings.ToUpper(strings.TrimSpace(*request.Reason_code)))
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown " + strings.ToLower(kind) + " reason code"})
+			return
+		}
+
+		if msg := orderInvoicesOpen(ctx, order.Order_id); msg != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+
+		approvedBy, msg := helpers.ManagerOverride(ctx, request.Manager_token, request.Manager_id, request.Manager_pin)
+		if msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		adjustment := models.ItemAdjustment{
+			Reason_code:  reason.Code,
+			Reason_label: reason.Label,
+			Amount:       helpers.OrderItemAmount(ctx, orderItem),
+			Requested_by: c.GetString("user_id"),
+			Approved_by:  approvedBy,
+		}
+		if request.Comment != nil {
+			adjustment.Comment = *request.Comment
+		}
+		adjustment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if kind == "VOID" {
+			err = helpers.VoidOrderItem(ctx, orderItemId, adjustment)
+		} else {
+			err = helpers.CompOrderItem(ctx, orderItemId, adjustment)
+		}
+		if err == helpers.ErrOrderItemChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err = rebuildOrderInvoices(ctx, order.Order_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderStatus, err := helpers.RollUpOrderStatus(ctx, order.Order_id, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"order_item": orderItem, "order_status": orderStatus})
+	}
+}
+
+// orderInvoicesOpen explains why the bill of an order can no longer change
+// under its invoices, or returns an empty string when it still can.
+func orderInvoicesOpen(ctx context.Context, orderId string) string {
+	cursor, err := invoiceCollection.Find(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$ne": "VOID"}})
+	if err != nil {
+		return err.Error()
+	}
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err.Error()
+	}
+	for _, invoice := range invoices {
+		if helpers.InvoiceLocked(invoice) {
+			return "order is invoiced on a closed business day"
+		}
+		if invoice.Payment_status != nil && *invoice.Payment_status != "PENDING" {
+			return "order has already taken payment, refund it instead"
+		}
+	}
+	return ""
+}
+
+// rebuildOrderInvoices recalculates the pending invoices of an order after
+// its items changed.
+func rebuildOrderInvoices(ctx context.Context, orderId string) error {
+	cursor, err := invoiceCollection.Find(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$in": bson.A{"PENDING", nil}}})
+	if err != nil {
+		return err
+	}
+	var invoices []models.Invoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err
+	}
+	for _, invoice := range invoices {
+		if err = helpers.BuildInvoice(ctx, &invoice); err != nil {
+			return err
+		}
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := append(invoiceBreakdown(invoice), bson.E{"payment_status", invoice.Payment_status}, bson.E{"updated_at", invoice.Updated_at})
+		_, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id}, bson.D{{"$set", updateObj}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
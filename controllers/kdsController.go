@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/websocket"
+)
+
+const kitchenHeartbeat = 15 * time.Second
+
+type kitchenBumpRequest struct {
+	Station *string `json:"station"`
+}
+
+// lastKitchenEventId reads where a display wants to resume from: the
+// Last-Event-ID header EventSource sends on reconnect, or last_event_id in
+// the query for clients that cannot set headers.
+func lastKitchenEventId(c *gin.Context) (int64, bool) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return id, err == nil
+}
+
+// followKitchen replays the events a display missed and then forwards live
+// ones to send until the display goes away or send fails. It subscribes
+// before replaying so nothing published in between is lost, and skips live
+// events the replay already covered.
+func followKitchen(ctx context.Context, station string, lastEventId int64, resume bool, send func(models.KitchenEvent) error, heartbeat func() error) error {
+	events, unsubscribe := helpers.SubscribeKitchen(station)
+	defer unsubscribe()
+
+	if resume {
+		missed, err := helpers.KitchenEventsSince(ctx, station, lastEventId)
+		if err != nil {
+			return err
+		}
+		for _, event := range missed {
+			if err = send(event); err != nil {
+				return err
+			}
+			lastEventId = event.Event_id
+		}
+	}
+
+	ticker := time.NewTicker(kitchenHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return fmt.Errorf("display fell behind, reconnect from event %d", lastEventId)
+			}
+			if event.Event_id <= lastEventId {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+			lastEventId = event.Event_id
+		case <-ticker.C:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// KitchenEventStream pushes order and order item changes to a kitchen
+// display as Server-Sent Events, optionally only for one station.
+func KitchenEventStream() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		station := c.Query("station")
+		lastEventId, resume := lastKitchenEventId(c)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		fmt.Fprint(c.Writer, "retry: 3000\n\n")
+		c.Writer.Flush()
+
+		send := func(event models.KitchenEvent) error {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.Event_id, event.Type, data); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		}
+		heartbeat := func() error {
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		}
+
+		followKitchen(c.Request.Context(), station, lastEventId, resume, send, heartbeat)
+	}
+}
+
+// KitchenEventSocket pushes the same events as KitchenEventStream over a
+// WebSocket, one JSON event per message.
+func KitchenEventSocket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		station := c.Query("station")
+		lastEventId, resume := lastKitchenEventId(c)
+
+		// Displays authenticate with their token, sent as a header or as
+		// the token query parameter, rather than a browser origin, so any
+		// origin is accepted.
+		server := websocket.Server{Handshake: func(*websocket.Config, *http.Request) error { return nil }}
+		server.Handler = func(ws *websocket.Conn) {
+			ctx, cancel := context.WithCancel(c.Request.Context())
+			defer cancel()
+
+			// Displays only listen; reading is how a closed socket is noticed.
+			go func() {
+				var discard string
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+				cancel()
+			}()
+
+			send := func(event models.KitchenEvent) error {
+				return websocket.JSON.Send(ws, event)
+			}
+			heartbeat := func() error {
+				return websocket.JSON.Send(ws, gin.H{"type": "ping"})
+			}
+			followKitchen(ctx, station, lastEventId, resume, send, heartbeat)
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// BumpKitchenOrder clears an order from a station's screen by marking the
+// station's unfinished items ready.
+func BumpKitchenOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		moveKitchenOrder(c, "order.bumped", []string{"QUEUED", "FIRING"}, "READY")
+	}
+}
+
+// RecallKitchenOrder brings a bumped order back to a station's screen by
+// putting its ready items back on the fire.
+func RecallKitchenOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		moveKitchenOrder(c, "order.recalled", []string{"READY"}, "FIRING")
+	}
+}
+
+// moveKitchenOrder walks the station's items of an order that are in one
+// of from, step by step, to target so every timestamp along the way is
+// recorded, then rolls the order up and announces the bump or recall.
+func moveKitchenOrder(c *gin.Context, eventType string, from []string, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var request kitchenBumpRequest
+	var order models.Order
+
+	orderId := c.Param("order_id")
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	station := ""
+	if request.Station != nil {
+		station = *request.Station
+	}
+
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+	if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+		c.JSON(http.StatusConflict, gin.H{"error": "order is " + status})
+		return
+	}
+
+	statuses := bson.A{}
+	for _, status := range from {
+		statuses = append(statuses, status)
+	}
+	if from[0] == helpers.ItemFlow[0] {
+		statuses = append(statuses, nil)
+	}
+	cursor, err := orderitemCollection.Find(ctx, bson.M{"order_id": orderId, "status": bson.M{"$in": statuses}, "hold": bson.M{"$ne": true}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var items []models.OrderItem
+	if err = cursor.All(ctx, &items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	moved := []string{}
+	for _, item := range items {
+		if station != "" && !stationMatches(ctx, item, station) {
+			continue
+		}
+		current := helpers.OrderItemStatus(item)
+		for current != target {
+			next, ok := helpers.NextOrderItemStatus(current)
+			if target == "FIRING" {
+				next, ok = helpers.PreviousOrderItemStatus(current)
+			}
+			if !ok {
+				break
+			}
+			if _, err = helpers.TransitionOrderItem(ctx, item.Order_item_id, current, next); err != nil {
+				break
+			}
+			current = next
+		}
+		if current == target {
+			moved = append(moved, item.Order_item_id)
+		}
+	}
+	if len(moved) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "no items to move on this station"})
+		return
+	}
+
+	orderStatus, err := helpers.RollUpOrderStatus(ctx, orderId, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if target == "FIRING" && orderStatus == "READY" {
+		if _, err = helpers.TransitionOrder(ctx, orderId, "READY", "IN_PROGRESS", c.GetString("user_id"), "recalled to the kitchen"); err == nil {
+			orderStatus = "IN_PROGRESS"
+		}
+	}
+
+	event := models.KitchenEvent{Type: eventType, Order_id: orderId, Status: orderStatus}
+	if station != "" {
+		event.Station = &station
+	}
+	helpers.PublishKitchenEvent(ctx, event)
+
+	c.JSON(http.StatusOK, gin.H{"order_item_ids": moved, "order_status": orderStatus})
+}
+
+// stationMatches reports whether an item is prepared at station, routing
+// items created before stations existed on the fly.
+func stationMatches(ctx context.Context, item models.OrderItem, station string) bool {
+	itemStation := item.Station_id
+	if itemStation == nil && item.Food_id != nil {
+		itemStation = helpers.StationFor(ctx, *item.Food_id)
+	}
+	return itemStation != nil && *itemStation == station
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type redeemRequest struct {
+	Points        *int64  `json:"points" validate:"omitempty,min=1"`
+	Order_item_id *string `json:"order_item_id"`
+}
+
+var loyaltyTransactionCollection *mongo.Collection = database.OpenCollection(database.Client, "loyaltytransaction")
+
+// GetCustomerLoyalty returns a guest's points balance and tier together with
+// their ledger, newest entry first.
+func GetCustomerLoyalty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var customer models.Customer
+
+		customerId := c.Param("customer_id")
+
+		err := customerCollection.FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "customer not found"})
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{"created_at", -1}})
+		result, err := loyaltyTransactionCollection.Find(ctx, bson.M{"customer_id": customerId}, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var transactions []models.LoyaltyTransaction
+		if err = result.All(ctx, &transactions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"points_balance":  customer.Points_balance,
+			"lifetime_points": customer.Lifetime_points,
+			"tier":            customer.Tier,
+			"transactions":    transactions,
+		})
+	}
+}
+
+// RedeemLoyalty spends a guest's points on an unpaid invoice, either as a
+// discount worth the configured point value or on a reward item, which
+// then becomes free.
+func RedeemLoyalty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request redeemRequest
+		var invoice models.Invoice
+
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if (request.Points == nil) == (request.Order_item_id == nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "redeem either points or an order item"})
+			return
+		}
+
+		program := helpers.LoyaltyProgram(ctx)
+		if program == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "no loyalty program is set up"})
+			return
+		}
+
+		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+		if invoice.Customer_id == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invoice has no customer"})
+			return
+		}
+		if invoice.Payment_status == nil || *invoice.Payment_status != "PENDING" || invoice.Amount_paid != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "points can only be redeemed before the invoice is paid"})
+			return
+		}
+		if helpers.InvoiceLocked(invoice) {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+			return
+		}
+
+		var points int64
+		if request.Points != nil {
+			if program.Point_value == nil || program.Point_value.Amount <= 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "points cannot be redeemed as a discount"})
+				return
+			}
+			points = *request.Points
+			discount := program.Point_value.Mul(points)
+			if invoice.Points_discount != nil {
+				discount = discount.Add(*invoice.Points_discount)
+			}
+			net := models.NewMoney(0, discount.Currency)
+			if invoice.Subtotal != nil {
+				net = *invoice.Subtotal
+			}
+			if invoice.Discount_total != nil {
+				net = net.Sub(*invoice.Discount_total)
+			}
+			if invoice.Points_discount != nil {
+				net = net.Add(*invoice.Points_discount)
+			}
+			if discount.Amount > net.Amount {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the discount would exceed the invoice"})
+				return
+			}
+			invoice.Points_discount = &discount
+		} else {
+			reward, msg := loyaltyReward(*program, invoice, *request.Order_item_id)
+			if msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+			points = reward.Points
+			invoice.Reward_item_ids = append(invoice.Reward_item_ids, *request.Order_item_id)
+		}
+
+		err = helpers.SpendLoyaltyPoints(ctx, *invoice.Customer_id, points, &invoice.Invoice_id, "")
+		if errors.Is(err, helpers.ErrNotEnoughPoints) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		previousRedeemed := invoice.Points_redeemed
+		invoice.Points_redeemed += points
+		if err = saveRedemption(ctx, &invoice, previousRedeemed); err != nil {
+			if returnErr := helpers.CreditLoyaltyPoints(ctx, *invoice.Customer_id, points, &invoice.Invoice_id, "RETURN"); returnErr != nil {
+				log.Println("could not return loyalty points", invoice.Invoice_id, returnErr)
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoice)
+	}
+}
+
+func loyaltyReward(program models.LoyaltyProgram, invoice models.Invoice, orderItemId string) (models.LoyaltyReward, string) {
+	for _, id := range invoice.Reward_item_ids {
+		if id == orderItemId {
+			return models.LoyaltyReward{}, "this item is already a reward"
+		}
+	}
+	for _, line := range invoice.Line_items {
+		if line.Order_item_id != orderItemId {
+			continue
+		}
+		for _, reward := range program.Rewards {
+			if reward.Food_id == line.Food_id {
+				return reward, ""
+			}
+		}
+		return models.LoyaltyReward{}, "this item cannot be redeemed with points"
+	}
+	return models.LoyaltyReward{}, "order item is not on this invoice"
+}
+
+// saveRedemption reprices the invoice with the redemption and stores it,
+// as long as nobody redeemed on or paid the invoice in the meantime.
+func saveRedemption(ctx context.Context, invoice *models.Invoice, previousRedeemed int64) error {
+	if err := helpers.BuildInvoice(ctx, invoice); err != nil {
+		return err
+	}
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj := append(invoiceBreakdown(*invoice),
+		bson.E{"points_redeemed", invoice.Points_redeemed},
+		bson.E{"points_discount", invoice.Points_discount},
+		bson.E{"reward_item_ids", invoice.Reward_item_ids},
+		bson.E{"updated_at", invoice.Updated_at},
+	)
+	redeemed := bson.M{"$eq": previousRedeemed}
+	if previousRedeemed == 0 {
+		redeemed = bson.M{"$in": bson.A{0, nil}}
+	}
+	result, err := invoiceCollection.UpdateOne(ctx,
+		bson.M{"invoice_id": invoice.Invoice_id, "payment_status": "PENDING", "amount_paid": nil, "points_redeemed": redeemed},
+		bson.D{{"$set", updateObj}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("invoice changed concurrently, please retry")
+	}
+	return nil
+}
@@ -122,6 +122,14 @@ func UpdateMenu() gin.HandlerFunc {
 			updateObj = append(updateObj, bson.E{"category", menu.Category})
 		}
 
+		if menu.Station_id != nil {
+			if !stationExists(ctx, *menu.Station_id) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "station not found"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"station_id", menu.Station_id})
+		}
+
 		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{"updated_at", menu.Updated_at})
 
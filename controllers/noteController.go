@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var noteCollection *mongo.Collection = database.OpenCollection(database.Client, "note")
+
+// noteEntities maps each kind of record a note can be attached to onto the
+// collection and id field it lives in.
+var noteEntities = map[string]struct {
+	collection *mongo.Collection
+	idField    string
+}{
+	"ORDER":      {orderCollection, "order_id"},
+	"ORDER_ITEM": {orderitemCollection, "order_item_id"},
+	"TABLE":      {tableCollection, "table_id"},
+	"CUSTOMER":   {customerCollection, "customer_id"},
+	"INVOICE":    {invoiceCollection, "invoice_id"},
+}
+
+// GetNotes lists notes, usually for one record with entity_type and
+// entity_id, optionally only those of one visibility.
+func GetNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if entityType := c.Query("entity_type"); entityType != "" {
+			filter["entity_type"] = entityType
+		}
+		if entityId := c.Query("entity_id"); entityId != "" {
+			filter["entity_id"] = entityId
+		}
+		if visibility := c.Query("visibility"); visibility != "" {
+			filter["visibility"] = visibility
+		}
+
+		result, err := noteCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{"created_at", 1}}))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		allNotes := []models.Note{}
+		if err = result.All(ctx, &allNotes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allNotes)
+	}
+}
+
+func GetNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+
+		err := noteCollection.FindOne(ctx, bson.M{"note_id": c.Param("note_id")}).Decode(&note)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+func CreateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+
+		if err := c.BindJSON(&note); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(note)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		entity := noteEntities[*note.Entity_type]
+		count, err := entity.collection.CountDocuments(ctx, bson.M{entity.idField: note.Entity_id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the record this note is for was not found"})
+			return
+		}
+
+		if note.Visibility == nil {
+			visibility := "INTERNAL"
+			note.Visibility = &visibility
+		}
+		note.Author_id = c.GetString("user_id")
+		note.Author_name = c.GetString("first_name")
+		note.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		note.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		note.ID = primitive.NewObjectID()
+		noteId := note.ID.Hex()
+		note.Note_id = &noteId
+
+		result, insertErr := noteCollection.InsertOne(ctx, note)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		publishNote(ctx, note)
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+		noteId := c.Param("note_id")
+
+		if err := c.BindJSON(&note); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if note.Text != nil {
+			if err := validate.Var(*note.Text, "min=1,max=1000"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"text", note.Text})
+		}
+
+		if note.Title != nil {
+			if err := validate.Var(*note.Title, "max=100"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"title", note.Title})
+		}
+
+		if note.Visibility != nil {
+			if err := validate.Var(*note.Visibility, "eq=KITCHEN|eq=INTERNAL"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"visibility", note.Visibility})
+		}
+
+		note.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", note.Updated_at})
+
+		var updated models.Note
+		err := noteCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"note_id": noteId},
+			bson.D{{"$set", updateObj}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishNote(ctx, updated)
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+func DeleteNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var note models.Note
+
+		err := noteCollection.FindOneAndDelete(ctx, bson.M{"note_id": c.Param("note_id")}).Decode(&note)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "note not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishNote(ctx, note)
+		c.JSON(http.StatusOK, note)
+	}
+}
+
+// publishNote tells kitchen screens that a note on an order or one of its
+// items changed so they can reload it. The event carries no text, so
+// internal notes are never exposed to the kitchen this way.
+func publishNote(ctx context.Context, note models.Note) {
+	switch *note.Entity_type {
+	case "ORDER":
+		helpers.PublishKitchenEvent(ctx, models.KitchenEvent{Type: "note.changed", Order_id: *note.Entity_id})
+	case "ORDER_ITEM":
+		helpers.PublishOrderItemEvent(ctx, "note.changed", *note.Entity_id)
+	}
+}
@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,29 +20,40 @@ import (
 
 var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
 
+type orderStatusRequest struct {
+	Status        *string `json:"status"`
+	Action        *string `json:"action" validate:"omitempty,eq=ADVANCE|eq=REVERT"`
+	Reason        *string `json:"reason"`
+	Manager_token *string `json:"manager_token"`
+}
+
 func GetOrders() gin.HandlerFunc {
 	return func(c *gin.Context) {
-
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
-		var order models.Order
+		defer cancel()
 
-		err := c.BindJSON(&order)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			if status == "OPEN" {
+				filter["status"] = bson.M{"$in": bson.A{"OPEN", nil}}
+			} else {
+				filter["status"] = status
+			}
 		}
 
-		result, err := orderCollection.Find(context.TODO(), bson.M{})
+		result, err := orderCollection.Find(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
-		var allorders []bson.M
+		allorders := []bson.M{}
 		err = result.All(ctx, &allorders)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		defer cancel()
-		c.JSON(http.StatusOK, allorders[0])
+		c.JSON(http.StatusOK, allorders)
 
 	}
 }
@@ -67,24 +81,43 @@ func GetOrder() gin.HandlerFunc {
 func CreateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		var order models.Order
 		var table models.Table
 
 		if err := c.BindJSON(&order); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
 
 		validateErr := validate.Struct(order)
 		if validateErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": validateErr.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": validateErr.Error()})
+			return
 		}
 
 		if order.Table_id != nil {
-			err := tableCollection.FindOne(ctx, bson.M{"order_id": order.Table_id}).Decode(&table)
-			defer cancel()
+			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
 			if err != nil {
-				log.Fatal(err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": "table not found"})
+				return
+			}
+		}
+
+		if order.Customer_id != nil {
+			count, err := customerCollection.CountDocuments(ctx, bson.M{"customer_id": order.Customer_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "customer not found"})
+				return
+			}
+		}
+
+		if order.Server_id != nil {
+			count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": order.Server_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "server not found"})
+				return
 			}
 		}
 
@@ -92,13 +125,17 @@ func CreateOrder() gin.HandlerFunc {
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
+		openOrder(&order, c.GetString("user_id"))
 
-		result, insertErr := menuCollection.InsertOne(ctx, order)
+		result, insertErr := orderCollection.InsertOne(ctx, order)
 		if insertErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": insertErr.Error()})
 			return
 		}
-		defer cancel()
+		if order.Table_id != nil {
+			helpers.RefreshTableStatus(ctx, *order.Table_id)
+		}
+		helpers.PublishOrderEvent(ctx, "order.created", order.Order_id, *order.Status)
 		c.JSON(http.StatusOK, result)
 
 	}
@@ -113,6 +150,7 @@ func UpdateOrder() gin.HandlerFunc {
 		var table models.Table
 
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		orderId := c.Param("order_id")
 
@@ -123,12 +161,39 @@ func UpdateOrder() gin.HandlerFunc {
 		var updateObj primitive.D
 
 		if order.Table_id != nil {
-			err := tableCollection.FindOne(ctx, bson.M{"order_id": order.Table_id}).Decode(&table)
-			defer cancel()
+			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
 			if err != nil {
-				log.Fatal(err)
+				c.JSON(http.StatusBadRequest, gin.H{"error": "table not found"})
+				return
+			}
+			current, code, msg := activeOrder(ctx, orderId)
+			if msg != "" {
+				c.JSON(code, gin.H{"error": msg})
+				return
+			}
+			if current.Table_id == nil || *current.Table_id != *order.Table_id {
+				if code, msg = moveOrderTable(ctx, current, *order.Table_id, c.GetString("user_id"), ""); msg != "" {
+					c.JSON(code, gin.H{"error": msg})
+					return
+				}
+			}
+		}
+
+		if order.Order_type != nil {
+			if err := validate.Var(*order.Order_type, "eq=DINE_IN|eq=TAKEAWAY"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"order_type", order.Order_type})
+		}
+
+		if order.Customer_id != nil {
+			count, err := customerCollection.CountDocuments(ctx, bson.M{"customer_id": order.Customer_id})
+			if err != nil || count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "customer not found"})
+				return
 			}
-			updateObj = append(updateObj, bson.E{"tableid", order.Table_id})
+			updateObj = append(updateObj, bson.E{"customer_id", order.Customer_id})
 		}
 
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
@@ -150,7 +215,6 @@ func UpdateOrder() gin.HandlerFunc {
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
-		defer cancel()
 		c.JSON(http.StatusOK, result)
 	}
 
@@ -164,8 +228,205 @@ func OrderItemOrderCreator(order models.Order) string {
 	order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 	order.ID = primitive.NewObjectID()
 	order.Order_id = order.ID.Hex()
+	openOrder(&order, "")
 
-	orderCollection.InsertOne(ctx, order)
+	if _, err := orderCollection.InsertOne(ctx, order); err == nil {
+		if order.Table_id != nil {
+			helpers.RefreshTableStatus(ctx, *order.Table_id)
+		}
+		helpers.PublishOrderEvent(ctx, "order.created", order.Order_id, *order.Status)
+	}
 	defer cancel()
 	return order.Order_id
 }
+
+// openOrder starts a new order in the OPEN status with that as the first
+// entry in its history. Whoever opens the order serves it unless a server
+// was named.
+func openOrder(order *models.Order, userId string) {
+	status := helpers.OrderFlow[0]
+	order.Status = &status
+	order.Status_history = []models.OrderStatusChange{{To: status, Changed_by: userId, Changed_at: order.Created_at}}
+	if order.Server_id == nil && userId != "" {
+		order.Server_id = &userId
+	}
+}
+
+// ChangeOrderStatus moves an order through its lifecycle. The caller either
+// names the target status or asks to ADVANCE or REVERT one step. Voiding an
+// order the kitchen has started on needs a manager, and an order can only
+// be closed once everything on it has been paid.
+func ChangeOrderStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderStatusRequest
+		var order models.Order
+
+		orderId := c.Param("order_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		current := helpers.OrderStatus(order)
+
+		var target string
+		var ok bool
+		switch {
+		case request.Status != nil:
+			target, ok = *request.Status, true
+		case request.Action != nil && *request.Action == "ADVANCE":
+			target, ok = helpers.NextOrderStatus(current)
+		case request.Action != nil && *request.Action == "REVERT":
+			target, ok = helpers.PreviousOrderStatus(current)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status or action is required"})
+			return
+		}
+		if target == "MERGED" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "orders are merged through the merge action"})
+			return
+		}
+		if !ok || !helpers.CanTransitionOrder(current, target) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("order cannot move from %s to %s", current, target)})
+			return
+		}
+
+		reason := ""
+		if request.Reason != nil {
+			reason = strings.TrimSpace(*request.Reason)
+		}
+		if (target == "CANCELLED" || target == "VOID") && reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required to cancel or void an order"})
+			return
+		}
+
+		changedBy := c.GetString("user_id")
+		if target == "VOID" {
+			approvedBy, msg := helpers.ManagerApproval(ctx, request.Manager_token)
+			if msg != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": msg})
+				return
+			}
+			changedBy = approvedBy
+		}
+
+		if target == "CLOSED" {
+			if msg := orderSettled(ctx, orderId); msg != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": msg})
+				return
+			}
+		}
+		if target == "CANCELLED" || target == "VOID" {
+			paid, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$in": bson.A{"PARTIALLY_PAID", "PAID"}}})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if paid > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "order has payments, refund or void them first"})
+				return
+			}
+		}
+
+		change, err := helpers.TransitionOrder(ctx, orderId, current, target, changedBy, reason)
+		if err == helpers.ErrOrderChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		order.Status = &target
+		order.Updated_at = change.Changed_at
+		order.Status_history = append(order.Status_history, change)
+		c.JSON(http.StatusOK, order)
+	}
+}
+
+// orderSettled explains why an order cannot be closed yet, or returns ""
+// when it has been invoiced and every live invoice is paid.
+func orderSettled(ctx context.Context, orderId string) string {
+	invoiced, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$ne": "VOID"}})
+	if err != nil {
+		return err.Error()
+	}
+	if invoiced == 0 {
+		return "order has not been invoiced"
+	}
+	unpaid, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$in": bson.A{"PENDING", "PARTIALLY_PAID", nil}}})
+	if err != nil {
+		return err.Error()
+	}
+	if unpaid > 0 {
+		return "order has unpaid invoices"
+	}
+	return ""
+}
+
+// FireCourse releases the held items of a course to the kitchen and prints
+// their tickets.
+func FireCourse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var order models.Order
+
+		orderId := c.Param("order_id")
+		course, err := strconv.Atoi(c.Param("course"))
+		if err != nil || course < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "course must be a positive number"})
+			return
+		}
+
+		err = orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + strings.ToLower(status)})
+			return
+		}
+
+		released, err := helpers.FireCourse(ctx, order, course, c.GetString("user_id"))
+		if err == helpers.ErrCourseNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err == helpers.ErrCourseFired {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		jobs := []models.PrintJob{}
+		if len(released) > 0 {
+			jobs, err = printKitchenTickets(ctx, orderId, released, c.GetString("first_name"))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"course": course, "order_item_ids": released, "print_jobs": jobs})
+	}
+}
@@ -2,22 +2,30 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
 	"golang-restaurant-management/models"
 
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type orderitemPack struct {
-	Table_id   *string
-	orderItems []models.OrderItem
+	Table_id    *string            `json:"table_id"`
+	Order_id    *string            `json:"order_id"`
+	Order_items []models.OrderItem `json:"order_items"`
+}
+
+type orderItemStatusRequest struct {
+	Status *string `json:"status" validate:"omitempty,eq=QUEUED|eq=FIRING|eq=READY|eq=DELIVERED|eq=VOIDED"`
+	Action *string `json:"action" validate:"omitempty,eq=ADVANCE|eq=REVERT"`
 }
 
 var orderitemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderitem")
@@ -85,24 +93,43 @@ func ItemsByOrder(id string) (orderItems []primitive.M, err error) {
 
 	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
 	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
-	projectStage := bson.D{{"project", bson.D{
-		{"id", 0},
-		{"amount", "$food.price"},
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"amount", bson.D{{"$cond", bson.A{
+			bson.D{{"$or", bson.A{
+				bson.D{{"$eq", bson.A{"$status", "VOIDED"}}},
+				bson.D{{"$gt", bson.A{"$adjustment", nil}}},
+			}}},
+			0,
+			bson.D{{"$multiply", bson.A{
+				bson.D{{"$add", bson.A{
+					bson.D{{"$ifNull", bson.A{"$unit_price.amount", "$food.price.amount"}}},
+					bson.D{{"$sum", "$modifiers.price_delta.amount"}},
+				}}},
+				bson.D{{"$ifNull", bson.A{"$quantity", 1}}},
+			}}},
+		}}}},
+		{"currency", bson.D{{"$ifNull", bson.A{"$unit_price.currency", "$food.price.currency"}}}},
 		{"total_count", 1},
 		{"food_name", "$food.name"},
+		{"variant_name", 1},
+		{"modifiers", 1},
+		{"status", 1},
+		{"adjustment", 1},
 		{"food_image", "$food.food_image"},
 		{"table_number", "$table.table_number"},
 		{"number_of_guest", "$table.number_of_guest"},
 		{"table_id", "$table.table_id"},
 		{"order_id", "$order.order_id"},
-		{"price", "$food.price"},
+		{"price", bson.D{{"$ifNull", bson.A{"$unit_price", "$food.price"}}}},
 		{"quantity", 1},
 	}}}
-	groupStage := bson.D{{"group", bson.D{{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}}}, {"payment_due", bson.D{{"$sum", "$amount"}}}, {"$total_count", bson.D{{"$sum", 1}}}, {"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
-	projectStage2 := bson.D{{"project", bson.D{
-		{"id", 0},
+	groupStage := bson.D{{"$group", bson.D{{"_id", bson.D{{"order_id", "$order_id"}, {"table_id", "$table_id"}, {"table_number", "$table_number"}, {"currency", "$currency"}}}, {"payment_due", bson.D{{"$sum", bson.D{{"$toLong", "$amount"}}}}}, {"total_count", bson.D{{"$sum", 1}}}, {"order_items", bson.D{{"$push", "$$ROOT"}}}}}}
+	projectStage2 := bson.D{{"$project", bson.D{
+		{"_id", 0},
 		{"total_count", 1},
 		{"table_number", "$_id.table_number"},
+		{"payment_due", bson.D{{"amount", "$payment_due"}, {"currency", "$_id.currency"}}},
 		{"order_items", 1},
 	}}}
 	results, err := orderitemCollection.Aggregate(ctx, mongo.Pipeline{
@@ -127,43 +154,108 @@ func ItemsByOrder(id string) (orderItems []primitive.M, err error) {
 	return orderItems, err
 }
 
+// CreateOrderItem adds items to an order, starting a new one for the table
+// unless order_id names an existing order. Dine-in items of later courses
+// are held back from the kitchen until their course is fired.
 func CreateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		var orderitemPack orderitemPack
 		var order models.Order
 
 		err := c.BindJSON(&orderitemPack)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(orderitemPack.Order_items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order_items is required"})
+			return
+		}
+		for i, orderItem := range orderitemPack.Order_items {
+			validationErr := validate.StructExcept(orderItem, "Order_id")
+			if validationErr != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+				return
+			}
+			if msg := approvePriceOverride(ctx, &orderItem); msg != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": msg})
+				return
+			}
+			if msg := priceOrderItem(ctx, &orderItem); msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+			orderitemPack.Order_items[i] = orderItem
 		}
 
-		order.Order_date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		orderItemsTobeInserted := []interface{}{}
-		order.Table_id = orderitemPack.Table_id
-		order_id := OrderItemOrderCreator(order)
+		if orderitemPack.Order_id != nil {
+			err = orderCollection.FindOne(ctx, bson.M{"order_id": orderitemPack.Order_id}).Decode(&order)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+				return
+			}
+			if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+				c.JSON(http.StatusConflict, gin.H{"error": "order is " + strings.ToLower(status)})
+				return
+			}
+		} else {
+			order.Order_date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			order.Table_id = orderitemPack.Table_id
+			if serverId := c.GetString("user_id"); serverId != "" {
+				order.Server_id = &serverId
+			}
+			order.Order_id = OrderItemOrderCreator(order)
+		}
+		order_id := order.Order_id
 
-		for _, orderItem := range orderitemPack.orderItems {
+		orderItemsTobeInserted := []interface{}{}
+		sent := false
+		for _, orderItem := range orderitemPack.Order_items {
 			orderItem.Order_id = &order_id
-
-			validationErr := validate.Struct(orderItem)
-			if validationErr != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": validationErr.Error()})
-			}
 			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			status := helpers.ItemFlow[0]
+			orderItem.Status = &status
+			if orderItem.Course == nil {
+				course := 1
+				orderItem.Course = &course
+			}
+			if orderItem.Hold == nil {
+				hold := helpers.HoldByDefault(order, *orderItem.Course)
+				orderItem.Hold = &hold
+			}
+			if !*orderItem.Hold {
+				orderItem.Queued_at = &orderItem.Created_at
+				sent = true
+			}
+			if orderItem.Station_id == nil && orderItem.Food_id != nil {
+				orderItem.Station_id = helpers.StationFor(ctx, *orderItem.Food_id)
+			}
+			if orderItem.Priority == nil {
+				priority := 0
+				orderItem.Priority = &priority
+			}
 			orderItem.ID = primitive.NewObjectID()
 			orderItem.Order_item_id = orderItem.ID.Hex()
-			var num = tofixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
 			orderItemsTobeInserted = append(orderItemsTobeInserted, orderItem)
 		}
 		result, err := orderitemCollection.InsertMany(ctx, orderItemsTobeInserted)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, orderItem := range orderItemsTobeInserted {
+			helpers.PublishOrderItemEvent(ctx, "order_item.created", orderItem.(models.OrderItem).Order_item_id)
+		}
+		if sent {
+			if err = helpers.SendOrderToKitchen(ctx, order_id, c.GetString("user_id")); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
 		}
-		defer cancel()
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -172,51 +264,261 @@ func UpdateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
 
 		var orderitem models.OrderItem
 
-		orderitemId := c.Param("orderitem_id")
+		orderitemId := c.Param("orderItem_id")
 
 		err := c.BindJSON(&orderitem)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 
 		var updateObj primitive.D
 
 		if orderitem.Quantity != nil {
+			if err := validate.Var(*orderitem.Quantity, "min=1,max=999"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			updateObj = append(updateObj, bson.E{"quantity", orderitem.Quantity})
 		}
 
-		if orderitem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{"unit_price", orderitem.Unit_price})
+		if orderitem.Seat_number != nil {
+			updateObj = append(updateObj, bson.E{"seat_number", orderitem.Seat_number})
 		}
 
-		if orderitem.Food_id != nil {
-			updateObj = append(updateObj, bson.E{"oderitem_foodid", orderitem.Food_id})
+		if orderitem.Food_id != nil && orderitem.Station_id == nil {
+			updateObj = append(updateObj, bson.E{"station_id", helpers.StationFor(ctx, *orderitem.Food_id)})
 		}
 
-		orderitem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{"update_at", orderitem.Updated_at})
+		if orderitem.Station_id != nil {
+			if !stationExists(ctx, *orderitem.Station_id) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "station not found"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"station_id", orderitem.Station_id})
+		}
+
+		dishChanged := orderitem.Food_id != nil || orderitem.Variant_id != nil || orderitem.Size != nil
+		repricing := dishChanged || orderitem.Modifiers != nil || orderitem.Unit_price != nil
+		kitchenChange := dishChanged || orderitem.Modifiers != nil || orderitem.Quantity != nil || orderitem.Course != nil || orderitem.Hold != nil
+		if repricing || kitchenChange {
+			var current models.OrderItem
+			if err := orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderitemId}).Decode(&current); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+				return
+			}
+			if kitchenChange && helpers.OrderItemStatus(current) != "QUEUED" {
+				c.JSON(http.StatusConflict, gin.H{"error": "the kitchen has already started on this item"})
+				return
+			}
+			if repricing {
+				merged := current
+				if orderitem.Food_id != nil {
+					merged.Food_id = orderitem.Food_id
+					merged.Variant_id, merged.Size, merged.Modifiers = nil, nil, nil
+				}
+				if orderitem.Variant_id != nil || orderitem.Size != nil {
+					merged.Variant_id, merged.Size = orderitem.Variant_id, orderitem.Size
+				}
+				if orderitem.Modifiers != nil {
+					merged.Modifiers = orderitem.Modifiers
+				}
+				if dishChanged || orderitem.Unit_price != nil {
+					if msg := approvePriceOverride(ctx, &orderitem); msg != "" {
+						c.JSON(http.StatusForbidden, gin.H{"error": msg})
+						return
+					}
+					merged.Unit_price = orderitem.Unit_price
+					merged.Price_set_by = orderitem.Price_set_by
+				}
+				if msg := priceOrderItem(ctx, &merged); msg != "" {
+					c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+					return
+				}
+				updateObj = append(updateObj,
+					bson.E{"food_id", merged.Food_id},
+					bson.E{"variant_id", merged.Variant_id},
+					bson.E{"size", merged.Size},
+					bson.E{"variant_name", merged.Variant_name},
+					bson.E{"sku", merged.Sku},
+					bson.E{"unit_price", merged.Unit_price},
+					bson.E{"price_set_by", merged.Price_set_by},
+					bson.E{"modifiers", merged.Modifiers},
+				)
+			}
+		}
 
-		upsert := true
+		if orderitem.Course != nil {
+			if err := validate.Var(*orderitem.Course, "min=1,max=9"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"course", orderitem.Course})
+		}
 
-		filter := bson.M{"orderitem_id": orderitemId}
+		if orderitem.Hold != nil {
+			updateObj = append(updateObj, bson.E{"hold", orderitem.Hold})
+			if !*orderitem.Hold {
+				releasedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+				updateObj = append(updateObj, bson.E{"released_at", releasedAt}, bson.E{"queued_at", releasedAt})
+			}
+		}
 
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if orderitem.Priority != nil {
+			if err := validate.Var(*orderitem.Priority, "min=0,max=10"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"priority", orderitem.Priority})
 		}
 
+		orderitem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", orderitem.Updated_at})
+
+		filter := bson.M{"order_item_id": orderitemId, "adjustment": nil}
+
 		result, err := orderitemCollection.UpdateOne(
 			ctx,
 			filter,
 			bson.D{{"$set", updateObj}},
-			&opt,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
-		defer cancel()
+		if result.MatchedCount == 0 {
+			if count, _ := orderitemCollection.CountDocuments(ctx, bson.M{"order_item_id": orderitemId}); count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "voided or comped items cannot be changed"})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			return
+		}
+		helpers.PublishOrderItemEvent(ctx, "order_item.updated", orderitemId)
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// ChangeOrderItemStatus moves an item through the kitchen. The caller either
+// names the status or asks to ADVANCE or REVERT one step, and the order is
+// rolled forward once its items call for it.
+func ChangeOrderItemStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderItemStatusRequest
+		var orderItem models.OrderItem
+		var order models.Order
+
+		orderItemId := c.Param("orderItem_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			return
+		}
+
+		err = orderCollection.FindOne(ctx, bson.M{"order_id": orderItem.Order_id}).Decode(&order)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+			c.JSON(http.StatusConflict, gin.H{"error": "order is " + strings.ToLower(status)})
+			return
+		}
+
+		current := helpers.OrderItemStatus(orderItem)
+		if request.Status != nil && *request.Status == "VOIDED" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order items are voided with a reason code through the void action"})
+			return
+		}
+		if helpers.ItemHeld(orderItem) {
+			c.JSON(http.StatusConflict, gin.H{"error": "order item is held until its course is fired"})
+			return
+		}
+		var target string
+		var ok bool
+		switch {
+		case request.Status != nil:
+			target, ok = *request.Status, true
+		case request.Action != nil && *request.Action == "ADVANCE":
+			target, ok = helpers.NextOrderItemStatus(current)
+		case request.Action != nil && *request.Action == "REVERT":
+			target, ok = helpers.PreviousOrderItemStatus(current)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status or action is required"})
+			return
+		}
+		if !ok || !helpers.CanTransitionOrderItem(current, target) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("order item cannot move from %s to %s", current, target)})
+			return
+		}
+
+		_, err = helpers.TransitionOrderItem(ctx, orderItemId, current, target)
+		if err == helpers.ErrOrderItemChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		orderStatus, err := helpers.RollUpOrderStatus(ctx, *orderItem.Order_id, c.GetString("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"order_item": orderItem, "order_status": orderStatus})
+	}
+}
+
+// approvePriceOverride lets a unit price sent by the till replace the menu
+// price only with a manager's approval, and records who gave it.
+func approvePriceOverride(ctx context.Context, orderItem *models.OrderItem) string {
+	orderItem.Price_set_by = nil
+	if orderItem.Unit_price == nil {
+		return ""
+	}
+	approvedBy, msg := helpers.ManagerOverride(ctx, orderItem.Manager_token, orderItem.Manager_id, orderItem.Manager_pin)
+	if msg != "" {
+		return "overriding the menu price: " + msg
+	}
+	orderItem.Price_set_by = &approvedBy
+	return ""
+}
+
+// priceOrderItem resolves the variant and modifiers of an item against its
+// food and prices it from the menu.
+func priceOrderItem(ctx context.Context, orderItem *models.OrderItem) string {
+	var food models.Food
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": orderItem.Food_id}).Decode(&food); err != nil {
+		return "food not found"
+	}
+	if err := helpers.PriceOrderItem(food, orderItem); err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type orderTableRequest struct {
+	Table_id *string `json:"table_id" validate:"required"`
+	Reason   *string `json:"reason"`
+}
+
+type orderMergeRequest struct {
+	From_order_id *string `json:"from_order_id"`
+	From_table_id *string `json:"from_table_id"`
+	Reason        *string `json:"reason"`
+}
+
+type orderItemsMoveRequest struct {
+	To_order_id    *string  `json:"to_order_id" validate:"required"`
+	Order_item_ids []string `json:"order_item_ids" validate:"required,min=1"`
+	Reason         *string  `json:"reason"`
+}
+
+type orderServerRequest struct {
+	Server_id *string `json:"server_id" validate:"required"`
+	Reason    *string `json:"reason"`
+}
+
+// MoveOrderTable moves a party's order to another table.
+func MoveOrderTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderTableRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		order, code, msg := activeOrder(ctx, c.Param("order_id"))
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+
+		if code, msg = moveOrderTable(ctx, order, *request.Table_id, c.GetString("user_id"), stringValue(request.Reason)); msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+		respondWithOrder(c, ctx, order.Order_id)
+	}
+}
+
+// MergeOrders brings another order, named directly or by its table, into
+// this one. Its items move across and the emptied order is closed as
+// MERGED, which frees its table.
+func MergeOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderMergeRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		target, code, msg := activeOrder(ctx, c.Param("order_id"))
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+
+		var source models.Order
+		switch {
+		case request.From_order_id != nil:
+			source, code, msg = activeOrder(ctx, *request.From_order_id)
+		case request.From_table_id != nil:
+			source, code, msg = activeOrderAtTable(ctx, *request.From_table_id)
+		default:
+			code, msg = http.StatusBadRequest, "from_order_id or from_table_id is required"
+		}
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+		if source.Order_id == target.Order_id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "an order cannot be merged into itself"})
+			return
+		}
+		sourceStatus := helpers.OrderStatus(source)
+		if !helpers.CanTransitionOrder(sourceStatus, "MERGED") {
+			c.JSON(http.StatusConflict, gin.H{"error": "order " + source.Order_id + " cannot be merged while " + strings.ToLower(sourceStatus)})
+			return
+		}
+
+		invoiced, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": source.Order_id, "payment_status": bson.M{"$ne": "VOID"}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if invoiced > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "void the invoices of the order being merged first"})
+			return
+		}
+		if msg := orderInvoicesOpen(ctx, target.Order_id); msg != "" {
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+
+		orderItemIds, err := orderItemIdsOf(ctx, source.Order_id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		userId := c.GetString("user_id")
+		reason := stringValue(request.Reason)
+		if reason == "" {
+			reason = "merged into order " + target.Order_id
+		}
+
+		// Move the items before closing the source, so a failure never
+		// leaves them on a finished order.
+		if _, err = helpers.MoveOrderItems(ctx, orderItemIds, source.Order_id, target.Order_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err = helpers.TransitionOrder(ctx, source.Order_id, sourceStatus, "MERGED", userId, reason)
+		if err != nil {
+			helpers.MoveOrderItems(ctx, orderItemIds, target.Order_id, source.Order_id)
+		}
+		if err == helpers.ErrOrderChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		change := orderChange("MERGED_INTO", source.Order_id, target.Order_id, orderItemIds, userId, reason)
+		if err = helpers.RecordOrderChange(ctx, bson.M{"order_id": source.Order_id}, nil, change); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		change.Action = "MERGED_IN"
+		set := bson.D{{"updated_at", change.Changed_at}}
+		if target.Customer_id == nil && source.Customer_id != nil {
+			set = append(set, bson.E{"customer_id", source.Customer_id})
+		}
+		if err = helpers.RecordOrderChange(ctx, bson.M{"order_id": target.Order_id}, set, change); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err = rebuildOrderInvoices(ctx, target.Order_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		orderStatus, err := helpers.RollUpOrderStatus(ctx, target.Order_id, userId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		helpers.PublishOrderEvent(ctx, "order.merged", target.Order_id, orderStatus)
+		respondWithOrder(c, ctx, target.Order_id)
+	}
+}
+
+// MoveOrderItemsBetweenOrders moves some of an order's items onto another
+// order, for guests who change tables or want to pay separately.
+func MoveOrderItemsBetweenOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderItemsMoveRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		source, code, msg := activeOrder(ctx, c.Param("order_id"))
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+		target, code, msg := activeOrder(ctx, *request.To_order_id)
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+		if source.Order_id == target.Order_id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "items are already on that order"})
+			return
+		}
+
+		orderItemIds := []string{}
+		seen := map[string]bool{}
+		for _, orderItemId := range request.Order_item_ids {
+			if !seen[orderItemId] {
+				seen[orderItemId] = true
+				orderItemIds = append(orderItemIds, orderItemId)
+			}
+		}
+		count, err := orderitemCollection.CountDocuments(ctx, bson.M{"order_item_id": bson.M{"$in": orderItemIds}, "order_id": source.Order_id})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count != int64(len(orderItemIds)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order items not found on this order"})
+			return
+		}
+
+		for _, orderId := range []string{source.Order_id, target.Order_id} {
+			if msg := orderInvoicesOpen(ctx, orderId); msg != "" {
+				c.JSON(http.StatusConflict, gin.H{"error": msg})
+				return
+			}
+		}
+
+		if _, err = helpers.MoveOrderItems(ctx, orderItemIds, source.Order_id, target.Order_id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		userId := c.GetString("user_id")
+		change := orderChange("ITEMS_MOVED_OUT", source.Order_id, target.Order_id, orderItemIds, userId, stringValue(request.Reason))
+		set := bson.D{{"updated_at", change.Changed_at}}
+		if err = helpers.RecordOrderChange(ctx, bson.M{"order_id": source.Order_id}, set, change); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		change.Action = "ITEMS_MOVED_IN"
+		if err = helpers.RecordOrderChange(ctx, bson.M{"order_id": target.Order_id}, set, change); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, orderId := range []string{source.Order_id, target.Order_id} {
+			if err = rebuildOrderInvoices(ctx, orderId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err = helpers.RollUpOrderStatus(ctx, orderId, userId); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"from_order_id": source.Order_id, "to_order_id": target.Order_id, "order_item_ids": orderItemIds})
+	}
+}
+
+// ReassignOrderServer hands an order over to another server, typically when
+// sections change hands at shift change.
+func ReassignOrderServer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request orderServerRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		order, code, msg := activeOrder(ctx, c.Param("order_id"))
+		if msg != "" {
+			c.JSON(code, gin.H{"error": msg})
+			return
+		}
+
+		count, err := userCollection.CountDocuments(ctx, bson.M{"user_id": request.Server_id})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "server not found"})
+			return
+		}
+		current := stringValue(order.Server_id)
+		if current == *request.Server_id {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "order is already served by that server"})
+			return
+		}
+
+		change := orderChange("SERVER_CHANGED", current, *request.Server_id, nil, c.GetString("user_id"), stringValue(request.Reason))
+		filter := bson.M{"order_id": order.Order_id, "server_id": order.Server_id}
+		set := bson.D{{"server_id", request.Server_id}, {"updated_at", change.Changed_at}}
+		err = helpers.RecordOrderChange(ctx, filter, set, change)
+		if err == helpers.ErrOrderChanged {
+			c.JSON(http.StatusConflict, gin.H{"error": "order was reassigned concurrently, please retry"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		respondWithOrder(c, ctx, order.Order_id)
+	}
+}
+
+// moveOrderTable moves an order to another table, records the move, updates
+// both tables and reprices the order's open invoices for the new party size.
+// It returns a status code and message when the move is refused.
+func moveOrderTable(ctx context.Context, order models.Order, tableId string, userId string, reason string) (int, string) {
+	count, err := tableCollection.CountDocuments(ctx, bson.M{"table_id": tableId})
+	if err != nil || count == 0 {
+		return http.StatusBadRequest, "table not found"
+	}
+	from := stringValue(order.Table_id)
+	if from == tableId {
+		return http.StatusBadRequest, "order is already at that table"
+	}
+
+	change := orderChange("TABLE_MOVED", from, tableId, nil, userId, reason)
+	filter := bson.M{"order_id": order.Order_id, "table_id": order.Table_id}
+	set := bson.D{{"table_id", tableId}, {"updated_at", change.Changed_at}}
+	err = helpers.RecordOrderChange(ctx, filter, set, change)
+	if err == helpers.ErrOrderChanged {
+		return http.StatusConflict, "order was moved concurrently, please retry"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	for _, id := range []string{from, tableId} {
+		if id == "" {
+			continue
+		}
+		if _, err = helpers.RefreshTableStatus(ctx, id); err != nil {
+			return http.StatusInternalServerError, err.Error()
+		}
+	}
+	if err = rebuildOrderInvoices(ctx, order.Order_id); err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	helpers.PublishOrderEvent(ctx, "order.moved", order.Order_id, helpers.OrderStatus(order))
+	return http.StatusOK, ""
+}
+
+// activeOrder loads an order that is still on the floor.
+func activeOrder(ctx context.Context, orderId string) (models.Order, int, string) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return order, http.StatusNotFound, "order not found"
+	}
+	if status := helpers.OrderStatus(order); helpers.OrderFinished(status) {
+		return order, http.StatusConflict, "order " + orderId + " is " + strings.ToLower(status)
+	}
+	return order, http.StatusOK, ""
+}
+
+// activeOrderAtTable finds the one order still going at a table.
+func activeOrderAtTable(ctx context.Context, tableId string) (models.Order, int, string) {
+	var orders []models.Order
+	cursor, err := orderCollection.Find(ctx, bson.M{
+		"table_id": tableId,
+		"status":   bson.M{"$nin": bson.A{"CLOSED", "CANCELLED", "VOID", "MERGED"}},
+	})
+	if err != nil {
+		return models.Order{}, http.StatusInternalServerError, err.Error()
+	}
+	if err = cursor.All(ctx, &orders); err != nil {
+		return models.Order{}, http.StatusInternalServerError, err.Error()
+	}
+	switch len(orders) {
+	case 0:
+		return models.Order{}, http.StatusNotFound, "table has no open order"
+	case 1:
+		return orders[0], http.StatusOK, ""
+	default:
+		return models.Order{}, http.StatusConflict, "table has more than one open order, name the order to merge"
+	}
+}
+
+func orderItemIdsOf(ctx context.Context, orderId string) ([]string, error) {
+	cursor, err := orderitemCollection.Find(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return nil, err
+	}
+	var items []models.OrderItem
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	orderItemIds := []string{}
+	for _, item := range items {
+		orderItemIds = append(orderItemIds, item.Order_item_id)
+	}
+	return orderItemIds, nil
+}
+
+func orderChange(action string, from string, to string, orderItemIds []string, userId string, reason string) models.OrderChange {
+	change := models.OrderChange{Action: action, From: from, To: to, Order_item_ids: orderItemIds, Reason: reason, Changed_by: userId}
+	change.Changed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return change
+}
+
+func respondWithOrder(c *gin.Context, ctx context.Context, orderId string) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
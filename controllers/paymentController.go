@@ -0,0 +1,547 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var paymentCollection *mongo.Collection = database.OpenCollection(database.Client, "payment")
+
+func GetPayments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		result, err := paymentCollection.Find(ctx, bson.M{"invoice_id": invoiceId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allPayments []bson.M
+		if err = result.All(ctx, &allPayments); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allPayments)
+	}
+}
+
+func CreatePayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		var invoice models.Invoice
+
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&payment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(payment)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if key := c.GetHeader("Idempotency-Key"); key != "" {
+			payment.Idempotency_key = &key
+		}
+
+		if payment.Idempotency_key != nil {
+			if status, msg := replayPayment(ctx, invoiceId, &payment, &invoice); status != http.StatusNotFound {
+				if msg != "" {
+					c.JSON(status, gin.H{"error": msg})
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{"payment": payment, "invoice": invoice})
+				return
+			}
+		}
+
+		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+
+		status, msg := recordPayment(ctx, &invoice, &payment, c.GetString("user_id"))
+		if msg != "" {
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"payment": payment, "invoice": invoice})
+	}
+}
+
+// replayPayment loads the payment already taken on an invoice under the
+// idempotency key of payment, along with the invoice. It returns
+// http.StatusNotFound when the key has not been used yet.
+func replayPayment(ctx context.Context, invoiceId string, payment *models.Payment, invoice *models.Invoice) (int, string) {
+	var existing models.Payment
+	err := paymentCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId, "idempotency_key": payment.Idempotency_key}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		return http.StatusNotFound, "payment not found"
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	if existing.Status == "PENDING" {
+		return http.StatusConflict, "a payment with this Idempotency-Key is still in progress"
+	}
+	*payment = existing
+	if err = invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(invoice); err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	return http.StatusOK, ""
+}
+
+// recordPayment applies payment to invoice and stores both. The payment is
+// stored as PENDING before any money moves, so its idempotency key is
+// claimed by the unique index and a concurrent retry replays it instead of
+// charging again. The invoice is updated with a compare-and-set on the
+// amount already paid, so two tills paying the same invoice at once cannot
+// both take the last of the balance.
+func recordPayment(ctx context.Context, invoice *models.Invoice, payment *models.Payment, userId string) (int, string) {
+	if invoice.Total == nil {
+		return http.StatusConflict, "invoice has no calculated total"
+	}
+	if invoice.Payment_status != nil && (*invoice.Payment_status == "PAID" || *invoice.Payment_status == "REFUNDED" || *invoice.Payment_status == "VOID") {
+		return http.StatusConflict, "invoice is already " + *invoice.Payment_status
+	}
+	if helpers.InvoiceLocked(*invoice) {
+		return http.StatusConflict, "invoice belongs to a closed business day"
+	}
+
+	previousPaid := invoice.Amount_paid
+	previousBalance := invoice.Balance_due
+	previousStatus := invoice.Payment_status
+	paid := models.NewMoney(0, invoice.Total.Currency)
+	if previousPaid != nil {
+		paid = *previousPaid
+	}
+	balance := invoice.Total.Sub(paid)
+
+	amount := models.NewMoney(payment.Amount.Amount, payment.Amount.Currency)
+	if amount.Currency != invoice.Total.Currency {
+		return http.StatusBadRequest, "payment currency does not match the invoice"
+	}
+
+	applied, change, ok := helpers.ApplyTender(balance, *payment.Tender, amount)
+	if !ok {
+		return http.StatusBadRequest, "payment amount must be positive and not exceed the balance due"
+	}
+
+	if *payment.Tender == "CASH" {
+		shift := helpers.OpenShiftFor(ctx, userId)
+		if shift == nil {
+			return http.StatusConflict, "open a shift before taking cash"
+		}
+		payment.Shift_id = &shift.Shift_id
+	}
+
+	payment.ID = primitive.NewObjectID()
+	payment.Payment_id = payment.ID.Hex()
+	payment.Status = "PENDING"
+	payment.Invoice_id = invoice.Invoice_id
+	payment.Amount = &applied
+	payment.Tendered = &amount
+	payment.Change_given = &change
+	payment.Created_by = userId
+	payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	payment.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	_, err := paymentCollection.InsertOne(ctx, payment)
+	if mongo.IsDuplicateKeyError(err) {
+		return replayPayment(ctx, invoice.Invoice_id, payment, invoice)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	// releaseClaim drops the pending payment when no money was kept, so the
+	// key can be retried.
+	releaseClaim := func() {
+		_, err := paymentCollection.DeleteOne(ctx, bson.M{"payment_id": payment.Payment_id, "status": "PENDING"})
+		if err != nil {
+			log.Println("could not release pending payment", payment.Payment_id, err)
+		}
+	}
+
+	if *payment.Tender == "CARD" {
+		if status, msg := chargeCard(ctx, payment, applied); msg != "" {
+			releaseClaim()
+			return status, msg
+		}
+	}
+
+	if *payment.Tender == "GIFT_CARD" {
+		if status, msg := chargeGiftCard(ctx, payment, applied, invoice.Invoice_id, userId); msg != "" {
+			releaseClaim()
+			return status, msg
+		}
+	}
+
+	newPaid := paid.Add(applied)
+	invoice.Amount_paid = &newPaid
+	helpers.SettleInvoice(invoice)
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	filter := bson.M{"invoice_id": invoice.Invoice_id, "amount_paid": previousPaid}
+	result, err := invoiceCollection.UpdateOne(ctx, filter, bson.D{{"$set", bson.D{
+		{"amount_paid", invoice.Amount_paid},
+		{"balance_due", invoice.Balance_due},
+		{"payment_status", invoice.Payment_status},
+		{"updated_at", invoice.Updated_at},
+	}}})
+	if err != nil {
+		reverseTender(ctx, payment, userId)
+		releaseClaim()
+		return http.StatusInternalServerError, err.Error()
+	}
+	if result.MatchedCount == 0 {
+		reverseTender(ctx, payment, userId)
+		releaseClaim()
+		return http.StatusConflict, "invoice was paid concurrently, please retry"
+	}
+
+	payment.Status = "CAPTURED"
+	_, err = paymentCollection.ReplaceOne(ctx, bson.M{"payment_id": payment.Payment_id, "status": "PENDING"}, payment)
+	if err != nil {
+		invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id}, bson.D{{"$set", bson.D{
+			{"amount_paid", previousPaid},
+			{"balance_due", previousBalance},
+			{"payment_status", previousStatus},
+		}}})
+		reverseTender(ctx, payment, userId)
+		releaseClaim()
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	if *invoice.Payment_status == "PAID" {
+		if err = helpers.AwardLoyaltyPoints(ctx, *invoice); err != nil {
+			log.Println("could not award loyalty points", invoice.Invoice_id, err)
+		}
+		if err = helpers.RecordCustomerVisit(ctx, *invoice); err != nil {
+			log.Println("could not record customer visit", invoice.Invoice_id, err)
+		}
+	}
+	return http.StatusOK, ""
+}
+
+func VoidPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var payment models.Payment
+		var invoice models.Invoice
+		var request voidRequest
+
+		invoiceId := c.Param("invoice_id")
+		paymentId := c.Param("payment_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := paymentCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId, "payment_id": paymentId}).Decode(&payment)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+			return
+		}
+		if payment.Status != "CAPTURED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only captured payments can be voided"})
+			return
+		}
+		if payment.Refunded_amount != nil && payment.Refunded_amount.Amount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "payment has refunds and cannot be voided"})
+			return
+		}
+
+		var approvedBy string
+		if helpers.RefundNeedsApproval(ctx, *payment.Amount) {
+			var msg string
+			approvedBy, msg = helpers.ManagerApproval(ctx, request.Manager_token)
+			if msg != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": msg})
+				return
+			}
+		}
+
+		err = invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+		if helpers.InvoiceLocked(invoice) {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+			return
+		}
+
+		voidedBy := c.GetString("user_id")
+		if approvedBy != "" {
+			voidedBy = approvedBy
+		}
+		payment.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// Claim the payment first so a concurrent refund or void cannot touch
+		// it while the tender is being given back.
+		result, err := paymentCollection.UpdateOne(ctx, bson.M{"payment_id": paymentId, "status": "CAPTURED", "refunded_amount": payment.Refunded_amount}, bson.D{{"$set", bson.D{
+			{"status", "VOIDING"},
+			{"updated_at", payment.Updated_at},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "payment changed concurrently, please retry"})
+			return
+		}
+		releasePayment := func() {
+			_, err := paymentCollection.UpdateOne(ctx, bson.M{"payment_id": paymentId, "status": "VOIDING"}, bson.D{{"$set", bson.D{{"status", "CAPTURED"}}}})
+			if err != nil {
+				log.Println("could not release payment", paymentId, err)
+			}
+		}
+
+		previousPaid := invoice.Amount_paid
+		previousBalance := invoice.Balance_due
+		previousStatus := invoice.Payment_status
+		wasPaid := previousStatus != nil && *previousStatus == "PAID"
+		paid := models.NewMoney(0, payment.Amount.Currency)
+		if previousPaid != nil {
+			paid = previousPaid.Sub(*payment.Amount)
+		}
+		invoice.Amount_paid = &paid
+		helpers.SettleInvoice(&invoice)
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		result, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoiceId, "amount_paid": previousPaid}, bson.D{{"$set", bson.D{
+			{"amount_paid", invoice.Amount_paid},
+			{"balance_due", invoice.Balance_due},
+			{"payment_status", invoice.Payment_status},
+			{"updated_at", invoice.Updated_at},
+		}}})
+		if err != nil {
+			releasePayment()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			releasePayment()
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice changed concurrently, please retry"})
+			return
+		}
+		restoreInvoice := func() {
+			_, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoiceId, "amount_paid": invoice.Amount_paid}, bson.D{{"$set", bson.D{
+				{"amount_paid", previousPaid},
+				{"balance_due", previousBalance},
+				{"payment_status", previousStatus},
+			}}})
+			if err != nil {
+				log.Println("could not restore invoice after a failed void", invoiceId, err)
+			}
+			releasePayment()
+		}
+
+		if payment.Provider_transaction_id != nil {
+			providerResult, err := helpers.WithProviderRetry(ctx, 3, func(ctx context.Context) (helpers.ProviderResult, error) {
+				return helpers.PaymentGateway.Void(ctx, *payment.Provider_transaction_id, "void:"+payment.Payment_id)
+			})
+			if err != nil {
+				restoreInvoice()
+				if errors.Is(err, helpers.ErrPaymentDeclined) {
+					c.JSON(http.StatusPaymentRequired, gin.H{"error": providerResult.Decline_reason})
+					return
+				}
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if payment.Gift_card_id != nil {
+			_, err = helpers.CreditGiftCard(ctx, *payment.Gift_card_id, *payment.Amount, "VOID", &invoiceId, &payment.Payment_id, c.GetString("user_id"))
+			if err != nil {
+				restoreInvoice()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		if wasPaid {
+			if err = helpers.ReverseCustomerSpend(ctx, invoice, *payment.Amount); err != nil {
+				log.Println("could not update customer spend", invoiceId, err)
+			}
+			if err = helpers.ReverseEarnedPoints(ctx, invoice); err != nil {
+				log.Println("could not reverse loyalty points", invoiceId, err)
+			}
+		}
+
+		payment.Status = "VOIDED"
+		payment.Void_reason = request.Reason_code
+		payment.Voided_by = &voidedBy
+		_, err = paymentCollection.UpdateOne(ctx, bson.M{"payment_id": paymentId, "status": "VOIDING"}, bson.D{{"$set", bson.D{
+			{"status", payment.Status},
+			{"void_reason", payment.Void_reason},
+			{"voided_by", payment.Voided_by},
+			{"updated_at", payment.Updated_at},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"payment": payment, "invoice": invoice})
+	}
+}
+
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		payload, err := c.GetRawData()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		event, err := helpers.PaymentGateway.VerifyWebhook(payload, c.GetHeader("X-Signature"))
+		if errors.Is(err, helpers.ErrWebhookSecretMissing) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = paymentCollection.UpdateOne(ctx, bson.M{"provider_transaction_id": event.Transaction_id}, bson.D{{"$set", bson.D{
+			{"provider_status", event.Type},
+			{"updated_at", updatedAt},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true})
+	}
+}
+
+// chargeCard authorizes and captures applied on the configured gateway.
+// Card payments have to carry the client's idempotency key, so a request
+// retried after a timeout reuses the original authorization instead of
+// charging the card again.
+func chargeCard(ctx context.Context, payment *models.Payment, applied models.Money) (int, string) {
+	if payment.Card_token == nil || *payment.Card_token == "" {
+		return http.StatusBadRequest, "card_token is required for card payments"
+	}
+	if payment.Idempotency_key == nil || *payment.Idempotency_key == "" {
+		return http.StatusBadRequest, "an Idempotency-Key header is required for card payments"
+	}
+
+	key := *payment.Idempotency_key
+	provider := helpers.PaymentGateway.Name()
+	payment.Provider = &provider
+
+	authorization, err := helpers.WithProviderRetry(ctx, 3, func(ctx context.Context) (helpers.ProviderResult, error) {
+		return helpers.PaymentGateway.Authorize(ctx, helpers.AuthorizeRequest{
+			Amount:          applied,
+			Card_token:      *payment.Card_token,
+			Idempotency_key: "authorize:" + key,
+		})
+	})
+	if errors.Is(err, helpers.ErrPaymentDeclined) {
+		return http.StatusPaymentRequired, authorization.Decline_reason
+	}
+	if err != nil {
+		return http.StatusBadGateway, err.Error()
+	}
+	payment.Provider_transaction_id = &authorization.Transaction_id
+
+	capture, err := helpers.WithProviderRetry(ctx, 3, func(ctx context.Context) (helpers.ProviderResult, error) {
+		return helpers.PaymentGateway.Capture(ctx, authorization.Transaction_id, applied, "capture:"+key)
+	})
+	if err != nil {
+		voidCard(ctx, payment)
+		if errors.Is(err, helpers.ErrPaymentDeclined) {
+			return http.StatusPaymentRequired, capture.Decline_reason
+		}
+		return http.StatusBadGateway, err.Error()
+	}
+	payment.Provider_status = &capture.Status
+	return http.StatusOK, ""
+}
+
+func voidCard(ctx context.Context, payment *models.Payment) {
+	if payment.Provider_transaction_id == nil {
+		return
+	}
+	_, err := helpers.WithProviderRetry(ctx, 3, func(ctx context.Context) (helpers.ProviderResult, error) {
+		return helpers.PaymentGateway.Void(ctx, *payment.Provider_transaction_id, "void:"+payment.Payment_id)
+	})
+	if err != nil {
+		log.Println("could not void card payment", payment.Payment_id, err)
+	}
+}
+
+// chargeGiftCard takes applied off the gift card named by the payment.
+func chargeGiftCard(ctx context.Context, payment *models.Payment, applied models.Money, invoiceId string, userId string) (int, string) {
+	if payment.Gift_card_code == nil || *payment.Gift_card_code == "" {
+		return http.StatusBadRequest, "gift_card_code is required for gift card payments"
+	}
+
+	card, err := helpers.DebitGiftCard(ctx, *payment.Gift_card_code, applied, invoiceId, payment.Payment_id, userId)
+	switch {
+	case errors.Is(err, helpers.ErrGiftCardNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, helpers.ErrGiftCardInsufficient):
+		return http.StatusPaymentRequired, err.Error()
+	case errors.Is(err, helpers.ErrGiftCardInactive), errors.Is(err, helpers.ErrGiftCardExpired):
+		return http.StatusConflict, err.Error()
+	case err != nil:
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	reference := "gift card ending " + card.Code[len(card.Code)-4:]
+	payment.Gift_card_id = &card.Gift_card_id
+	payment.Reference = &reference
+	return http.StatusOK, ""
+}
+
+// reverseTender gives back whatever a payment took when it could not be
+// recorded against the invoice.
+func reverseTender(ctx context.Context, payment *models.Payment, userId string) {
+	voidCard(ctx, payment)
+	if payment.Gift_card_id != nil {
+		_, err := helpers.CreditGiftCard(ctx, *payment.Gift_card_id, *payment.Amount, "VOID", &payment.Invoice_id, &payment.Payment_id, userId)
+		if err != nil {
+			log.Println("could not return gift card payment", payment.Payment_id, err)
+		}
+	}
+}
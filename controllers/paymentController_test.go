@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// usePaymentTestDatabase points the payment controllers at a scratch
+// database on the server named by MONGODB_TEST_URL and at a fresh fake
+// provider. The tests are skipped when no test server is configured.
+func usePaymentTestDatabase(t *testing.T) context.Context {
+	url := os.Getenv("MONGODB_TEST_URL")
+	if url == "" {
+		t.Skip("MONGODB_TEST_URL is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("restaurant_test_" + primitive.NewObjectID().Hex())
+
+	invoices, payments, refunds, gateway := invoiceCollection, paymentCollection, refundCollection, helpers.PaymentGateway
+	invoiceCollection = db.Collection("invoice")
+	paymentCollection = db.Collection("payment")
+	refundCollection = db.Collection("refund")
+	helpers.PaymentGateway = helpers.NewFakePaymentProvider("secret")
+	t.Cleanup(func() {
+		invoiceCollection, paymentCollection, refundCollection, helpers.PaymentGateway = invoices, payments, refunds, gateway
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return ctx
+}
+
+func insertTestInvoice(t *testing.T, ctx context.Context, total int64) models.Invoice {
+	amount := models.NewMoney(total, "USD")
+	invoice := models.Invoice{ID: primitive.NewObjectID(), Total: &amount}
+	invoice.Invoice_id = invoice.ID.Hex()
+	helpers.SettleInvoice(&invoice)
+	if _, err := invoiceCollection.InsertOne(ctx, invoice); err != nil {
+		t.Fatal(err)
+	}
+	return invoice
+}
+
+func cardPayment(amount int64, key string) models.Payment {
+	tender := "CARD"
+	token := "tok_visa"
+	money := models.NewMoney(amount, "USD")
+	return models.Payment{Tender: &tender, Card_token: &token, Amount: &money, Idempotency_key: &key}
+}
+
+func loadInvoice(t *testing.T, ctx context.Context, invoiceId string) models.Invoice {
+	var invoice models.Invoice
+	if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		t.Fatal(err)
+	}
+	return invoice
+}
+
+func paymentRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/invoices/:invoice_id/payments", CreatePayment())
+	router.POST("/invoices/:invoice_id/refunds", CreateRefund())
+	return router
+}
+
+func postJSON(router *gin.Engine, path string, body interface{}, header http.Header) *httptest.ResponseRecorder {
+	payload, _ := json.Marshal(body)
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(payload))
+	request.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestRecordPaymentComparesAmountPaid(t *testing.T) {
+	ctx := usePaymentTestDatabase(t)
+	stored := insertTestInvoice(t, ctx, 10000)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 5)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			invoice := stored
+			payment := cardPayment(6000, "till-"+strconv.Itoa(i))
+			statuses[i], _ = recordPayment(ctx, &invoice, &payment, "user")
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, status := range statuses {
+		if status == http.StatusOK {
+			succeeded++
+		} else if status != http.StatusConflict {
+			t.Errorf("unexpected status %d", status)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent payments went through, want 1", succeeded)
+	}
+
+	invoice := loadInvoice(t, ctx, stored.Invoice_id)
+	if invoice.Amount_paid.Amount != 6000 || *invoice.Payment_status != "PARTIALLY_PAID" {
+		t.Fatalf("invoice paid %d with status %s", invoice.Amount_paid.Amount, *invoice.Payment_status)
+	}
+	count, _ := paymentCollection.CountDocuments(ctx, bson.M{"invoice_id": stored.Invoice_id})
+	if count != 1 {
+		t.Fatalf("%d payments stored, want 1", count)
+	}
+}
+
+func TestCreatePaymentReplaysIdempotencyKey(t *testing.T) {
+	ctx := usePaymentTestDatabase(t)
+	invoice := insertTestInvoice(t, ctx, 10000)
+	router := paymentRouter()
+	header := http.Header{"Idempotency-Key": {"till-1-0001"}}
+
+	var paymentIds []string
+	for i := 0; i < 2; i++ {
+		payment := cardPayment(4000, "")
+		payment.Idempotency_key = nil
+		recorder := postJSON(router, "/invoices/"+invoice.Invoice_id+"/payments", payment, header)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("attempt %d: %d %s", i, recorder.Code, recorder.Body)
+		}
+		var response struct {
+			Payment models.Payment `json:"payment"`
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		paymentIds = append(paymentIds, response.Payment.Payment_id)
+	}
+	if paymentIds[0] == "" || paymentIds[0] != paymentIds[1] {
+		t.Fatalf("replay returned payment %s after %s", paymentIds[1], paymentIds[0])
+	}
+	if paid := loadInvoice(t, ctx, invoice.Invoice_id).Amount_paid.Amount; paid != 4000 {
+		t.Fatalf("invoice paid %d after a replayed payment, want 4000", paid)
+	}
+}
+
+func TestConcurrentPaymentsClaimIdempotencyKey(t *testing.T) {
+	ctx := usePaymentTestDatabase(t)
+	if _, err := paymentCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"invoice_id", 1}, {"idempotency_key", 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	stored := insertTestInvoice(t, ctx, 10000)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 5)
+	payments := make([]models.Payment, 5)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			invoice := stored
+			payments[i] = cardPayment(4000, "till-1-0001")
+			statuses[i], _ = recordPayment(ctx, &invoice, &payments[i], "user")
+		}(i)
+	}
+	wg.Wait()
+
+	var paymentId string
+	for i, status := range statuses {
+		if status == http.StatusConflict {
+			continue
+		}
+		if status != http.StatusOK {
+			t.Fatalf("unexpected status %d", status)
+		}
+		if paymentId != "" && payments[i].Payment_id != paymentId {
+			t.Fatalf("one key recorded payments %s and %s", paymentId, payments[i].Payment_id)
+		}
+		paymentId = payments[i].Payment_id
+	}
+
+	var payment models.Payment
+	if err := paymentCollection.FindOne(ctx, bson.M{"invoice_id": stored.Invoice_id}).Decode(&payment); err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != "CAPTURED" || payment.Payment_id != paymentId {
+		t.Fatalf("stored payment %s is %s", payment.Payment_id, payment.Status)
+	}
+	if _, err := helpers.PaymentGateway.Refund(ctx, *payment.Provider_transaction_id, *payment.Amount, "refund:check"); err != nil {
+		t.Fatalf("recorded card transaction is not live: %v", err)
+	}
+	if paid := loadInvoice(t, ctx, stored.Invoice_id).Amount_paid.Amount; paid != 4000 {
+		t.Fatalf("invoice paid %d, want 4000", paid)
+	}
+}
+
+func TestPaymentsAndRefundsSettleInvoice(t *testing.T) {
+	ctx := usePaymentTestDatabase(t)
+	invoice := insertTestInvoice(t, ctx, 10000)
+	router := paymentRouter()
+
+	var paymentIds []string
+	for _, step := range []struct {
+		amount int64
+		status string
+	}{{4000, "PARTIALLY_PAID"}, {6000, "PAID"}} {
+		recorder := postJSON(router, "/invoices/"+invoice.Invoice_id+"/payments", cardPayment(step.amount, "pay-"+step.status), nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("payment of %d: %d %s", step.amount, recorder.Code, recorder.Body)
+		}
+		var response struct {
+			Payment models.Payment `json:"payment"`
+		}
+		json.Unmarshal(recorder.Body.Bytes(), &response)
+		paymentIds = append(paymentIds, response.Payment.Payment_id)
+		if status := *loadInvoice(t, ctx, invoice.Invoice_id).Payment_status; status != step.status {
+			t.Fatalf("after paying %d: status %s, want %s", step.amount, status, step.status)
+		}
+	}
+
+	for i, amount := range []int64{4000, 6000} {
+		recorder := postJSON(router, "/invoices/"+invoice.Invoice_id+"/refunds", gin.H{
+			"payment_id":  paymentIds[i],
+			"amount":      gin.H{"amount": amount, "currency": "USD"},
+			"reason_code": "OTHER",
+		}, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("refund of %d: %d %s", amount, recorder.Code, recorder.Body)
+		}
+	}
+	if status := *loadInvoice(t, ctx, invoice.Invoice_id).Payment_status; status != "REFUNDED" {
+		t.Fatalf("after refunding everything: status %s, want REFUNDED", status)
+	}
+}
+
+func TestCreateRefundComparesRefundedAmount(t *testing.T) {
+	ctx := usePaymentTestDatabase(t)
+	invoice := insertTestInvoice(t, ctx, 10000)
+	payment := cardPayment(10000, "pay-all")
+	if status, msg := recordPayment(ctx, &invoice, &payment, "user"); msg != "" {
+		t.Fatalf("payment: %d %s", status, msg)
+	}
+	router := paymentRouter()
+
+	var wg sync.WaitGroup
+	codes := make([]int, 5)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = postJSON(router, "/invoices/"+invoice.Invoice_id+"/refunds", gin.H{
+				"payment_id":  payment.Payment_id,
+				"amount":      gin.H{"amount": 6000, "currency": "USD"},
+				"reason_code": "OTHER",
+			}, nil).Code
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			succeeded++
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("%d concurrent refunds went through, want 1 (%v)", succeeded, codes)
+	}
+
+	var stored models.Payment
+	if err := paymentCollection.FindOne(ctx, bson.M{"payment_id": payment.Payment_id}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Refunded_amount.Amount != 6000 {
+		t.Fatalf("payment refunded %d, want 6000", stored.Refunded_amount.Amount)
+	}
+}
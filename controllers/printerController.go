@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var printerCollection *mongo.Collection = database.OpenCollection(database.Client, "printer")
+var printJobCollection *mongo.Collection = database.OpenCollection(database.Client, "printjob")
+
+func GetPrinters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := printerCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allPrinters []bson.M
+		if err = result.All(ctx, &allPrinters); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allPrinters)
+	}
+}
+
+func CreatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var printer models.Printer
+
+		if err := c.BindJSON(&printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(printer)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if err := helpers.CheckPrinter(ctx, printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		printer.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		printer.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		printer.ID = primitive.NewObjectID()
+		printer.Printer_id = printer.ID.Hex()
+
+		result, insertErr := printerCollection.InsertOne(ctx, printer)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdatePrinter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var printer models.Printer
+		printerId := c.Param("printer_id")
+
+		if err := c.BindJSON(&printer); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.Printer
+		err := printerCollection.FindOne(ctx, bson.M{"printer_id": printerId}).Decode(&existing)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "printer not found"})
+			return
+		}
+		if printer.Address != nil {
+			existing.Address = printer.Address
+		}
+		if printer.File_path != nil {
+			existing.File_path = printer.File_path
+		}
+		if err = helpers.CheckPrinter(ctx, existing); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if printer.Name != nil {
+			updateObj = append(updateObj, bson.E{"name", printer.Name})
+		}
+
+		if printer.Address != nil {
+			updateObj = append(updateObj, bson.E{"address", printer.Address})
+		}
+
+		if printer.File_path != nil {
+			updateObj = append(updateObj, bson.E{"file_path", printer.File_path})
+		}
+
+		if printer.Categories != nil {
+			updateObj = append(updateObj, bson.E{"categories", printer.Categories})
+		}
+
+		if printer.Width != nil {
+			updateObj = append(updateObj, bson.E{"width", printer.Width})
+		}
+
+		printer.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", printer.Updated_at})
+
+		result, err := printerCollection.UpdateOne(
+			ctx,
+			bson.M{"printer_id": printerId},
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func PrintKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+
+		jobs, err := printKitchenTickets(ctx, orderId, nil, c.GetString("first_name"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
+func PrintReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		invoiceId := c.Param("invoice_id")
+
+		receipt, err := loadReceipt(ctx, invoiceId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+
+		filter := bson.M{"role": "RECEIPT"}
+		if printerId := c.Query("printer_id"); printerId != "" {
+			filter = bson.M{"printer_id": printerId}
+		}
+
+		var printer models.Printer
+		if err = printerCollection.FindOne(ctx, filter).Decode(&printer); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no receipt printer configured"})
+			return
+		}
+
+		job, err := helpers.EnqueuePrintJob(ctx, printer.Printer_id, "RECEIPT", invoiceId, helpers.ReceiptEscPos(receipt, helpers.PrinterWidth(printer)))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+func GetPrintJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if printerId := c.Query("printer_id"); printerId != "" {
+			filter["printer_id"] = printerId
+		}
+
+		result, err := printJobCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allJobs []models.PrintJob
+		if err = result.All(ctx, &allJobs); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allJobs)
+	}
+}
+
+func RetryPrintJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		printJobId := c.Param("print_job_id")
+		now := time.Now()
+
+		result, err := printJobCollection.UpdateOne(
+			ctx,
+			bson.M{"print_job_id": printJobId, "status": "FAILED"},
+			bson.D{{"$set", bson.D{{"status", "QUEUED"}, {"attempts", 0}, {"next_attempt_at", now}, {"updated_at", now}}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no failed print job with that id"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// printKitchenTickets queues one ticket per kitchen printer holding the
+// order items routed to it. When orderItemIds is set only those items are
+// printed, so later additions to an order do not reprint earlier items.
+func printKitchenTickets(ctx context.Context, orderId string, orderItemIds []string, server string) ([]models.PrintJob, error) {
+	items, err := helpers.KitchenItems(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	if orderItemIds != nil {
+		wanted := map[string]bool{}
+		for _, id := range orderItemIds {
+			wanted[id] = true
+		}
+		filtered := []helpers.KitchenItem{}
+		for _, item := range items {
+			if wanted[item.Order_item_id] {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	var printers []models.Printer
+	cursor, err := printerCollection.Find(ctx, bson.M{"role": "KITCHEN"})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &printers); err != nil {
+		return nil, err
+	}
+
+	ticket := helpers.KitchenTicket{Order_id: orderId, Server: server, Printed_at: time.Now()}
+	var order models.Order
+	var table models.Table
+	if err = orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err == nil && order.Table_id != nil {
+		if err = tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil {
+			ticket.Table_number = table.Table_number
+		}
+	}
+	if order.Customer_id != nil {
+		var customer models.Customer
+		if err = customerCollection.FindOne(ctx, bson.M{"customer_id": order.Customer_id}).Decode(&customer); err == nil {
+			ticket.Allergies = customer.Allergies
+		}
+	}
+
+	order.Order_id = orderId
+	itemIds := []string{}
+	for _, item := range items {
+		itemIds = append(itemIds, item.Order_item_id)
+	}
+	ticketNotes, itemNotes, err := helpers.KitchenNotes(ctx, order, itemIds)
+	if err != nil {
+		return nil, err
+	}
+	ticket.Notes = ticketNotes
+	for i := range items {
+		items[i].Notes = itemNotes[items[i].Order_item_id]
+	}
+
+	stations, err := helpers.StationsById(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	routed := map[string][]helpers.KitchenItem{}
+	byId := map[string]models.Printer{}
+	printerOrder := []string{}
+	for _, item := range items {
+		targets := helpers.KitchenPrinters(printers, item.Category)
+		if station, ok := stations[item.Station_id]; ok && len(station.Printer_ids) > 0 {
+			targets = helpers.StationPrinters(printers, station)
+		}
+		for _, printer := range targets {
+			if _, ok := routed[printer.Printer_id]; !ok {
+				printerOrder = append(printerOrder, printer.Printer_id)
+				byId[printer.Printer_id] = printer
+			}
+			routed[printer.Printer_id] = append(routed[printer.Printer_id], item)
+		}
+	}
+
+	jobs := []models.PrintJob{}
+	for _, printerId := range printerOrder {
+		ticket.Items = routed[printerId]
+		payload := helpers.KitchenTicketEscPos(ticket, helpers.PrinterWidth(byId[printerId]))
+		job, err := helpers.EnqueuePrintJob(ctx, printerId, "KITCHEN_TICKET", orderId, payload)
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
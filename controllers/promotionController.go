@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var promotionCollection *mongo.Collection = database.OpenCollection(database.Client, "promotion")
+
+func GetPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := promotionCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allPromotions []models.Promotion
+		if err = result.All(ctx, &allPromotions); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allPromotions)
+	}
+}
+
+func GetPromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+
+		promotionId := c.Param("promotion_id")
+
+		err := promotionCollection.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&promotion)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+			return
+		}
+		c.JSON(http.StatusOK, promotion)
+	}
+}
+
+func CreatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(promotion)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if msg := checkPromotion(promotion); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if promotion.Coupon_code != nil {
+			code := strings.ToUpper(*promotion.Coupon_code)
+			promotion.Coupon_code = &code
+			count, err := promotionCollection.CountDocuments(ctx, bson.M{"coupon_code": code})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if count > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "this coupon code already exists"})
+				return
+			}
+		}
+
+		if promotion.Amount != nil {
+			amount := models.NewMoney(promotion.Amount.Amount, promotion.Amount.Currency)
+			promotion.Amount = &amount
+		}
+
+		promotion.Usage_count = 0
+		promotion.Redeemed_order_ids = []string{}
+		promotion.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promotion.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		promotion.ID = primitive.NewObjectID()
+		promotion.Promotion_id = promotion.ID.Hex()
+
+		result, insertErr := promotionCollection.InsertOne(ctx, promotion)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdatePromotion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var promotion models.Promotion
+		promotionId := c.Param("promotion_id")
+
+		if err := c.BindJSON(&promotion); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var existing models.Promotion
+		if err := promotionCollection.FindOne(ctx, bson.M{"promotion_id": promotionId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "promotion not found"})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if promotion.Name != nil {
+			existing.Name = promotion.Name
+			updateObj = append(updateObj, bson.E{"name", promotion.Name})
+		}
+
+		if promotion.Percent_bps != nil {
+			existing.Percent_bps = promotion.Percent_bps
+			updateObj = append(updateObj, bson.E{"percent_bps", promotion.Percent_bps})
+		}
+
+		if promotion.Amount != nil {
+			amount := models.NewMoney(promotion.Amount.Amount, promotion.Amount.Currency)
+			existing.Amount = &amount
+			updateObj = append(updateObj, bson.E{"amount", amount})
+		}
+
+		if promotion.Buy_quantity != nil {
+			existing.Buy_quantity = promotion.Buy_quantity
+			updateObj = append(updateObj, bson.E{"buy_quantity", promotion.Buy_quantity})
+		}
+
+		if promotion.Get_quantity != nil {
+			existing.Get_quantity = promotion.Get_quantity
+			updateObj = append(updateObj, bson.E{"get_quantity", promotion.Get_quantity})
+		}
+
+		if promotion.Food_ids != nil {
+			updateObj = append(updateObj, bson.E{"food_ids", promotion.Food_ids})
+		}
+
+		if promotion.Categories != nil {
+			updateObj = append(updateObj, bson.E{"categories", promotion.Categories})
+		}
+
+		if promotion.Min_subtotal != nil {
+			updateObj = append(updateObj, bson.E{"min_subtotal", promotion.Min_subtotal})
+		}
+
+		if promotion.Days != nil {
+			existing.Days = promotion.Days
+			updateObj = append(updateObj, bson.E{"days", promotion.Days})
+		}
+
+		if promotion.Start_time != nil {
+			existing.Start_time = promotion.Start_time
+			updateObj = append(updateObj, bson.E{"start_time", promotion.Start_time})
+		}
+
+		if promotion.End_time != nil {
+			existing.End_time = promotion.End_time
+			updateObj = append(updateObj, bson.E{"end_time", promotion.End_time})
+		}
+
+		if promotion.Starts_at != nil {
+			existing.Starts_at = promotion.Starts_at
+			updateObj = append(updateObj, bson.E{"starts_at", promotion.Starts_at})
+		}
+
+		if promotion.Ends_at != nil {
+			existing.Ends_at = promotion.Ends_at
+			updateObj = append(updateObj, bson.E{"ends_at", promotion.Ends_at})
+		}
+
+		if promotion.Usage_limit != nil {
+			existing.Usage_limit = promotion.Usage_limit
+			updateObj = append(updateObj, bson.E{"usage_limit", promotion.Usage_limit})
+		}
+
+		if promotion.Stackable != nil {
+			updateObj = append(updateObj, bson.E{"stackable", promotion.Stackable})
+		}
+
+		if promotion.Priority != nil {
+			updateObj = append(updateObj, bson.E{"priority", promotion.Priority})
+		}
+
+		if promotion.Active != nil {
+			updateObj = append(updateObj, bson.E{"active", promotion.Active})
+		}
+
+		validationErr := validate.Struct(existing)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if msg := checkPromotion(existing); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		promotion.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", promotion.Updated_at})
+
+		result, err := promotionCollection.UpdateOne(
+			ctx,
+			bson.M{"promotion_id": promotionId},
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// PreviewPromotions prices an order with the promotions it would get right
+// now, optionally with coupon codes, without raising an invoice.
+func PreviewPromotions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+		invoice := models.Invoice{Order_id: &orderId, Coupon_codes: normalizeCoupons(c.QueryArray("coupon"))}
+
+		if err := helpers.CheckCoupons(ctx, invoice.Coupon_codes, orderId, time.Now()); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := helpers.BuildInvoice(ctx, &invoice); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"line_items":     invoice.Line_items,
+			"promotions":     invoice.Promotions,
+			"subtotal":       invoice.Subtotal,
+			"discount_total": invoice.Discount_total,
+			"total":          invoice.Total,
+		})
+	}
+}
+
+func checkPromotion(promotion models.Promotion) string {
+	switch *promotion.Type {
+	case "PERCENT_ITEM", "PERCENT_ORDER":
+		if promotion.Percent_bps == nil {
+			return "percentage promotions need percent_bps"
+		}
+	case "FIXED_ITEM", "FIXED_ORDER":
+		if promotion.Amount == nil || promotion.Amount.Amount <= 0 {
+			return "fixed promotions need a positive amount"
+		}
+	case "BUY_X_GET_Y":
+		if promotion.Buy_quantity == nil || promotion.Get_quantity == nil {
+			return "buy x get y promotions need buy_quantity and get_quantity"
+		}
+	}
+	if (promotion.Start_time == nil) != (promotion.End_time == nil) {
+		return "a time window needs both start_time and end_time"
+	}
+	if promotion.Start_time != nil {
+		if _, err := helpers.ClockMinutes(*promotion.Start_time); err != nil {
+			return "start_time must be HH:MM"
+		}
+		if _, err := helpers.ClockMinutes(*promotion.End_time); err != nil {
+			return "end_time must be HH:MM"
+		}
+	}
+	if promotion.Starts_at != nil && promotion.Ends_at != nil && !promotion.Ends_at.After(*promotion.Starts_at) {
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type voidRequest struct {
+	Reason_code   *string `json:"reason_code" validate:"required,eq=CUSTOMER_COMPLAINT|eq=OVERCHARGE|eq=WRONG_ITEM|eq=QUALITY|eq=CANCELLED_ORDER|eq=DUPLICATE_PAYMENT|eq=OTHER"`
+	Note          *string `json:"note"`
+	Manager_token *string `json:"manager_token"`
+}
+
+var refundCollection *mongo.Collection = database.OpenCollection(database.Client, "refund")
+
+func GetRefunds() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"status": bson.M{"$ne": "FAILED"}}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if invoiceId := c.Param("invoice_id"); invoiceId != "" {
+			filter["invoice_id"] = invoiceId
+		}
+
+		createdAt := bson.M{}
+		if from, err := time.Parse(time.RFC3339, c.Query("from")); err == nil {
+			createdAt["$gte"] = from
+		}
+		if to, err := time.Parse(time.RFC3339, c.Query("to")); err == nil {
+			createdAt["$lt"] = to
+		}
+		if len(createdAt) > 0 {
+			filter["created_at"] = createdAt
+		}
+
+		result, err := refundCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allRefunds []models.Refund
+		if err = result.All(ctx, &allRefunds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		total := models.Money{}
+		byReason := map[string]models.Money{}
+		for _, refund := range allRefunds {
+			total = total.Add(*refund.Amount)
+			byReason[*refund.Reason_code] = byReason[*refund.Reason_code].Add(*refund.Amount)
+		}
+		c.JSON(http.StatusOK, gin.H{"refunds": allRefunds, "total": total, "by_reason": byReason})
+	}
+}
+
+func CreateRefund() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var refund models.Refund
+		var payment models.Payment
+
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&refund); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(refund)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := paymentCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId, "payment_id": refund.Payment_id}).Decode(&payment)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "payment not found"})
+			return
+		}
+		if payment.Status != "CAPTURED" {
+			c.JSON(http.StatusConflict, gin.H{"error": "only captured payments can be refunded"})
+			return
+		}
+
+		amount := models.NewMoney(refund.Amount.Amount, refund.Amount.Currency)
+		if amount.Currency != payment.Amount.Currency || amount.Amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refund amount must be positive and in the payment currency"})
+			return
+		}
+
+		previousRefunded := payment.Refunded_amount
+		refunded := models.NewMoney(0, amount.Currency)
+		if previousRefunded != nil {
+			refunded = *previousRefunded
+		}
+		if refunded.Add(amount).Amount > payment.Amount.Amount {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refund exceeds the refundable amount of the payment"})
+			return
+		}
+
+		refund.Tender = payment.Tender
+		if *payment.Tender == "CASH" {
+			shift := helpers.OpenShiftFor(ctx, c.GetString("user_id"))
+			if shift == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "open a shift before paying out a cash refund"})
+				return
+			}
+			refund.Shift_id = &shift.Shift_id
+		}
+
+		alreadyRefunded, err := helpers.InvoiceRefundTotal(ctx, invoiceId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if helpers.RefundNeedsApproval(ctx, alreadyRefunded.Add(amount)) {
+			approvedBy, msg := helpers.ManagerApproval(ctx, refund.Manager_token)
+			if msg != "" {
+				c.JSON(http.StatusForbidden, gin.H{"error": msg})
+				return
+			}
+			refund.Approved_by = &approvedBy
+		}
+
+		refund.ID = primitive.NewObjectID()
+		refund.Refund_id = refund.ID.Hex()
+		refund.Invoice_id = invoiceId
+		refund.Amount = &amount
+		refund.Status = "PENDING"
+		refund.Requested_by = c.GetString("user_id")
+		refund.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		refund.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		// Record the refund before any money moves so a crash part way
+		// through leaves a PENDING refund to reconcile rather than nothing.
+		if _, err = refundCollection.InsertOne(ctx, refund); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		newRefunded := refunded.Add(amount)
+		result, err := paymentCollection.UpdateOne(ctx, bson.M{"payment_id": payment.Payment_id, "status": "CAPTURED", "refunded_amount": previousRefunded}, bson.D{{"$set", bson.D{
+			{"refunded_amount", newRefunded},
+			{"updated_at", refund.Updated_at},
+		}}})
+		if err != nil {
+			failRefund(ctx, &refund)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			failRefund(ctx, &refund)
+			c.JSON(http.StatusConflict, gin.H{"error": "payment was refunded concurrently, please retry"})
+			return
+		}
+		restorePayment := func() {
+			_, err := paymentCollection.UpdateOne(ctx, bson.M{"payment_id": payment.Payment_id, "refunded_amount": newRefunded}, bson.D{{"$set", bson.D{{"refunded_amount", previousRefunded}}}})
+			if err != nil {
+				log.Println("could not restore refunded amount", payment.Payment_id, err)
+			}
+			failRefund(ctx, &refund)
+		}
+
+		if payment.Provider_transaction_id != nil {
+			key := refund.Refund_id
+			if header := c.GetHeader("Idempotency-Key"); header != "" {
+				key = header
+			}
+			providerResult, err := helpers.WithProviderRetry(ctx, 3, func(ctx context.Context) (helpers.ProviderResult, error) {
+				return helpers.PaymentGateway.Refund(ctx, *payment.Provider_transaction_id, amount, "refund:"+key)
+			})
+			if err != nil {
+				restorePayment()
+				if errors.Is(err, helpers.ErrPaymentDeclined) {
+					c.JSON(http.StatusPaymentRequired, gin.H{"error": providerResult.Decline_reason})
+					return
+				}
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+			refund.Provider_transaction_id = &providerResult.Transaction_id
+		}
+
+		if payment.Gift_card_id != nil {
+			_, err = helpers.CreditGiftCard(ctx, *payment.Gift_card_id, amount, "REFUND", &invoiceId, &payment.Payment_id, refund.Requested_by)
+			if err != nil {
+				restorePayment()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		approvedBy := ""
+		if refund.Approved_by != nil {
+			approvedBy = *refund.Approved_by
+		}
+		adjustment := models.InvoiceAdjustment{
+			Type:        "REFUND",
+			Reason_code: *refund.Reason_code,
+			Amount:      amount.Neg(),
+			Refund_id:   refund.Refund_id,
+			Approved_by: approvedBy,
+			Created_at:  refund.Created_at,
+		}
+		invoice, err := adjustInvoice(ctx, invoiceId, amount, adjustment)
+		if err != nil {
+			// The money has already gone back, so the refund stays PENDING
+			// for the invoice to be reconciled.
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err = helpers.RecordCustomerRefund(ctx, invoice, amount); err != nil {
+			log.Println("could not record customer refund", invoiceId, err)
+		}
+		if *invoice.Payment_status == "REFUNDED" {
+			if err = helpers.ReturnLoyaltyPoints(ctx, invoice); err != nil {
+				log.Println("could not return loyalty points", invoiceId, err)
+			}
+		}
+
+		refund.Status = "COMPLETED"
+		refund.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = refundCollection.UpdateOne(ctx, bson.M{"refund_id": refund.Refund_id}, bson.D{{"$set", bson.D{
+			{"status", refund.Status},
+			{"provider_transaction_id", refund.Provider_transaction_id},
+			{"updated_at", refund.Updated_at},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"refund": refund, "invoice": invoice})
+	}
+}
+
+// failRefund marks a refund that was given up on before any money moved.
+func failRefund(ctx context.Context, refund *models.Refund) {
+	refund.Status = "FAILED"
+	refund.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := refundCollection.UpdateOne(ctx, bson.M{"refund_id": refund.Refund_id}, bson.D{{"$set", bson.D{
+		{"status", refund.Status},
+		{"updated_at", refund.Updated_at},
+	}}})
+	if err != nil {
+		log.Println("could not mark refund failed", refund.Refund_id, err)
+	}
+}
+
+func VoidInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request voidRequest
+		var invoice models.Invoice
+
+		invoiceId := c.Param("invoice_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		approvedBy, msg := helpers.ManagerApproval(ctx, request.Manager_token)
+		if msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invoice not found"})
+			return
+		}
+		if invoice.Payment_status != nil && *invoice.Payment_status == "VOID" {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice is already void"})
+			return
+		}
+		if helpers.InvoiceLocked(invoice) {
+			c.JSON(http.StatusConflict, gin.H{"error": "invoice belongs to a closed business day"})
+			return
+		}
+
+		netPaid := models.Money{}
+		if invoice.Amount_paid != nil {
+			netPaid = netPaid.Add(*invoice.Amount_paid)
+		}
+		if invoice.Amount_refunded != nil {
+			netPaid = netPaid.Sub(*invoice.Amount_refunded)
+		}
+		if netPaid.Amount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "void or refund the invoice payments first"})
+			return
+		}
+
+		status := "VOID"
+		invoice.Payment_status = &status
+		invoice.Void_reason = request.Reason_code
+		invoice.Voided_by = &approvedBy
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		helpers.SettleInvoice(&invoice)
+
+		adjustment := models.InvoiceAdjustment{
+			Type:        "VOID",
+			Reason_code: *request.Reason_code,
+			Approved_by: approvedBy,
+			Created_at:  invoice.Updated_at,
+		}
+		if invoice.Total != nil {
+			adjustment.Amount = invoice.Total.Neg()
+		}
+
+		_, err = invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoiceId}, bson.D{
+			{"$set", bson.D{
+				{"payment_status", invoice.Payment_status},
+				{"void_reason", invoice.Void_reason},
+				{"voided_by", invoice.Voided_by},
+				{"balance_due", invoice.Balance_due},
+				{"updated_at", invoice.Updated_at},
+			}},
+			{"$push", bson.D{{"adjustments", adjustment}}},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		invoice.Adjustments = append(invoice.Adjustments, adjustment)
+
+		if err = helpers.ReturnLoyaltyPoints(ctx, invoice); err != nil {
+			log.Println("could not return loyalty points", invoiceId, err)
+		}
+
+		if invoice.Order_id != nil {
+			live, err := invoiceCollection.CountDocuments(ctx, bson.M{"order_id": invoice.Order_id, "payment_status": bson.M{"$ne": "VOID"}})
+			if err == nil && live == 0 {
+				helpers.ReleaseCoupons(ctx, *invoice.Order_id)
+			}
+		}
+		c.JSON(http.StatusOK, invoice)
+	}
+}
+
+// adjustInvoice records a refund against an invoice, retrying when another
+// refund on the same invoice lands between the read and the write.
+func adjustInvoice(ctx context.Context, invoiceId string, amount models.Money, adjustment models.InvoiceAdjustment) (models.Invoice, error) {
+	var invoice models.Invoice
+	for attempt := 0; attempt < 5; attempt++ {
+		if err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			return invoice, err
+		}
+
+		previousRefunded := invoice.Amount_refunded
+		refunded := amount
+		if previousRefunded != nil {
+			refunded = previousRefunded.Add(amount)
+		}
+		invoice.Amount_refunded = &refunded
+		helpers.SettleInvoice(&invoice)
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		result, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoiceId, "amount_refunded": previousRefunded}, bson.D{
+			{"$set", bson.D{
+				{"amount_refunded", invoice.Amount_refunded},
+				{"balance_due", invoice.Balance_due},
+				{"payment_status", invoice.Payment_status},
+				{"updated_at", invoice.Updated_at},
+			}},
+			{"$push", bson.D{{"adjustments", adjustment}}},
+		})
+		if err != nil {
+			return invoice, err
+		}
+		if result.MatchedCount == 1 {
+			invoice.Adjustments = append(invoice.Adjustments, adjustment)
+			return invoice, nil
+		}
+	}
+	return invoice, errors.New("invoice kept changing, please retry")
+}
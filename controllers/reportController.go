@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type closeDayRequest struct {
+	Business_date *string `json:"business_date"`
+	Location      *string `json:"location"`
+	Manager_token *string `json:"manager_token"`
+}
+
+var zReportCollection *mongo.Collection = database.OpenCollection(database.Client, "zreport")
+
+func GetZReports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		businessDate := bson.M{}
+		if from := c.Query("from"); from != "" {
+			businessDate["$gte"] = from
+		}
+		if to := c.Query("to"); to != "" {
+			businessDate["$lte"] = to
+		}
+		if len(businessDate) > 0 {
+			filter["business_date"] = businessDate
+		}
+
+		result, err := zReportCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allReports []models.ZReport
+		if err = result.All(ctx, &allReports); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allReports)
+	}
+}
+
+// GetXReport shows the running totals of a business day without closing it.
+func GetXReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		date := c.DefaultQuery("business_date", time.Now().Format(helpers.BusinessDateLayout))
+		location := c.Query("location")
+		if location == "" {
+			location = defaultLocation(ctx)
+		}
+
+		report, _, err := helpers.BuildZReport(ctx, date, location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// GetTicketTimes reports how long each dish took in the kitchen over a
+// business day, or a range of them with from and to.
+func GetTicketTimes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		today := time.Now().Format(helpers.BusinessDateLayout)
+		from, _, err := helpers.BusinessDay(c.DefaultQuery("from", today))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+		_, to, err := helpers.BusinessDay(c.DefaultQuery("to", c.DefaultQuery("from", today)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+
+		times, err := helpers.DishTicketTimes(ctx, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, times)
+	}
+}
+
+// GetVoidCompReport lists the voids and comps made over a range of business
+// days for loss prevention review.
+func GetVoidCompReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		today := time.Now().Format(helpers.BusinessDateLayout)
+		from, _, err := helpers.BusinessDay(c.DefaultQuery("from", today))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+		_, to, err := helpers.BusinessDay(c.DefaultQuery("to", c.DefaultQuery("from", today)))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+
+		report, err := helpers.VoidCompReport(ctx, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// CloseBusinessDay runs the Z report for a day at one location. Every shift
+// there has to be closed and every invoice settled or voided first, and once
+// the report is stored the day's invoices are locked.
+func CloseBusinessDay() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request closeDayRequest
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		approvedBy, msg := helpers.ManagerApproval(ctx, request.Manager_token)
+		if msg != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		date := time.Now().Format(helpers.BusinessDateLayout)
+		if request.Business_date != nil {
+			date = *request.Business_date
+		}
+		location := defaultLocation(ctx)
+		if request.Location != nil {
+			location = *request.Location
+		}
+
+		locationFilter := interface{}(location)
+		if location == "" {
+			locationFilter = nil
+		}
+
+		open, err := shiftCollection.CountDocuments(ctx, bson.M{"status": "OPEN", "location": locationFilter})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if open > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "close every open shift first"})
+			return
+		}
+
+		closed, err := zReportCollection.CountDocuments(ctx, bson.M{"business_date": date, "location": location})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if closed > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the business day is already closed"})
+			return
+		}
+
+		report, invoiceIds, err := helpers.BuildZReport(ctx, date, location)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if report.Open_count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": helpers.ErrInvoicesOpen.Error(), "open_count": report.Open_count})
+			return
+		}
+
+		report.ID = primitive.NewObjectID()
+		report.Z_report_id = report.ID.Hex()
+		report.Closed_by = approvedBy
+		report.Closed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		if _, err = zReportCollection.InsertOne(ctx, report); mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the business day is already closed"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err = invoiceCollection.UpdateMany(ctx, bson.M{"invoice_id": bson.M{"$in": invoiceIds}}, bson.D{{"$set", bson.D{
+			{"z_report_id", report.Z_report_id},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+func defaultLocation(ctx context.Context) string {
+	var setting models.Setting
+	err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting)
+	if err != nil || setting.Location == nil {
+		return ""
+	}
+	return *setting.Location
+}
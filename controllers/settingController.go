@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var settingCollection *mongo.Collection = database.OpenCollection(database.Client, "setting")
+
+func GetSettings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var setting models.Setting
+
+		err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting)
+		if err == mongo.ErrNoDocuments {
+			setting.Setting_id = models.DefaultSettingId
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, setting)
+	}
+}
+
+func UpdateSettings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var setting models.Setting
+
+		if !helpers.IsManager(ctx, c.GetString("user_id")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only a manager or admin can change settings"})
+			return
+		}
+
+		if err := c.BindJSON(&setting); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(setting)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if setting.Service_charge_bps != nil {
+			updateObj = append(updateObj, bson.E{"service_charge_bps", setting.Service_charge_bps})
+		}
+
+		if setting.Service_charge_min_guests != nil {
+			updateObj = append(updateObj, bson.E{"service_charge_min_guests", setting.Service_charge_min_guests})
+		}
+
+		if setting.Refund_approval_threshold != nil {
+			updateObj = append(updateObj, bson.E{"refund_approval_threshold", setting.Refund_approval_threshold})
+		}
+
+		if setting.Invoice_prefix != nil {
+			updateObj = append(updateObj, bson.E{"invoice_prefix", setting.Invoice_prefix})
+		}
+
+		if setting.Branding != nil {
+			if setting.Branding.Accent_color != "" && !helpers.ValidAccentColor(setting.Branding.Accent_color) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "accent_color must be a hex colour such as #c0392b"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"branding", setting.Branding})
+		}
+
+		if setting.Location != nil {
+			updateObj = append(updateObj, bson.E{"location", setting.Location})
+		}
+
+		if setting.Loyalty != nil {
+			updateObj = append(updateObj, bson.E{"loyalty", setting.Loyalty})
+		}
+
+		if setting.Reason_codes != nil {
+			seen := map[string]bool{}
+			for i, reason := range setting.Reason_codes {
+				reason.Code = strings.ToUpper(strings.TrimSpace(reason.Code))
+				if seen[reason.Kind+reason.Code] {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "duplicate reason code " + reason.Code})
+					return
+				}
+				seen[reason.Kind+reason.Code] = true
+				setting.Reason_codes[i] = reason
+			}
+			updateObj = append(updateObj, bson.E{"reason_codes", setting.Reason_codes})
+		}
+
+		setting.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", setting.Updated_at})
+
+		upsert := true
+		filter := bson.M{"setting_id": models.DefaultSettingId}
+		opt := options.UpdateOptions{
+			Upsert: &upsert,
+		}
+
+		result, err := settingCollection.UpdateOne(
+			ctx,
+			filter,
+			bson.D{{"$set", updateObj}, {"$setOnInsert", bson.D{{"_id", primitive.NewObjectID()}}}},
+			&opt,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type closeShiftRequest struct {
+	Counted_cash *models.Money `json:"counted_cash" validate:"required"`
+}
+
+var shiftCollection *mongo.Collection = database.OpenCollection(database.Client, "shift")
+
+func GetShifts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		result, err := shiftCollection.Find(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allShifts []models.Shift
+		if err = result.All(ctx, &allShifts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allShifts)
+	}
+}
+
+func GetShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+
+		shiftId := c.Param("shift_id")
+
+		err := shiftCollection.FindOne(ctx, bson.M{"shift_id": shiftId}).Decode(&shift)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shift not found"})
+			return
+		}
+
+		summary, err := helpers.SummarizeShift(ctx, shift)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"shift": shift, "summary": summary})
+	}
+}
+
+func OpenShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var shift models.Shift
+
+		if err := c.BindJSON(&shift); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(shift)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		openingFloat := models.NewMoney(shift.Opening_float.Amount, shift.Opening_float.Currency)
+		if openingFloat.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "opening float cannot be negative"})
+			return
+		}
+
+		userId := c.GetString("user_id")
+		open, err := shiftCollection.CountDocuments(ctx, bson.M{"status": "OPEN", "$or": bson.A{
+			bson.M{"opened_by": userId},
+			bson.M{"drawer": shift.Drawer},
+		}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if open > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "the drawer or user already has an open shift"})
+			return
+		}
+
+		shift.ID = primitive.NewObjectID()
+		shift.Shift_id = shift.ID.Hex()
+		shift.Opening_float = &openingFloat
+		shift.Cash_movements = []models.CashMovement{}
+		shift.Status = "OPEN"
+		shift.Opened_by = userId
+		if shift.Location == nil {
+			if location := defaultLocation(ctx); location != "" {
+				shift.Location = &location
+			}
+		}
+		shift.Opened_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		shift.Expected_cash = nil
+		shift.Counted_cash = nil
+		shift.Variance = nil
+		shift.Closed_by = nil
+		shift.Closed_at = nil
+
+		if _, err = shiftCollection.InsertOne(ctx, shift); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, shift)
+	}
+}
+
+func AddCashMovement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var movement models.CashMovement
+		var shift models.Shift
+
+		shiftId := c.Param("shift_id")
+
+		if err := c.BindJSON(&movement); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(movement)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := shiftCollection.FindOne(ctx, bson.M{"shift_id": shiftId}).Decode(&shift)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shift not found"})
+			return
+		}
+
+		amount := models.NewMoney(movement.Amount.Amount, movement.Amount.Currency)
+		if amount.Amount <= 0 || amount.Currency != shift.Opening_float.Currency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive and in the drawer currency"})
+			return
+		}
+		if movement.Type == "PAYOUT" && movement.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "payouts need a reason"})
+			return
+		}
+
+		movement.Amount = &amount
+		movement.Created_by = c.GetString("user_id")
+		movement.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		result, err := shiftCollection.UpdateOne(ctx, bson.M{"shift_id": shiftId, "status": "OPEN"}, bson.D{{"$push", bson.D{{"cash_movements", movement}}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "shift is already closed"})
+			return
+		}
+		c.JSON(http.StatusOK, movement)
+	}
+}
+
+func CloseShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var request closeShiftRequest
+		var shift models.Shift
+
+		shiftId := c.Param("shift_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(request)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		err := shiftCollection.FindOne(ctx, bson.M{"shift_id": shiftId}).Decode(&shift)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "shift not found"})
+			return
+		}
+		if shift.Status != "OPEN" {
+			c.JSON(http.StatusConflict, gin.H{"error": "shift is already closed"})
+			return
+		}
+
+		counted := models.NewMoney(request.Counted_cash.Amount, request.Counted_cash.Currency)
+		if counted.Currency != shift.Opening_float.Currency || counted.Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "counted cash must be in the drawer currency"})
+			return
+		}
+
+		summary, err := helpers.SummarizeShift(ctx, shift)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		variance := counted.Sub(summary.Expected_cash)
+		closedBy := c.GetString("user_id")
+		closedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		shift.Status = "CLOSED"
+		shift.Expected_cash = &summary.Expected_cash
+		shift.Counted_cash = &counted
+		shift.Variance = &variance
+		shift.Closed_by = &closedBy
+		shift.Closed_at = &closedAt
+
+		result, err := shiftCollection.UpdateOne(ctx, bson.M{"shift_id": shiftId, "status": "OPEN"}, bson.D{{"$set", bson.D{
+			{"status", shift.Status},
+			{"expected_cash", shift.Expected_cash},
+			{"counted_cash", shift.Counted_cash},
+			{"variance", shift.Variance},
+			{"closed_by", shift.Closed_by},
+			{"closed_at", shift.Closed_at},
+		}}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "shift is already closed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"shift": shift, "summary": summary})
+	}
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var stationCollection *mongo.Collection = database.OpenCollection(database.Client, "station")
+
+func GetStations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := stationCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		allStations := []models.Station{}
+		if err = result.All(ctx, &allStations); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allStations)
+	}
+}
+
+func GetStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var station models.Station
+
+		err := stationCollection.FindOne(ctx, bson.M{"station_id": c.Param("station_id")}).Decode(&station)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station not found"})
+			return
+		}
+		c.JSON(http.StatusOK, station)
+	}
+}
+
+func CreateStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var station models.Station
+
+		if err := c.BindJSON(&station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(station)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if msg := checkStationPrinters(ctx, station.Printer_ids); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if station.Active == nil {
+			active := true
+			station.Active = &active
+		}
+		station.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		station.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		station.ID = primitive.NewObjectID()
+		station.Station_id = station.ID.Hex()
+
+		result, insertErr := stationCollection.InsertOne(ctx, station)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateStation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var station models.Station
+		stationId := c.Param("station_id")
+
+		if err := c.BindJSON(&station); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if station.Name != nil {
+			if err := validate.Var(*station.Name, "min=2,max=100"); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"name", station.Name})
+		}
+
+		if station.Categories != nil {
+			updateObj = append(updateObj, bson.E{"categories", station.Categories})
+		}
+
+		if station.Printer_ids != nil {
+			if msg := checkStationPrinters(ctx, station.Printer_ids); msg != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"printer_ids", station.Printer_ids})
+		}
+
+		if station.Active != nil {
+			updateObj = append(updateObj, bson.E{"active", station.Active})
+		}
+
+		station.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", station.Updated_at})
+
+		result, err := stationCollection.UpdateOne(
+			ctx,
+			bson.M{"station_id": stationId},
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station not found"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetStationQueue lists what a station still has to cook, highest priority
+// first and oldest first within a priority.
+func GetStationQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		stationId := c.Param("station_id")
+		if !stationExists(ctx, stationId) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "station not found"})
+			return
+		}
+
+		queue, err := helpers.StationQueue(ctx, stationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, queue)
+	}
+}
+
+func stationExists(ctx context.Context, stationId string) bool {
+	count, err := stationCollection.CountDocuments(ctx, bson.M{"station_id": stationId})
+	return err == nil && count > 0
+}
+
+// checkStationPrinters makes sure every printer given to a station exists
+// and prints kitchen tickets.
+func checkStationPrinters(ctx context.Context, printerIds []string) string {
+	for _, printerId := range printerIds {
+		count, err := printerCollection.CountDocuments(ctx, bson.M{"printer_id": printerId, "role": "KITCHEN"})
+		if err != nil {
+			return err.Error()
+		}
+		if count == 0 {
+			return "kitchen printer " + printerId + " not found"
+		}
+	}
+	return ""
+}
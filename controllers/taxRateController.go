@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var taxRateCollection *mongo.Collection = database.OpenCollection(database.Client, "taxrate")
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := taxRateCollection.Find(ctx, bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var allTaxRates []bson.M
+		if err = result.All(ctx, &allTaxRates); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, allTaxRates)
+	}
+}
+
+func GetTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var taxRate models.TaxRate
+		taxRateId := c.Param("tax_rate_id")
+
+		err := taxRateCollection.FindOne(ctx, bson.M{"tax_rate_id": taxRateId}).Decode(&taxRate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while fetching the tax rate"})
+			return
+		}
+		c.JSON(http.StatusOK, taxRate)
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var taxRate models.TaxRate
+
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validationErr := validate.Struct(taxRate)
+		if validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if msg := checkTaxRate(taxRate); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		taxRate.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		taxRate.ID = primitive.NewObjectID()
+		taxRate.Tax_rate_id = taxRate.ID.Hex()
+
+		result, insertErr := taxRateCollection.InsertOne(ctx, taxRate)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": insertErr.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var taxRate models.TaxRate
+		taxRateId := c.Param("tax_rate_id")
+
+		if err := c.BindJSON(&taxRate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updateObj primitive.D
+
+		if taxRate.Name != nil {
+			updateObj = append(updateObj, bson.E{"name", taxRate.Name})
+		}
+
+		if taxRate.Rate_bps != nil {
+			if *taxRate.Rate_bps < 0 || *taxRate.Rate_bps > 10000 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "rate_bps must be between 0 and 10000"})
+				return
+			}
+			updateObj = append(updateObj, bson.E{"rate_bps", taxRate.Rate_bps})
+		}
+
+		if taxRate.Inclusive != nil {
+			updateObj = append(updateObj, bson.E{"inclusive", taxRate.Inclusive})
+		}
+
+		if taxRate.Compound != nil {
+			updateObj = append(updateObj, bson.E{"compound", taxRate.Compound})
+		}
+
+		if taxRate.Priority != nil {
+			updateObj = append(updateObj, bson.E{"priority", taxRate.Priority})
+		}
+
+		if taxRate.Location != nil {
+			updateObj = append(updateObj, bson.E{"location", taxRate.Location})
+		}
+
+		if taxRate.Order_type != nil {
+			updateObj = append(updateObj, bson.E{"order_type", taxRate.Order_type})
+		}
+
+		if taxRate.Tax_categories != nil {
+			updateObj = append(updateObj, bson.E{"tax_categories", taxRate.Tax_categories})
+		}
+
+		if taxRate.Effective_from != nil {
+			updateObj = append(updateObj, bson.E{"effective_from", taxRate.Effective_from})
+		}
+
+		if taxRate.Effective_to != nil {
+			updateObj = append(updateObj, bson.E{"effective_to", taxRate.Effective_to})
+		}
+
+		var existing models.TaxRate
+		if err := taxRateCollection.FindOne(ctx, bson.M{"tax_rate_id": taxRateId}).Decode(&existing); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "tax rate not found"})
+			return
+		}
+		if taxRate.Name != nil {
+			existing.Name = taxRate.Name
+		}
+		if taxRate.Rate_bps != nil {
+			existing.Rate_bps = taxRate.Rate_bps
+		}
+		if taxRate.Inclusive != nil {
+			existing.Inclusive = taxRate.Inclusive
+		}
+		if taxRate.Compound != nil {
+			existing.Compound = taxRate.Compound
+		}
+		if taxRate.Priority != nil {
+			existing.Priority = taxRate.Priority
+		}
+		if taxRate.Location != nil {
+			existing.Location = taxRate.Location
+		}
+		if taxRate.Order_type != nil {
+			existing.Order_type = taxRate.Order_type
+		}
+		if taxRate.Tax_categories != nil {
+			existing.Tax_categories = taxRate.Tax_categories
+		}
+		if taxRate.Effective_from != nil {
+			existing.Effective_from = taxRate.Effective_from
+		}
+		if taxRate.Effective_to != nil {
+			existing.Effective_to = taxRate.Effective_to
+		}
+		if validationErr := validate.Struct(existing); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+		if msg := checkTaxRate(existing); msg != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		taxRate.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{"updated_at", taxRate.Updated_at})
+
+		filter := bson.M{"tax_rate_id": taxRateId}
+
+		result, err := taxRateCollection.UpdateOne(
+			ctx,
+			filter,
+			bson.D{{"$set", updateObj}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func checkTaxRate(taxRate models.TaxRate) string {
+	if taxRate.Inclusive != nil && *taxRate.Inclusive && taxRate.Compound != nil && *taxRate.Compound {
+		return "a tax rate cannot be both inclusive and compound"
+	}
+	if taxRate.Effective_from != nil && taxRate.Effective_to != nil && !taxRate.Effective_to.After(*taxRate.Effective_from) {
+		return "effective_to must be after effective_from"
+	}
+	return ""
+}
@@ -20,6 +20,10 @@ import (
 
 var userCollection *mongo.Collection = database.OpenCollection(database.Client, "user")
 
+type userPinRequest struct {
+	Pin *string `json:"pin" validate:"required,numeric,min=4,max=8"`
+}
+
 func GetUsers() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -90,6 +94,7 @@ func GetUser() gin.HandlerFunc {
 func Signup() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
 		var user models.User
 
@@ -102,6 +107,19 @@ func Signup() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": validationErr.Error()})
 		}
 
+		role := "STAFF"
+		if user.Role == nil {
+			user.Role = &role
+		}
+		if *user.Role != "STAFF" {
+			var creator models.User
+			err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("user_id")}).Decode(&creator)
+			if err != nil || creator.Role == nil || *creator.Role != "ADMIN" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only an admin can create managers and admins"})
+				return
+			}
+		}
+
 		Password := HashPassword(*user.Password)
 		user.Password = &Password
 
@@ -137,7 +155,6 @@ func Signup() gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 
-		defer cancel()
 		c.JSON(http.StatusOK, result)
 	}
 }
@@ -145,34 +162,98 @@ func Signup() gin.HandlerFunc {
 func Login() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
 		var user models.User
 		var founduser models.User
 
 		if err := c.BindJSON(&user); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if user.Email == nil || user.Password == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email and password are required"})
+			return
 		}
 
 		err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&founduser)
-		defer cancel()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err != nil || founduser.Password == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "email or password is incorrect"})
+			return
 		}
 
 		passwordisValid, msg := VerifyPassword(*user.Password, *founduser.Password)
 		msg = fmt.Sprintf("Password invalid")
 		if passwordisValid != true {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
 		}
 
 		token, refresh_token, _ := helpers.GenerateAllTokens(*founduser.Email, *founduser.First_name, *founduser.Last_name, *&founduser.User_id)
 		helpers.UpdateAllToken(token, refresh_token, founduser.User_id)
+		founduser.Token = &token
+		founduser.Refresh_token = &refresh_token
 
 		c.JSON(http.StatusOK, founduser)
 
 	}
 }
 
+// SetUserPin sets the PIN a manager keys in to approve voids, comps and
+// other overrides on a shared terminal. Users set their own PIN and admins
+// can reset anyone's.
+func SetUserPin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var request userPinRequest
+		var caller models.User
+
+		userId := c.Param("user_id")
+
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if validationErr := validate.Struct(request); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		if c.GetString("user_id") != userId {
+			err := userCollection.FindOne(ctx, bson.M{"user_id": c.GetString("user_id")}).Decode(&caller)
+			if err != nil || caller.Role == nil || *caller.Role != "ADMIN" {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only an admin can set another user's PIN"})
+				return
+			}
+		}
+
+		pin, err := helpers.HashPin(*request.Pin)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		updated_at, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{"$set", bson.D{{"pin", pin}, {"pin_failures", 0}, {"pin_locked_at", nil}, {"updated_at", updated_at}}}},
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.MatchedCount == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user_id": userId, "pin_set": true})
+	}
+}
+
 func VerifyPassword(userPassword string, providedPassword string) (bool, string) {
 	err := bcrypt.CompareHashAndPassword([]byte(providedPassword), []byte(userPassword))
 	check := true
@@ -0,0 +1,130 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var managerRoles = map[string]bool{"ADMIN": true, "MANAGER": true}
+
+// maxPinFailures wrong PINs in a row lock a manager's PIN for pinLockout.
+const maxPinFailures = 5
+
+const pinLockout = 15 * time.Minute
+
+// ManagerApproval checks that token belongs to a manager or admin and
+// returns their user id, or a message explaining why approval failed.
+func ManagerApproval(ctx context.Context, token *string) (string, string) {
+	if token == nil || *token == "" {
+		return "", "manager approval is required"
+	}
+
+	claims, msg := ValidateAllToken(*token)
+	if msg != "" {
+		return "", msg
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": claims.User_id}).Decode(&user); err != nil {
+		return "", "approving user not found"
+	}
+	if user.Role == nil || !managerRoles[*user.Role] {
+		return "", "approving user is not a manager"
+	}
+	return user.User_id, ""
+}
+
+// IsManager reports whether the user is a manager or admin.
+func IsManager(ctx context.Context, userId string) bool {
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&user); err != nil {
+		return false
+	}
+	return user.Role != nil && managerRoles[*user.Role]
+}
+
+// ManagerPinApproval checks the PIN a manager or admin keyed in on the
+// terminal. Repeated wrong PINs lock the PIN for a while so it cannot be
+// guessed.
+func ManagerPinApproval(ctx context.Context, userId *string, pin *string) (string, string) {
+	if userId == nil || pin == nil || *userId == "" || *pin == "" {
+		return "", "manager approval is required"
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"user_id": *userId}).Decode(&user); err != nil {
+		return "", "approving user not found"
+	}
+	if user.Role == nil || !managerRoles[*user.Role] {
+		return "", "approving user is not a manager"
+	}
+	if user.Pin == nil {
+		return "", "approving user has no PIN set"
+	}
+	if user.Pin_locked_at != nil && time.Since(*user.Pin_locked_at) < pinLockout {
+		return "", "PIN is locked after too many attempts, try again later"
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(*user.Pin), []byte(*pin)) != nil {
+		update := bson.D{{"$inc", bson.D{{"pin_failures", 1}}}}
+		if user.Pin_failures+1 >= maxPinFailures {
+			now := time.Now()
+			update = bson.D{{"$set", bson.D{{"pin_failures", 0}, {"pin_locked_at", now}}}}
+		}
+		userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, update)
+		return "", "PIN is incorrect"
+	}
+	if user.Pin_failures > 0 || user.Pin_locked_at != nil {
+		userCollection.UpdateOne(ctx, bson.M{"user_id": user.User_id}, bson.D{{"$set", bson.D{{"pin_failures", 0}, {"pin_locked_at", nil}}}})
+	}
+	return user.User_id, ""
+}
+
+// ManagerOverride accepts either a manager's token or their user id and PIN,
+// whichever the terminal sent.
+func ManagerOverride(ctx context.Context, token *string, userId *string, pin *string) (string, string) {
+	if pin != nil && *pin != "" {
+		return ManagerPinApproval(ctx, userId, pin)
+	}
+	return ManagerApproval(ctx, token)
+}
+
+// HashPin hashes a PIN for storage. PINs are short, so they are only ever
+// compared with bcrypt and locked after repeated failures.
+func HashPin(pin string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	return string(bytes), err
+}
+
+// RefundNeedsApproval reports whether amount is above the configured refund
+// approval threshold. Without a threshold no approval is needed.
+func RefundNeedsApproval(ctx context.Context, amount models.Money) bool {
+	var setting models.Setting
+	err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting)
+	if err != nil || setting.Refund_approval_threshold == nil {
+		return false
+	}
+	return amount.Amount > setting.Refund_approval_threshold.Amount
+}
+
+// InvoiceRefundTotal adds up the refunds already issued or in progress on an
+// invoice, so splitting a large refund into small ones still needs approval.
+func InvoiceRefundTotal(ctx context.Context, invoiceId string) (models.Money, error) {
+	total := models.Money{}
+	cursor, err := refundCollection.Find(ctx, bson.M{"invoice_id": invoiceId, "status": bson.M{"$ne": "FAILED"}})
+	if err != nil {
+		return total, err
+	}
+	var refunds []models.Refund
+	if err = cursor.All(ctx, &refunds); err != nil {
+		return total, err
+	}
+	for _, refund := range refunds {
+		total = total.Add(*refund.Amount)
+	}
+	return total, nil
+}
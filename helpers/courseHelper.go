@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrCourseNotFound = errors.New("order has no items in this course")
+var ErrCourseFired = errors.New("course has already been fired")
+
+// ItemCourse returns the course of an item. Items without one are served
+// with the first course.
+func ItemCourse(item models.OrderItem) int {
+	if item.Course == nil {
+		return 1
+	}
+	return *item.Course
+}
+
+// ItemHeld reports whether an item is waiting for its course to be fired.
+func ItemHeld(item models.OrderItem) bool {
+	return item.Hold != nil && *item.Hold
+}
+
+// CourseFired reports whether a course of an order has been fired.
+func CourseFired(order models.Order, course int) bool {
+	for _, fire := range order.Fired_courses {
+		if fire.Course == course {
+			return true
+		}
+	}
+	return false
+}
+
+// HoldByDefault decides whether a new item waits for its course to be
+// fired when the server did not say. Dine-in items after the first course
+// are held until their course is fired; takeaway goes straight through.
+func HoldByDefault(order models.Order, course int) bool {
+	if order.Order_type != nil && *order.Order_type == "TAKEAWAY" {
+		return false
+	}
+	return course > 1 && !CourseFired(order, course)
+}
+
+// FireCourse releases the held items of a course to the kitchen and
+// records when and by whom the course was fired. Released items are queued
+// from the moment they are fired so ticket times do not include the hold.
+func FireCourse(ctx context.Context, order models.Order, course int, userId string) ([]string, error) {
+	courseFilter := bson.M{"$eq": course}
+	if course == 1 {
+		courseFilter = bson.M{"$in": bson.A{1, nil}}
+	}
+	cursor, err := orderitemCollection.Find(ctx, bson.M{"order_id": order.Order_id, "course": courseFilter, "status": bson.M{"$ne": "VOIDED"}})
+	if err != nil {
+		return nil, err
+	}
+	var items []models.OrderItem
+	if err = cursor.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrCourseNotFound
+	}
+
+	held := []string{}
+	for _, item := range items {
+		if ItemHeld(item) {
+			held = append(held, item.Order_item_id)
+		}
+	}
+	if len(held) == 0 && CourseFired(order, course) {
+		return nil, ErrCourseFired
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	fire := models.CourseFire{Course: course, Fired_by: userId, Fired_at: now}
+	if len(held) > 0 {
+		_, err = orderitemCollection.UpdateMany(ctx, bson.M{"order_item_id": bson.M{"$in": held}, "hold": true}, bson.D{{"$set", bson.D{
+			{"hold", false},
+			{"released_at", now},
+			{"queued_at", now},
+			{"updated_at", now},
+		}}})
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = orderCollection.UpdateOne(ctx, bson.M{"order_id": order.Order_id}, bson.D{
+		{"$set", bson.D{{"updated_at", now}}},
+		{"$push", bson.D{{"fired_courses", fire}}},
+	})
+	if err != nil {
+		return held, err
+	}
+
+	for _, id := range held {
+		PublishOrderItemEvent(ctx, "order_item.fired", id)
+	}
+	PublishKitchenEvent(ctx, models.KitchenEvent{Type: "course.fired", Order_id: order.Order_id, Status: OrderStatus(order)})
+	return held, SendOrderToKitchen(ctx, order.Order_id, userId)
+}
+
+// SendOrderToKitchen moves an OPEN order to SENT_TO_KITCHEN once the
+// kitchen has something to cook. Orders already further along are left
+// alone.
+func SendOrderToKitchen(ctx context.Context, orderId string, userId string) error {
+	_, err := TransitionOrder(ctx, orderId, "OPEN", "SENT_TO_KITCHEN", userId, "items sent to the kitchen")
+	if err == ErrOrderChanged {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// RecordCustomerVisit adds a paid invoice to its customer's lifetime spend.
+// The visit count goes up once per order, so a table that splits the bill
+// still counts as a single visit. The invoice keeps what it has already
+// added, so one paid again after a voided payment only adds the new payment.
+func RecordCustomerVisit(ctx context.Context, invoice models.Invoice) error {
+	if invoice.Customer_id == nil || invoice.Amount_paid == nil {
+		return nil
+	}
+
+	recorded := models.NewMoney(0, invoice.Amount_paid.Currency)
+	if invoice.Spend_recorded != nil {
+		recorded = *invoice.Spend_recorded
+	}
+	spend := invoice.Amount_paid.Sub(recorded)
+	if spend.Amount <= 0 {
+		return nil
+	}
+	result, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id, "spend_recorded": invoice.Spend_recorded}, bson.D{{"$set", bson.D{{"spend_recorded", invoice.Amount_paid}}}})
+	if err != nil || result.MatchedCount == 0 {
+		return err
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"lifetime_spend.amount": spend.Amount},
+		"$set": bson.M{"last_visit_at": time.Now(), "lifetime_spend.currency": spend.Currency},
+	}
+	if invoice.Order_id != nil && invoice.Spend_recorded == nil {
+		earlier, err := invoiceCollection.CountDocuments(ctx, bson.M{
+			"order_id":       invoice.Order_id,
+			"invoice_id":     bson.M{"$ne": invoice.Invoice_id},
+			"payment_status": bson.M{"$in": bson.A{"PAID", "REFUNDED"}},
+		})
+		if err != nil {
+			return err
+		}
+		if earlier == 0 {
+			update["$inc"].(bson.M)["visit_count"] = 1
+		}
+	}
+
+	_, err = customerCollection.UpdateOne(ctx, bson.M{"customer_id": invoice.Customer_id}, update)
+	return err
+}
+
+// ReverseCustomerSpend takes a voided payment back off the customer's
+// lifetime spend and off what its invoice has recorded.
+func ReverseCustomerSpend(ctx context.Context, invoice models.Invoice, amount models.Money) error {
+	if invoice.Customer_id == nil {
+		return nil
+	}
+	_, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id, "spend_recorded": bson.M{"$ne": nil}}, bson.M{"$inc": bson.M{"spend_recorded.amount": -amount.Amount}})
+	if err != nil {
+		return err
+	}
+	return RecordCustomerRefund(ctx, invoice, amount)
+}
+
+// RecordCustomerRefund takes a refund off the customer's lifetime spend.
+func RecordCustomerRefund(ctx context.Context, invoice models.Invoice, amount models.Money) error {
+	if invoice.Customer_id == nil {
+		return nil
+	}
+	_, err := customerCollection.UpdateOne(ctx, bson.M{"customer_id": invoice.Customer_id}, bson.M{
+		"$inc": bson.M{"lifetime_spend.amount": -amount.Amount},
+	})
+	return err
+}
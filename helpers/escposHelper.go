@@ -0,0 +1,88 @@
+package helpers
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	escposAlignLeft   = 0
+	escposAlignCenter = 1
+	escposAlignRight  = 2
+)
+
+// EscPos builds a byte stream for ESC/POS thermal printers. Only the small
+// command subset every Epson compatible printer understands is used.
+type EscPos struct {
+	buf bytes.Buffer
+}
+
+func NewEscPos() *EscPos {
+	e := &EscPos{}
+	e.buf.Write([]byte{0x1b, '@'})
+	return e
+}
+
+func (e *EscPos) Align(align byte) *EscPos {
+	e.buf.Write([]byte{0x1b, 'a', align})
+	return e
+}
+
+func (e *EscPos) Bold(on bool) *EscPos {
+	e.buf.Write([]byte{0x1b, 'E', boolByte(on)})
+	return e
+}
+
+func (e *EscPos) DoubleSize(on bool) *EscPos {
+	size := byte(0x00)
+	if on {
+		size = 0x11
+	}
+	e.buf.Write([]byte{0x1d, '!', size})
+	return e
+}
+
+func (e *EscPos) Line(text string) *EscPos {
+	e.buf.WriteString(escposText(text))
+	e.buf.WriteByte('\n')
+	return e
+}
+
+func (e *EscPos) Rule(width int) *EscPos {
+	return e.Line(strings.Repeat("-", width))
+}
+
+func (e *EscPos) Feed(lines int) *EscPos {
+	e.buf.Write([]byte{0x1b, 'd', byte(lines)})
+	return e
+}
+
+func (e *EscPos) Cut() *EscPos {
+	e.buf.Write([]byte{0x1d, 'V', 'A', 0x03})
+	return e
+}
+
+func (e *EscPos) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+func boolByte(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+// escposText keeps printable ASCII and replaces everything else, since the
+// default code page of most printers cannot show it.
+func escposText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package helpers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"golang-restaurant-management/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakePaymentProvider is an in-process provider for tests and demos. Card
+// tokens drive its behaviour: "tok_decline" is declined, "tok_timeout" times
+// out on every call and "tok_timeout_once" times out on the first call only.
+// Any other token is approved.
+type FakePaymentProvider struct {
+	mu           sync.Mutex
+	secret       string
+	sequence     int
+	transactions map[string]*fakeTransaction
+	idempotent   map[string]ProviderResult
+	timedOut     map[string]bool
+}
+
+type fakeTransaction struct {
+	amount   models.Money
+	captured models.Money
+	refunded models.Money
+	status   string
+}
+
+func NewFakePaymentProvider(secret string) *FakePaymentProvider {
+	return &FakePaymentProvider{
+		secret:       secret,
+		transactions: map[string]*fakeTransaction{},
+		idempotent:   map[string]ProviderResult{},
+		timedOut:     map[string]bool{},
+	}
+}
+
+func (p *FakePaymentProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakePaymentProvider) Authorize(ctx context.Context, request AuthorizeRequest) (ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if result, ok, err := p.replay("authorize", request.Idempotency_key); ok {
+		return result, err
+	}
+
+	switch request.Card_token {
+	case "tok_decline":
+		return p.remember("authorize", request.Idempotency_key, ProviderResult{Status: "DECLINED", Decline_reason: "card declined"}), ErrPaymentDeclined
+	case "tok_timeout":
+		return ProviderResult{}, ErrProviderTimeout
+	case "tok_timeout_once":
+		if !p.timedOut[request.Idempotency_key] {
+			p.timedOut[request.Idempotency_key] = true
+			return ProviderResult{}, ErrProviderTimeout
+		}
+	}
+
+	p.sequence++
+	id := "fake_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "_" + strconv.Itoa(p.sequence)
+	p.transactions[id] = &fakeTransaction{amount: request.Amount, status: "AUTHORIZED"}
+	return p.remember("authorize", request.Idempotency_key, ProviderResult{Transaction_id: id, Status: "AUTHORIZED"}), nil
+}
+
+func (p *FakePaymentProvider) Capture(ctx context.Context, transactionId string, amount models.Money, idempotencyKey string) (ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if result, ok, err := p.replay("capture", idempotencyKey); ok {
+		return result, err
+	}
+	transaction, ok := p.transactions[transactionId]
+	if !ok || transaction.status != "AUTHORIZED" || amount.Amount > transaction.amount.Amount {
+		return ProviderResult{Transaction_id: transactionId, Status: "DECLINED", Decline_reason: "capture not allowed"}, ErrPaymentDeclined
+	}
+	transaction.captured = amount
+	transaction.status = "CAPTURED"
+	return p.remember("capture", idempotencyKey, ProviderResult{Transaction_id: transactionId, Status: "CAPTURED"}), nil
+}
+
+// Void cancels a transaction and forgets the authorize and capture keys that
+// led to it, so a payment retried after its tender was reversed is charged
+// again instead of replaying the voided result.
+func (p *FakePaymentProvider) Void(ctx context.Context, transactionId string, idempotencyKey string) (ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if result, ok, err := p.replay("void", idempotencyKey); ok {
+		return result, err
+	}
+	transaction, ok := p.transactions[transactionId]
+	if !ok || (transaction.status != "AUTHORIZED" && transaction.status != "CAPTURED") {
+		return ProviderResult{Transaction_id: transactionId, Status: "DECLINED", Decline_reason: "void not allowed"}, ErrPaymentDeclined
+	}
+	transaction.status = "VOIDED"
+	for key, result := range p.idempotent {
+		if result.Transaction_id == transactionId && (strings.HasPrefix(key, "authorize:") || strings.HasPrefix(key, "capture:")) {
+			delete(p.idempotent, key)
+		}
+	}
+	return p.remember("void", idempotencyKey, ProviderResult{Transaction_id: transactionId, Status: "VOIDED"}), nil
+}
+
+func (p *FakePaymentProvider) Refund(ctx context.Context, transactionId string, amount models.Money, idempotencyKey string) (ProviderResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if result, ok, err := p.replay("refund", idempotencyKey); ok {
+		return result, err
+	}
+	transaction, ok := p.transactions[transactionId]
+	if !ok || transaction.status == "VOIDED" || transaction.refunded.Amount+amount.Amount > transaction.captured.Amount {
+		return ProviderResult{Transaction_id: transactionId, Status: "DECLINED", Decline_reason: "refund not allowed"}, ErrPaymentDeclined
+	}
+	transaction.refunded = transaction.refunded.Add(amount)
+	return p.remember("refund", idempotencyKey, ProviderResult{Transaction_id: transactionId, Status: "REFUNDED"}), nil
+}
+
+func (p *FakePaymentProvider) VerifyWebhook(payload []byte, signature string) (WebhookEvent, error) {
+	var event WebhookEvent
+	if p.secret == "" {
+		return event, ErrWebhookSecretMissing
+	}
+	if !hmac.Equal([]byte(p.Sign(payload)), []byte(signature)) {
+		return event, ErrInvalidSignature
+	}
+	err := json.Unmarshal(payload, &event)
+	return event, err
+}
+
+// Sign returns the signature the fake provider expects on a webhook payload.
+func (p *FakePaymentProvider) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *FakePaymentProvider) replay(operation string, idempotencyKey string) (ProviderResult, bool, error) {
+	if idempotencyKey == "" {
+		return ProviderResult{}, false, nil
+	}
+	result, ok := p.idempotent[operation+":"+idempotencyKey]
+	if ok && result.Status == "DECLINED" {
+		return result, true, ErrPaymentDeclined
+	}
+	return result, ok, nil
+}
+
+func (p *FakePaymentProvider) remember(operation string, idempotencyKey string, result ProviderResult) ProviderResult {
+	if idempotencyKey != "" {
+		p.idempotent[operation+":"+idempotencyKey] = result
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestFakeProviderReplaysIdempotentCalls(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider("secret")
+	request := AuthorizeRequest{Amount: models.NewMoney(1500, "USD"), Card_token: "tok_visa", Idempotency_key: "authorize:a"}
+
+	first, err := provider.Authorize(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := provider.Authorize(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Transaction_id != second.Transaction_id {
+		t.Fatalf("retried authorization created %s after %s", second.Transaction_id, first.Transaction_id)
+	}
+
+	request.Idempotency_key = "authorize:b"
+	other, err := provider.Authorize(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Transaction_id == first.Transaction_id {
+		t.Fatal("a new key replayed another authorization")
+	}
+
+	if _, err = provider.Capture(ctx, first.Transaction_id, request.Amount, "capture:a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = provider.Capture(ctx, first.Transaction_id, request.Amount, "capture:a"); err != nil {
+		t.Fatalf("retried capture failed: %v", err)
+	}
+}
+
+func TestFakeProviderReplaysDeclines(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider("secret")
+	request := AuthorizeRequest{Amount: models.NewMoney(1500, "USD"), Card_token: "tok_decline", Idempotency_key: "authorize:a"}
+
+	for i := 0; i < 2; i++ {
+		result, err := provider.Authorize(ctx, request)
+		if !errors.Is(err, ErrPaymentDeclined) || result.Decline_reason == "" {
+			t.Fatalf("attempt %d: got %+v, %v", i, result, err)
+		}
+	}
+}
+
+func TestFakeProviderVoidForgetsKeys(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider("secret")
+	request := AuthorizeRequest{Amount: models.NewMoney(1500, "USD"), Card_token: "tok_visa", Idempotency_key: "authorize:a"}
+
+	first, err := provider.Authorize(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = provider.Capture(ctx, first.Transaction_id, request.Amount, "capture:a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = provider.Void(ctx, first.Transaction_id, "void:a"); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := provider.Authorize(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Transaction_id == first.Transaction_id {
+		t.Fatal("authorization replayed a voided transaction")
+	}
+	if _, err = provider.Capture(ctx, second.Transaction_id, request.Amount, "capture:a"); err != nil {
+		t.Fatalf("capture after void: %v", err)
+	}
+}
+
+func TestFakeProviderRefundLimits(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider("secret")
+	amount := models.NewMoney(1000, "USD")
+
+	authorization, _ := provider.Authorize(ctx, AuthorizeRequest{Amount: amount, Card_token: "tok_visa"})
+	if _, err := provider.Capture(ctx, authorization.Transaction_id, amount, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Refund(ctx, authorization.Transaction_id, models.NewMoney(600, "USD"), "refund:a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Refund(ctx, authorization.Transaction_id, models.NewMoney(600, "USD"), "refund:a"); err != nil {
+		t.Fatalf("retried refund failed: %v", err)
+	}
+	if _, err := provider.Refund(ctx, authorization.Transaction_id, models.NewMoney(600, "USD"), "refund:b"); !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("refund over the captured amount: %v", err)
+	}
+}
+
+func TestWithProviderRetry(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakePaymentProvider("secret")
+	authorize := func(token string) (ProviderResult, error) {
+		return WithProviderRetry(ctx, 3, func(ctx context.Context) (ProviderResult, error) {
+			return provider.Authorize(ctx, AuthorizeRequest{Amount: models.NewMoney(100, "USD"), Card_token: token, Idempotency_key: "authorize:" + token})
+		})
+	}
+
+	if result, err := authorize("tok_timeout_once"); err != nil || result.Status != "AUTHORIZED" {
+		t.Fatalf("timeout once: got %+v, %v", result, err)
+	}
+	if _, err := authorize("tok_timeout"); !errors.Is(err, ErrProviderTimeout) {
+		t.Fatalf("timeout: got %v", err)
+	}
+	if _, err := authorize("tok_decline"); !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("decline: got %v", err)
+	}
+}
+
+func TestFakeProviderVerifyWebhook(t *testing.T) {
+	provider := NewFakePaymentProvider("secret")
+	payload := []byte(`{"type":"payment.captured","transaction_id":"fake_1"}`)
+
+	event, err := provider.VerifyWebhook(payload, provider.Sign(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "payment.captured" || event.Transaction_id != "fake_1" {
+		t.Fatalf("decoded %+v", event)
+	}
+
+	if _, err = provider.VerifyWebhook([]byte(`{"type":"payment.refunded","transaction_id":"fake_1"}`), provider.Sign(payload)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("tampered payload: got %v", err)
+	}
+	if _, err = provider.VerifyWebhook(payload, NewFakePaymentProvider("other").Sign(payload)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("wrong secret: got %v", err)
+	}
+
+	unconfigured := NewFakePaymentProvider("")
+	if _, err = unconfigured.VerifyWebhook(payload, unconfigured.Sign(payload)); !errors.Is(err, ErrWebhookSecretMissing) {
+		t.Fatalf("empty secret: got %v", err)
+	}
+}
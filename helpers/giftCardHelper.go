@@ -0,0 +1,231 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var giftCardCollection *mongo.Collection = database.OpenCollection(database.Client, "giftcard")
+var giftCardTransactionCollection *mongo.Collection = database.OpenCollection(database.Client, "giftcardtransaction")
+
+var ErrGiftCardNotFound = errors.New("gift card not found")
+var ErrGiftCardInactive = errors.New("gift card is not active")
+var ErrGiftCardExpired = errors.New("gift card has expired")
+var ErrGiftCardInsufficient = errors.New("gift card balance is too low")
+
+// giftCardAlphabet leaves out characters that are easy to misread.
+const giftCardAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// GenerateGiftCardCode returns a random code such as 7KQM-X2RD-PZ4H-N9TB.
+func GenerateGiftCardCode() (string, error) {
+	var code strings.Builder
+	for i := 0; i < 16; i++ {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(giftCardAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(giftCardAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// NormalizeGiftCardCode accepts codes typed in lower case or without dashes.
+func NormalizeGiftCardCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	var normalized strings.Builder
+	for i, r := range code {
+		if i > 0 && i%4 == 0 {
+			normalized.WriteByte('-')
+		}
+		normalized.WriteRune(r)
+	}
+	return normalized.String()
+}
+
+// MaskGiftCardCode hides all but the last group of a code, so card lists
+// do not hand out spendable codes.
+func MaskGiftCardCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '-' {
+			return r
+		}
+		return '*'
+	}, code[:len(code)-4]) + code[len(code)-4:]
+}
+
+// DebitGiftCard takes amount off a card for an invoice payment. The balance
+// check and the deduction are one conditional update, so two tills spending
+// the same card at once can never take it below zero.
+func DebitGiftCard(ctx context.Context, code string, amount models.Money, invoiceId string, paymentId string, userId string) (models.GiftCard, error) {
+	var card models.GiftCard
+	now := time.Now()
+	filter := bson.M{
+		"code":             NormalizeGiftCardCode(code),
+		"status":           "ACTIVE",
+		"balance.currency": amount.Currency,
+		"balance.amount":   bson.M{"$gte": amount.Amount},
+		"$or":              bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now}}},
+	}
+	update := bson.M{
+		"$inc": bson.M{"balance.amount": -amount.Amount},
+		"$set": bson.M{"updated_at": now},
+	}
+	after := options.After
+	err := giftCardCollection.FindOneAndUpdate(ctx, filter, update, &options.FindOneAndUpdateOptions{ReturnDocument: &after}).Decode(&card)
+	if err == mongo.ErrNoDocuments {
+		return card, giftCardDebitError(ctx, code, amount)
+	}
+	if err != nil {
+		return card, err
+	}
+
+	if err = recordGiftCardTransaction(ctx, card, "REDEEM", amount.Neg(), &invoiceId, &paymentId, userId); err != nil {
+		giftCardCollection.UpdateOne(ctx, bson.M{"gift_card_id": card.Gift_card_id}, bson.M{"$inc": bson.M{"balance.amount": amount.Amount}})
+		return card, err
+	}
+	return card, nil
+}
+
+// CreditGiftCard adds amount back onto a card. Reloads need a live card,
+// while refunds and reversed payments always go back to the card they came
+// from.
+func CreditGiftCard(ctx context.Context, giftCardId string, amount models.Money, kind string, invoiceId *string, paymentId *string, userId string) (models.GiftCard, error) {
+	var card models.GiftCard
+	now := time.Now()
+	filter := bson.M{"gift_card_id": giftCardId, "balance.currency": amount.Currency}
+	if kind == "RELOAD" {
+		filter["status"] = "ACTIVE"
+		filter["$or"] = bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now}}}
+	}
+	update := bson.M{
+		"$inc": bson.M{"balance.amount": amount.Amount},
+		"$set": bson.M{"updated_at": now},
+	}
+	after := options.After
+	err := giftCardCollection.FindOneAndUpdate(ctx, filter, update, &options.FindOneAndUpdateOptions{ReturnDocument: &after}).Decode(&card)
+	if err == mongo.ErrNoDocuments {
+		var existing models.GiftCard
+		if giftCardCollection.FindOne(ctx, bson.M{"gift_card_id": giftCardId}).Decode(&existing) != nil {
+			return card, ErrGiftCardNotFound
+		}
+		if existing.Expires_at != nil && !existing.Expires_at.After(now) {
+			return card, ErrGiftCardExpired
+		}
+		return card, ErrGiftCardInactive
+	}
+	if err != nil {
+		return card, err
+	}
+
+	if err = recordGiftCardTransaction(ctx, card, kind, amount, invoiceId, paymentId, userId); err != nil {
+		giftCardCollection.UpdateOne(ctx, bson.M{"gift_card_id": card.Gift_card_id}, bson.M{"$inc": bson.M{"balance.amount": -amount.Amount}})
+		return card, err
+	}
+	return card, nil
+}
+
+// ExpireGiftCards writes off the balance of every card past its expiry date
+// and records the write-off in the card ledger.
+func ExpireGiftCards(ctx context.Context) (int, error) {
+	now := time.Now()
+	cursor, err := giftCardCollection.Find(ctx, bson.M{"status": "ACTIVE", "expires_at": bson.M{"$lte": now}})
+	if err != nil {
+		return 0, err
+	}
+	var cards []models.GiftCard
+	if err = cursor.All(ctx, &cards); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, card := range cards {
+		var updated models.GiftCard
+		after := options.After
+		err := giftCardCollection.FindOneAndUpdate(ctx,
+			bson.M{"gift_card_id": card.Gift_card_id, "status": "ACTIVE", "balance.amount": card.Balance.Amount},
+			bson.M{"$set": bson.M{"status": "EXPIRED", "balance.amount": 0, "updated_at": now}},
+			&options.FindOneAndUpdateOptions{ReturnDocument: &after},
+		).Decode(&updated)
+		if err != nil {
+			continue
+		}
+		if card.Balance.Amount > 0 {
+			if err = recordGiftCardTransaction(ctx, updated, "EXPIRE", card.Balance.Neg(), nil, nil, ""); err != nil {
+				log.Println("could not record gift card expiry", card.Gift_card_id, err)
+			}
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// RunGiftCardExpiry expires gift cards once an hour until ctx is done.
+func RunGiftCardExpiry(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if _, err := ExpireGiftCards(ctx); err != nil {
+			log.Println("gift card expiry failed", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func RecordGiftCardIssue(ctx context.Context, card models.GiftCard) error {
+	return recordGiftCardTransaction(ctx, card, "ISSUE", *card.Initial_balance, nil, nil, card.Issued_by)
+}
+
+func recordGiftCardTransaction(ctx context.Context, card models.GiftCard, kind string, amount models.Money, invoiceId *string, paymentId *string, userId string) error {
+	transaction := models.GiftCardTransaction{
+		ID:           primitive.NewObjectID(),
+		Gift_card_id: card.Gift_card_id,
+		Type:         kind,
+		Amount:       amount,
+		Invoice_id:   invoiceId,
+		Payment_id:   paymentId,
+		Created_by:   userId,
+	}
+	transaction.Gift_card_transaction_id = transaction.ID.Hex()
+	if card.Balance != nil {
+		transaction.Balance_after = *card.Balance
+	}
+	transaction.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := giftCardTransactionCollection.InsertOne(ctx, transaction)
+	return err
+}
+
+func giftCardDebitError(ctx context.Context, code string, amount models.Money) error {
+	var card models.GiftCard
+	if err := giftCardCollection.FindOne(ctx, bson.M{"code": NormalizeGiftCardCode(code)}).Decode(&card); err != nil {
+		return ErrGiftCardNotFound
+	}
+	if card.Status == nil || *card.Status != "ACTIVE" {
+		return ErrGiftCardInactive
+	}
+	if card.Expires_at != nil && !card.Expires_at.After(time.Now()) {
+		return ErrGiftCardExpired
+	}
+	return ErrGiftCardInsufficient
+}
@@ -0,0 +1,15 @@
+package helpers
+
+import "testing"
+
+func TestMaskGiftCardCode(t *testing.T) {
+	for code, want := range map[string]string{
+		"7KQM-X2RD-PZ4H-N9TB": "****-****-****-N9TB",
+		"N9TB":                "N9TB",
+		"":                    "",
+	} {
+		if got := MaskGiftCardCode(code); got != want {
+			t.Errorf("MaskGiftCardCode(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type InvoiceInput struct {
+	Lines           []models.InvoiceLine
+	TaxRates        []models.TaxRate
+	Setting         models.Setting
+	Guests          int
+	Discount_bps    int64
+	Discount_amount models.Money
+	Tip             models.Money
+	Currency        string
+	Location        string
+	Order_type      string
+	Date            time.Time
+}
+
+var ErrInvoiceCurrency = errors.New("tip and discount_amount must be in the invoice currency")
+
+var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+var tableCollection *mongo.Collection = database.OpenCollection(database.Client, "table")
+var taxRateCollection *mongo.Collection = database.OpenCollection(database.Client, "taxrate")
+var settingCollection *mongo.Collection = database.OpenCollection(database.Client, "setting")
+
+// CalculateInvoice fills the breakdown fields of invoice from the given input.
+// Promotion discounts already recorded on the lines come off first, the
+// invoice discount is then spread over what is left of the lines pro rata
+// before taxing, and the service charge only applies once the party reaches
+// the configured size.
+func CalculateInvoice(invoice *models.Invoice, in InvoiceInput) {
+	currency := in.Currency
+	if currency == "" && len(in.Lines) > 0 {
+		currency = in.Lines[0].Unit_price.Currency
+	}
+	zero := models.Money{Currency: currency}
+
+	subtotal := zero
+	promotion := zero
+	for _, line := range in.Lines {
+		subtotal = subtotal.Add(line.Line_total)
+		promotion = promotion.Add(line.Discount)
+	}
+	promoted := subtotal.Sub(promotion)
+
+	discount := zero
+	if in.Discount_bps > 0 {
+		discount = promoted.Percent(in.Discount_bps)
+	}
+	discount = discount.Add(in.Discount_amount)
+	if discount.Amount > promoted.Amount {
+		discount = promoted
+	}
+	if discount.Amount < 0 {
+		discount = zero
+	}
+
+	netLines := allocateDiscount(in.Lines, discount)
+	net := promoted.Sub(discount)
+	discount = discount.Add(promotion)
+
+	service := zero
+	if in.Setting.Service_charge_bps != nil && in.Setting.Service_charge_min_guests != nil && in.Guests >= *in.Setting.Service_charge_min_guests {
+		service = net.Percent(*in.Setting.Service_charge_bps)
+	}
+
+	rates := ApplicableTaxRates(in.TaxRates, in.Location, in.Order_type, in.Date)
+	taxes, exclusiveTax, taxTotal := CalculateTaxes(in.Lines, netLines, rates, currency)
+
+	tip := in.Tip
+	if tip.Currency == "" {
+		tip = models.NewMoney(tip.Amount, currency)
+	}
+	total := net.Add(service).Add(exclusiveTax).Add(tip)
+
+	invoice.Line_items = in.Lines
+	invoice.Taxes = taxes
+	invoice.Subtotal = &subtotal
+	invoice.Discount_total = &discount
+	invoice.Service_charge = &service
+	invoice.Tax_total = &taxTotal
+	invoice.Tip = &tip
+	invoice.Total = &total
+}
+
+func allocateDiscount(lines []models.InvoiceLine, discount models.Money) []models.Money {
+	net := make([]models.Money, len(lines))
+	var subtotal int64
+	for _, line := range lines {
+		subtotal += line.Line_total.Amount - line.Discount.Amount
+	}
+	remaining := discount.Amount
+	for i, line := range lines {
+		lineNet := line.Line_total.Sub(line.Discount)
+		share := remaining
+		if i < len(lines)-1 && subtotal != 0 {
+			share = discount.Scale(lineNet.Amount, subtotal).Amount
+		}
+		remaining -= share
+		net[i] = lineNet.Sub(models.NewMoney(share, line.Line_total.Currency))
+	}
+	return net
+}
+
+// BuildInvoice loads the order behind invoice and recalculates its
+// breakdown using the stored tax rates and service charge setting.
+func BuildInvoice(ctx context.Context, invoice *models.Invoice) error {
+	lines, err := InvoiceLinesForOrder(ctx, *invoice.Order_id)
+	if err != nil {
+		return err
+	}
+
+	var in InvoiceInput
+
+	cursor, err := taxRateCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(ctx, &in.TaxRates); err != nil {
+		return err
+	}
+
+	err = settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&in.Setting)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	var order models.Order
+	var table models.Table
+	if err = orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return err
+	}
+	if order.Order_type != nil {
+		in.Order_type = *order.Order_type
+	}
+	if invoice.Customer_id == nil {
+		invoice.Customer_id = order.Customer_id
+	}
+	if order.Table_id != nil {
+		err = tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
+		if err == nil && table.Number_of_guests != nil {
+			in.Guests = *table.Number_of_guests
+		}
+	}
+
+	if invoice.Location == nil {
+		invoice.Location = in.Setting.Location
+	}
+	if invoice.Location != nil {
+		in.Location = *invoice.Location
+	}
+	in.Date = invoice.Created_at
+	if in.Date.IsZero() {
+		in.Date = time.Now()
+	}
+
+	promotions, err := loadPromotions(ctx)
+	if err != nil {
+		return err
+	}
+	in.Lines, invoice.Promotions = ApplyPromotions(lines, promotions, invoice.Coupon_codes, *invoice.Order_id, invoice.Order_item_ids, in.Date)
+
+	// Loyalty redemptions are only set by RedeemLoyalty once the points have
+	// been spent, so anything else on an invoice without spent points is
+	// dropped rather than given away.
+	if invoice.Points_redeemed <= 0 {
+		invoice.Points_discount = nil
+		invoice.Reward_item_ids = nil
+	}
+	in.Lines, invoice.Promotions = applyLoyaltyRewards(in.Lines, invoice.Promotions, invoice.Reward_item_ids)
+
+	if len(in.Lines) > 0 {
+		currency := in.Lines[0].Unit_price.Currency
+		for _, amount := range []*models.Money{invoice.Discount_amount, invoice.Tip} {
+			if amount != nil && amount.IsZero() {
+				amount.Currency = currency
+			}
+			if amount != nil && amount.Currency != currency {
+				return ErrInvoiceCurrency
+			}
+		}
+	}
+
+	if invoice.Discount_bps != nil {
+		in.Discount_bps = *invoice.Discount_bps
+	}
+	if invoice.Discount_amount != nil {
+		in.Discount_amount = *invoice.Discount_amount
+	}
+	if invoice.Points_discount != nil {
+		in.Discount_amount = in.Discount_amount.Add(*invoice.Points_discount)
+	}
+	if invoice.Tip != nil {
+		in.Tip = *invoice.Tip
+	}
+
+	CalculateInvoice(invoice, in)
+	ApplySplitShare(invoice)
+	SettleInvoice(invoice)
+	return nil
+}
+
+// InvoiceLinesForOrder prices every order item of an order, falling back to
+// the food price when the item has no unit price of its own. The price
+// deltas of the item's modifiers are included in its unit price, and the
+// line total is that unit price times the quantity ordered. Voided and
+// comped items are left off the bill.
+func InvoiceLinesForOrder(ctx context.Context, orderId string) ([]models.InvoiceLine, error) {
+	matchStage := bson.D{{"$match", bson.D{{"order_id", orderId}, {"status", bson.D{{"$ne", "VOIDED"}}}, {"adjustment", nil}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+	lookupMenuStage := bson.D{{"$lookup", bson.D{{"from", "menu"}, {"localField", "food.menu_id"}, {"foreignField", "menu_id"}, {"as", "menu"}}}}
+	unwindMenuStage := bson.D{{"$unwind", bson.D{{"path", "$menu"}, {"preserveNullAndEmptyArrays", true}}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"order_item_id", 1},
+		{"food_id", 1},
+		{"name", "$food.name"},
+		{"category", bson.D{{"$ifNull", bson.A{"$menu.category", ""}}}},
+		{"tax_category", bson.D{{"$ifNull", bson.A{"$food.tax_category", ""}}}},
+		{"variant_name", bson.D{{"$ifNull", bson.A{"$variant_name", ""}}}},
+		{"sku", bson.D{{"$ifNull", bson.A{"$sku", ""}}}},
+		{"quantity", bson.D{{"$ifNull", bson.A{"$quantity", 1}}}},
+		{"unit_price", bson.D{{"$ifNull", bson.A{"$unit_price", "$food.price"}}}},
+		{"modifiers", 1},
+		{"ordered_at", "$created_at"},
+	}}}
+
+	cursor, err := orderitemCollection.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		lookupStage,
+		unwindStage,
+		lookupMenuStage,
+		unwindMenuStage,
+		projectStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []models.InvoiceLine
+	if err = cursor.All(ctx, &lines); err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].Unit_price = lines[i].Unit_price.Add(ModifiersTotal(lines[i].Modifiers, lines[i].Unit_price.Currency))
+		lines[i].Line_total = lines[i].Unit_price.Mul(lines[i].Quantity)
+	}
+	return lines, nil
+}
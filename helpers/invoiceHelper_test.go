@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func testLine(amount int64) models.InvoiceLine {
+	price := models.NewMoney(amount, "USD")
+	return models.InvoiceLine{Quantity: 1, Unit_price: price, Line_total: price}
+}
+
+func testTaxRate(name string, bps int64, inclusive bool, compound bool, priority int) models.TaxRate {
+	return models.TaxRate{Tax_rate_id: name, Name: &name, Rate_bps: &bps, Inclusive: &inclusive, Compound: &compound, Priority: &priority}
+}
+
+func TestCalculateInvoice(t *testing.T) {
+	serviceBps := int64(1000)
+	minGuests := 6
+	serviceCharge := models.Setting{Service_charge_bps: &serviceBps, Service_charge_min_guests: &minGuests}
+
+	tests := []struct {
+		name         string
+		lines        []models.InvoiceLine
+		rates        []models.TaxRate
+		setting      models.Setting
+		guests       int
+		discountBps  int64
+		wantDiscount int64
+		wantService  int64
+		wantTaxes    []int64
+		wantTaxTotal int64
+		wantTotal    int64
+	}{
+		{
+			name:         "exclusive tax is added on top",
+			lines:        []models.InvoiceLine{testLine(1000)},
+			rates:        []models.TaxRate{testTaxRate("VAT", 1000, false, false, 0)},
+			wantTaxes:    []int64{100},
+			wantTaxTotal: 100,
+			wantTotal:    1100,
+		},
+		{
+			name:         "inclusive tax is backed out of the price",
+			lines:        []models.InvoiceLine{testLine(1200)},
+			rates:        []models.TaxRate{testTaxRate("VAT", 2000, true, false, 0)},
+			wantTaxes:    []int64{200},
+			wantTaxTotal: 200,
+			wantTotal:    1200,
+		},
+		{
+			name:  "compound tax is charged on the earlier tax",
+			lines: []models.InvoiceLine{testLine(1000)},
+			rates: []models.TaxRate{
+				testTaxRate("PST", 800, false, true, 2),
+				testTaxRate("GST", 500, false, false, 1),
+			},
+			wantTaxes:    []int64{50, 84},
+			wantTaxTotal: 134,
+			wantTotal:    1134,
+		},
+		{
+			name:        "service charge once the party is large enough",
+			lines:       []models.InvoiceLine{testLine(2000)},
+			setting:     serviceCharge,
+			guests:      6,
+			wantService: 200,
+			wantTaxes:   []int64{},
+			wantTotal:   2200,
+		},
+		{
+			name:      "no service charge for a smaller party",
+			lines:     []models.InvoiceLine{testLine(2000)},
+			setting:   serviceCharge,
+			guests:    5,
+			wantTaxes: []int64{},
+			wantTotal: 2000,
+		},
+		{
+			name:         "discount comes off before tax",
+			lines:        []models.InvoiceLine{testLine(1000)},
+			rates:        []models.TaxRate{testTaxRate("VAT", 1000, false, false, 0)},
+			discountBps:  1000,
+			wantDiscount: 100,
+			wantTaxes:    []int64{90},
+			wantTaxTotal: 90,
+			wantTotal:    990,
+		},
+		{
+			name:         "tax is rounded once per rate, not per line",
+			lines:        []models.InvoiceLine{testLine(100), testLine(100), testLine(100)},
+			rates:        []models.TaxRate{testTaxRate("VAT", 450, false, false, 0)},
+			wantTaxes:    []int64{14},
+			wantTaxTotal: 14,
+			wantTotal:    314,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var invoice models.Invoice
+			CalculateInvoice(&invoice, InvoiceInput{
+				Lines:        tt.lines,
+				TaxRates:     tt.rates,
+				Setting:      tt.setting,
+				Guests:       tt.guests,
+				Discount_bps: tt.discountBps,
+				Currency:     "USD",
+			})
+
+			if invoice.Discount_total.Amount != tt.wantDiscount {
+				t.Errorf("discount %d, want %d", invoice.Discount_total.Amount, tt.wantDiscount)
+			}
+			if invoice.Service_charge.Amount != tt.wantService {
+				t.Errorf("service charge %d, want %d", invoice.Service_charge.Amount, tt.wantService)
+			}
+			if len(invoice.Taxes) != len(tt.wantTaxes) {
+				t.Fatalf("got %d taxes, want %d", len(invoice.Taxes), len(tt.wantTaxes))
+			}
+			for i, tax := range invoice.Taxes {
+				if tax.Amount.Amount != tt.wantTaxes[i] {
+					t.Errorf("%s %d, want %d", tax.Name, tax.Amount.Amount, tt.wantTaxes[i])
+				}
+			}
+			if invoice.Tax_total.Amount != tt.wantTaxTotal {
+				t.Errorf("tax total %d, want %d", invoice.Tax_total.Amount, tt.wantTaxTotal)
+			}
+			if invoice.Total.Amount != tt.wantTotal || invoice.Total.Currency != "USD" {
+				t.Errorf("total %v, want %d USD", *invoice.Total, tt.wantTotal)
+			}
+		})
+	}
+}
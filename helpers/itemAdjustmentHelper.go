@@ -0,0 +1,240 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultReasonCodes are offered until the restaurant configures its own.
+var DefaultReasonCodes = []models.ReasonCode{
+	{Code: "ENTRY_ERROR", Label: "Entered by mistake", Kind: "VOID"},
+	{Code: "GUEST_CHANGED_MIND", Label: "Guest changed their mind", Kind: "VOID"},
+	{Code: "OUT_OF_STOCK", Label: "Out of stock", Kind: "VOID"},
+	{Code: "QUALITY", Label: "Quality complaint", Kind: "COMP"},
+	{Code: "LONG_WAIT", Label: "Long wait", Kind: "COMP"},
+	{Code: "HOSPITALITY", Label: "On the house", Kind: "COMP"},
+}
+
+// ReasonCodes returns the configured void and comp reasons, or the defaults
+// when none have been set up.
+func ReasonCodes(ctx context.Context) []models.ReasonCode {
+	var setting models.Setting
+	err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting)
+	if err != nil || len(setting.Reason_codes) == 0 {
+		return DefaultReasonCodes
+	}
+	return setting.Reason_codes
+}
+
+func FindReasonCode(ctx context.Context, kind string, code string) (models.ReasonCode, bool) {
+	for _, reason := range ReasonCodes(ctx) {
+		if reason.Kind == kind && reason.Code == code {
+			return reason, true
+		}
+	}
+	return models.ReasonCode{}, false
+}
+
+// OrderItemAmount is what an item is billed at: its unit price, or the food
+// price for items priced before unit prices were stored, plus its modifiers,
+// times the quantity.
+func OrderItemAmount(ctx context.Context, item models.OrderItem) models.Money {
+	var unit models.Money
+	if item.Unit_price != nil {
+		unit = *item.Unit_price
+	} else {
+		var food models.Food
+		if err := foodCollection.FindOne(ctx, bson.M{"food_id": item.Food_id}).Decode(&food); err == nil && food.Price != nil {
+			unit = *food.Price
+		}
+	}
+	unit = models.NewMoney(unit.Amount, unit.Currency).Add(ModifiersTotal(item.Modifiers, unit.Currency))
+	quantity := int64(1)
+	if item.Quantity != nil {
+		quantity = *item.Quantity
+	}
+	return unit.Mul(quantity)
+}
+
+// CanVoidOrderItem reports whether the kitchen has yet to start on an item,
+// which is when it can still be voided. Afterwards it can only be comped.
+func CanVoidOrderItem(item models.OrderItem) bool {
+	return OrderItemStatus(item) == ItemFlow[0]
+}
+
+func CanCompOrderItem(item models.OrderItem) bool {
+	status := OrderItemStatus(item)
+	return status != ItemFlow[0] && status != "VOIDED"
+}
+
+// VoidOrderItem takes an item the kitchen has not started on off the order.
+// The item stays on record as VOIDED with the adjustment explaining why.
+func VoidOrderItem(ctx context.Context, orderItemId string, adjustment models.ItemAdjustment) error {
+	adjustment.Kind = "VOID"
+	filter := bson.M{
+		"order_item_id": orderItemId,
+		"status":        bson.M{"$in": bson.A{ItemFlow[0], nil}},
+		"adjustment":    nil,
+	}
+	update := bson.D{{"$set", bson.D{
+		{"status", "VOIDED"},
+		{"voided_at", adjustment.Created_at},
+		{"adjustment", adjustment},
+		{"updated_at", adjustment.Created_at},
+	}}}
+	if err := adjustOrderItem(ctx, filter, update); err != nil {
+		return err
+	}
+	PublishOrderItemEvent(ctx, "order_item.status", orderItemId)
+	return nil
+}
+
+// CompOrderItem gives away an item the kitchen has already started on. It
+// keeps moving through the kitchen but is no longer billed.
+func CompOrderItem(ctx context.Context, orderItemId string, adjustment models.ItemAdjustment) error {
+	adjustment.Kind = "COMP"
+	filter := bson.M{
+		"order_item_id": orderItemId,
+		"status":        bson.M{"$in": bson.A{ItemFlow[1], ItemFlow[2], ItemFlow[3]}},
+		"adjustment":    nil,
+	}
+	update := bson.D{{"$set", bson.D{
+		{"adjustment", adjustment},
+		{"updated_at", adjustment.Created_at},
+	}}}
+	return adjustOrderItem(ctx, filter, update)
+}
+
+func adjustOrderItem(ctx context.Context, filter bson.M, update bson.D) error {
+	result, err := orderitemCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderItemChanged
+	}
+	return nil
+}
+
+// VoidCompReport gathers the voids and comps made between from and to.
+func VoidCompReport(ctx context.Context, from time.Time, to time.Time) (models.VoidCompReport, error) {
+	report := models.VoidCompReport{
+		From:        from,
+		To:          to,
+		Voids:       models.AdjustmentTotal{Kind: "VOID"},
+		Comps:       models.AdjustmentTotal{Kind: "COMP"},
+		Adjustments: []models.OrderItemAdjustment{},
+	}
+
+	opts := options.Find().SetSort(bson.D{{"adjustment.created_at", 1}})
+	cursor, err := orderitemCollection.Find(ctx, bson.M{"adjustment.created_at": bson.M{"$gte": from, "$lt": to}}, opts)
+	if err != nil {
+		return report, err
+	}
+	var items []models.OrderItem
+	if err = cursor.All(ctx, &items); err != nil {
+		return report, err
+	}
+
+	names, err := foodNames(ctx, items)
+	if err != nil {
+		return report, err
+	}
+
+	byReason := map[string]*models.AdjustmentTotal{}
+	byStaff := map[string]*models.AdjustmentTotal{}
+	for _, item := range items {
+		adjustment := *item.Adjustment
+		quantity := int64(1)
+		if item.Quantity != nil {
+			quantity = *item.Quantity
+		}
+		entry := models.OrderItemAdjustment{
+			Order_item_id: item.Order_item_id,
+			Adjustment:    adjustment,
+			Quantity:      quantity,
+		}
+		if item.Order_id != nil {
+			entry.Order_id = *item.Order_id
+		}
+		if item.Food_id != nil {
+			entry.Food_id = *item.Food_id
+			entry.Name = names[*item.Food_id]
+		}
+		report.Adjustments = append(report.Adjustments, entry)
+
+		if adjustment.Kind == "VOID" {
+			addAdjustment(&report.Voids, adjustment.Amount)
+		} else {
+			addAdjustment(&report.Comps, adjustment.Amount)
+		}
+
+		reasonKey := adjustment.Kind + "|" + adjustment.Reason_code
+		if byReason[reasonKey] == nil {
+			byReason[reasonKey] = &models.AdjustmentTotal{Kind: adjustment.Kind, Reason_code: adjustment.Reason_code, Reason_label: adjustment.Reason_label}
+		}
+		addAdjustment(byReason[reasonKey], adjustment.Amount)
+
+		staffKey := adjustment.Kind + "|" + adjustment.Requested_by
+		if byStaff[staffKey] == nil {
+			byStaff[staffKey] = &models.AdjustmentTotal{Kind: adjustment.Kind, Requested_by: adjustment.Requested_by}
+		}
+		addAdjustment(byStaff[staffKey], adjustment.Amount)
+	}
+
+	report.By_reason = sortedAdjustmentTotals(byReason)
+	report.By_staff = sortedAdjustmentTotals(byStaff)
+	return report, nil
+}
+
+func addAdjustment(total *models.AdjustmentTotal, amount models.Money) {
+	total.Count++
+	total.Amount = total.Amount.Add(amount)
+}
+
+// sortedAdjustmentTotals puts the biggest losses first.
+func sortedAdjustmentTotals(totals map[string]*models.AdjustmentTotal) []models.AdjustmentTotal {
+	sorted := []models.AdjustmentTotal{}
+	for _, total := range totals {
+		sorted = append(sorted, *total)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Amount.Amount != sorted[j].Amount.Amount {
+			return sorted[i].Amount.Amount > sorted[j].Amount.Amount
+		}
+		return sorted[i].Count > sorted[j].Count
+	})
+	return sorted
+}
+
+func foodNames(ctx context.Context, items []models.OrderItem) (map[string]string, error) {
+	ids := bson.A{}
+	for _, item := range items {
+		if item.Food_id != nil {
+			ids = append(ids, *item.Food_id)
+		}
+	}
+	names := map[string]string{}
+	if len(ids) == 0 {
+		return names, nil
+	}
+	cursor, err := foodCollection.Find(ctx, bson.M{"food_id": bson.M{"$in": ids}})
+	if err != nil {
+		return names, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return names, err
+	}
+	for _, food := range foods {
+		if food.Name != nil {
+			names[food.Food_id] = *food.Name
+		}
+	}
+	return names, nil
+}
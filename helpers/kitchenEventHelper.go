@@ -0,0 +1,187 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var kitchenEventCollection *mongo.Collection = database.OpenCollection(database.Client, "kitchen_event")
+
+const kitchenEventBuffer = 64
+
+// kitchenSubscriber is one connected display. Its channel is closed when
+// the display falls too far behind; it then reconnects and replays what it
+// missed from the stored events.
+type kitchenSubscriber struct {
+	station string
+	events  chan models.KitchenEvent
+}
+
+// kitchenBroker fans events out to displays in Event_id order. Events are
+// numbered and stored outside the lock, so one can arrive before an
+// earlier one; it waits in pending until the gap is filled. A nil pending
+// entry is a number whose event was never stored and is skipped.
+var kitchenBroker = struct {
+	sync.Mutex
+	subscribers map[*kitchenSubscriber]bool
+	next        int64
+	pending     map[int64]*models.KitchenEvent
+}{subscribers: map[*kitchenSubscriber]bool{}, pending: map[int64]*models.KitchenEvent{}}
+
+// SubscribeKitchen registers a display for live events of a station, or of
+// every station when station is "". The returned function unsubscribes it.
+func SubscribeKitchen(station string) (<-chan models.KitchenEvent, func()) {
+	subscriber := &kitchenSubscriber{station: station, events: make(chan models.KitchenEvent, kitchenEventBuffer)}
+
+	kitchenBroker.Lock()
+	kitchenBroker.subscribers[subscriber] = true
+	kitchenBroker.Unlock()
+
+	return subscriber.events, func() {
+		kitchenBroker.Lock()
+		defer kitchenBroker.Unlock()
+		if kitchenBroker.subscribers[subscriber] {
+			delete(kitchenBroker.subscribers, subscriber)
+			close(subscriber.events)
+		}
+	}
+}
+
+// KitchenEventsSince returns the stored events after lastEventId for a
+// station, oldest first, so a reconnecting display can catch up.
+func KitchenEventsSince(ctx context.Context, station string, lastEventId int64) ([]models.KitchenEvent, error) {
+	filter := bson.M{"event_id": bson.M{"$gt": lastEventId}}
+	if station != "" {
+		filter["station"] = bson.M{"$in": bson.A{station, nil}}
+	}
+	cursor, err := kitchenEventCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{"event_id", 1}}).SetLimit(1000))
+	if err != nil {
+		return nil, err
+	}
+	events := []models.KitchenEvent{}
+	err = cursor.All(ctx, &events)
+	return events, err
+}
+
+// KitchenEventVisible reports whether a display for station shows event.
+func KitchenEventVisible(event models.KitchenEvent, station string) bool {
+	return station == "" || event.Station == nil || *event.Station == station
+}
+
+// PublishKitchenEvent numbers, stores and broadcasts an event. Only the
+// fan-out holds the broker lock, which hands events to displays in
+// Event_id order. A failure is logged rather than returned: the change it
+// describes has already been saved and displays will pick it up on their
+// next reload.
+func PublishKitchenEvent(ctx context.Context, event models.KitchenEvent) {
+	seq, err := nextSequence(ctx, "kitchen_event")
+	if err != nil {
+		log.Println("kitchen event:", err)
+		return
+	}
+	event.ID = primitive.NewObjectID()
+	event.Event_id = seq
+	event.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err = kitchenEventCollection.InsertOne(ctx, event); err != nil {
+		log.Println("kitchen event:", err)
+		fanOutKitchenEvent(seq, nil)
+		return
+	}
+	fanOutKitchenEvent(seq, &event)
+}
+
+// fanOutKitchenEvent queues the event numbered seq and delivers every
+// queued event that is now next in line. If the queue grows past a
+// display's buffer the missing number is given up on, so one lost
+// publisher cannot stall every display.
+func fanOutKitchenEvent(seq int64, event *models.KitchenEvent) {
+	kitchenBroker.Lock()
+	defer kitchenBroker.Unlock()
+
+	if kitchenBroker.next == 0 || seq < kitchenBroker.next {
+		if event != nil {
+			deliverKitchenEvent(*event)
+		}
+		if kitchenBroker.next == 0 {
+			kitchenBroker.next = seq + 1
+		}
+		return
+	}
+
+	kitchenBroker.pending[seq] = event
+	if len(kitchenBroker.pending) > kitchenEventBuffer {
+		oldest := seq
+		for id := range kitchenBroker.pending {
+			if id < oldest {
+				oldest = id
+			}
+		}
+		kitchenBroker.next = oldest
+	}
+	for {
+		queued, ok := kitchenBroker.pending[kitchenBroker.next]
+		if !ok {
+			return
+		}
+		delete(kitchenBroker.pending, kitchenBroker.next)
+		kitchenBroker.next++
+		if queued != nil {
+			deliverKitchenEvent(*queued)
+		}
+	}
+}
+
+// deliverKitchenEvent hands an event to every display that shows it. The
+// caller holds the broker lock.
+func deliverKitchenEvent(event models.KitchenEvent) {
+	for subscriber := range kitchenBroker.subscribers {
+		if !KitchenEventVisible(event, subscriber.station) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			delete(kitchenBroker.subscribers, subscriber)
+			close(subscriber.events)
+		}
+	}
+}
+
+// PublishOrderEvent announces a change to a whole order to every station.
+func PublishOrderEvent(ctx context.Context, eventType string, orderId string, status string) {
+	PublishKitchenEvent(ctx, models.KitchenEvent{Type: eventType, Order_id: orderId, Status: status})
+}
+
+// PublishOrderItemEvent announces a change to one item to the station that
+// prepares it, read back from the database so the event carries the
+// item's current status.
+func PublishOrderItemEvent(ctx context.Context, eventType string, orderItemId string) {
+	var item models.OrderItem
+	if err := orderitemCollection.FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&item); err != nil {
+		log.Println("kitchen event:", err)
+		return
+	}
+	event := models.KitchenEvent{
+		Type:          eventType,
+		Order_item_id: &item.Order_item_id,
+		Food_id:       item.Food_id,
+		Status:        OrderItemStatus(item),
+	}
+	if item.Order_id != nil {
+		event.Order_id = *item.Order_id
+	}
+	event.Station = item.Station_id
+	if event.Station == nil && item.Food_id != nil {
+		event.Station = StationFor(ctx, *item.Food_id)
+	}
+	PublishKitchenEvent(ctx, event)
+}
@@ -0,0 +1,42 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestFanOutKitchenEventKeepsOrder(t *testing.T) {
+	kitchenBroker.Lock()
+	kitchenBroker.next = 0
+	kitchenBroker.pending = map[int64]*models.KitchenEvent{}
+	kitchenBroker.Unlock()
+
+	events, unsubscribe := SubscribeKitchen("")
+	defer unsubscribe()
+
+	publish := func(seq int64, stored bool) {
+		var event *models.KitchenEvent
+		if stored {
+			event = &models.KitchenEvent{Event_id: seq}
+		}
+		fanOutKitchenEvent(seq, event)
+	}
+
+	publish(10, true)
+	publish(12, true)
+	publish(11, false)
+	publish(14, true)
+	publish(13, true)
+
+	for _, want := range []int64{10, 12, 13, 14} {
+		got := <-events
+		if got.Event_id != want {
+			t.Fatalf("got event %d, want %d", got.Event_id, want)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("unexpected event %d", event.Event_id)
+	default:
+	}
+}
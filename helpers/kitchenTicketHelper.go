@@ -0,0 +1,151 @@
+package helpers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type KitchenItem struct {
+	Order_item_id string   `json:"order_item_id"`
+	Food_id       string   `json:"food_id"`
+	Name          string   `json:"name"`
+	Category      string   `json:"category"`
+	Station_id    string   `json:"station_id"`
+	Quantity      int64    `json:"quantity"`
+	Variant_name  string   `json:"variant_name"`
+	Seat_number   *int     `json:"seat_number"`
+	Modifiers     []string `json:"modifiers"`
+	Notes         []string `json:"notes"`
+}
+
+type KitchenTicket struct {
+	Order_id     string
+	Table_number *int
+	Server       string
+	Allergies    []string
+	Notes        []string
+	Printed_at   time.Time
+	Items        []KitchenItem
+}
+
+// KitchenItems loads the order items of an order with the food name and
+// menu category the kitchen needs to prepare and route them. Items held
+// for a later course are left out until it is fired.
+func KitchenItems(ctx context.Context, orderId string) ([]KitchenItem, error) {
+	matchStage := bson.D{{"$match", bson.D{{"order_id", orderId}, {"hold", bson.D{{"$ne", true}}}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+	lookupMenuStage := bson.D{{"$lookup", bson.D{{"from", "menu"}, {"localField", "food.menu_id"}, {"foreignField", "menu_id"}, {"as", "menu"}}}}
+	unwindMenuStage := bson.D{{"$unwind", bson.D{{"path", "$menu"}, {"preserveNullAndEmptyArrays", true}}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"order_item_id", 1},
+		{"food_id", 1},
+		{"name", "$food.name"},
+		{"category", bson.D{{"$ifNull", bson.A{"$menu.category", ""}}}},
+		{"station_id", bson.D{{"$ifNull", bson.A{"$station_id", ""}}}},
+		{"quantity", bson.D{{"$ifNull", bson.A{"$quantity", 1}}}},
+		{"variant_name", bson.D{{"$ifNull", bson.A{"$variant_name", ""}}}},
+		{"seat_number", 1},
+		{"modifiers", "$modifiers.name"},
+	}}}
+
+	cursor, err := orderitemCollection.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		lookupStage,
+		unwindStage,
+		lookupMenuStage,
+		unwindMenuStage,
+		projectStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []KitchenItem
+	err = cursor.All(ctx, &items)
+	return items, err
+}
+
+// KitchenTicketEscPos renders a kitchen ticket with large table and item
+// text so it can be read from across the pass.
+func KitchenTicketEscPos(ticket KitchenTicket, width int) []byte {
+	e := NewEscPos()
+	e.Align(escposAlignCenter).DoubleSize(true).Bold(true)
+	if ticket.Table_number != nil {
+		e.Line("TABLE " + strconv.Itoa(*ticket.Table_number))
+	} else {
+		e.Line("TAKEAWAY")
+	}
+	e.DoubleSize(false).Bold(false)
+	e.Line("Order " + shortId(ticket.Order_id))
+	e.Line(ticket.Printed_at.Format("15:04 02/01"))
+	if ticket.Server != "" {
+		e.Line("Server: " + ticket.Server)
+	}
+	if len(ticket.Allergies) > 0 {
+		e.Bold(true).Line(truncate("ALLERGY: "+strings.Join(ticket.Allergies, ", "), width)).Bold(false)
+	}
+	for _, note := range ticket.Notes {
+		e.Line(truncate("NOTE: "+note, width))
+	}
+	e.Align(escposAlignLeft).Rule(width)
+
+	for _, item := range ticket.Items {
+		e.DoubleSize(true).Bold(true)
+		e.Line(truncate(kitchenItemLabel(item), width/2))
+		e.DoubleSize(false).Bold(false)
+		for _, modifier := range item.Modifiers {
+			e.Bold(true).Line(truncate("  > "+modifier, width)).Bold(false)
+		}
+		for _, note := range item.Notes {
+			e.Line(truncate("  * "+note, width))
+		}
+		if item.Seat_number != nil {
+			e.Line("  seat " + strconv.Itoa(*item.Seat_number))
+		}
+	}
+
+	e.Rule(width).Feed(3).Cut()
+	return e.Bytes()
+}
+
+// ReceiptEscPos prints the same layout as the text receipt, with the
+// restaurant name enlarged and the total in bold.
+func ReceiptEscPos(receipt Receipt, width int) []byte {
+	e := NewEscPos()
+	if receipt.Branding.Restaurant_name != "" {
+		e.Align(escposAlignCenter).DoubleSize(true).Line(truncate(receipt.Branding.Restaurant_name, width/2)).DoubleSize(false)
+		receipt.Branding.Restaurant_name = ""
+	}
+	e.Align(escposAlignLeft)
+	for _, line := range strings.Split(strings.TrimRight(ReceiptText(receipt, width), "\n"), "\n") {
+		strong := strings.HasPrefix(line, "TOTAL ")
+		e.Bold(strong).Line(line).Bold(false)
+	}
+	e.Feed(4).Cut()
+	return e.Bytes()
+}
+
+func kitchenItemLabel(item KitchenItem) string {
+	label := item.Name
+	if item.Variant_name != "" {
+		label += " (" + item.Variant_name + ")"
+	}
+	if item.Quantity > 0 {
+		label = strconv.FormatInt(item.Quantity, 10) + "x " + label
+	}
+	return label
+}
+
+func shortId(id string) string {
+	if len(id) > 6 {
+		return id[len(id)-6:]
+	}
+	return id
+}
@@ -0,0 +1,337 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var customerCollection *mongo.Collection = database.OpenCollection(database.Client, "customer")
+var loyaltyTransactionCollection *mongo.Collection = database.OpenCollection(database.Client, "loyaltytransaction")
+
+var ErrNotEnoughPoints = errors.New("customer does not have enough points")
+
+// LoyaltyProgram returns the configured loyalty program, or nil when the
+// restaurant has not set one up.
+func LoyaltyProgram(ctx context.Context) *models.LoyaltyProgram {
+	var setting models.Setting
+	if err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting); err != nil {
+		return nil
+	}
+	return setting.Loyalty
+}
+
+// EarnedPoints works out the points an invoice earns: points per whole
+// currency unit spent after discounts, excluding tax, service and tip, plus
+// the bonus points of any bonus items, all scaled by the tier multiplier.
+// Items given away as loyalty rewards earn nothing.
+func EarnedPoints(program models.LoyaltyProgram, invoice models.Invoice, tier *models.LoyaltyTier) int64 {
+	var points int64
+	if program.Points_per_unit != nil && invoice.Subtotal != nil {
+		spent := *invoice.Subtotal
+		if invoice.Discount_total != nil {
+			spent = spent.Sub(*invoice.Discount_total)
+		}
+		if spent.Amount > 0 {
+			points = spent.Amount * *program.Points_per_unit / int64(math.Pow10(models.MinorUnits))
+		}
+	}
+
+	rewarded := map[string]bool{}
+	for _, id := range invoice.Reward_item_ids {
+		rewarded[id] = true
+	}
+	for _, line := range invoice.Line_items {
+		if rewarded[line.Order_item_id] {
+			continue
+		}
+		for _, bonus := range program.Bonus_items {
+			if bonus.Food_id == line.Food_id {
+				points += bonus.Points * line.Quantity
+			}
+		}
+	}
+
+	if tier != nil && tier.Multiplier_bps > 0 {
+		points = points * tier.Multiplier_bps / 10000
+	}
+	return points
+}
+
+// TierFor returns the highest tier whose threshold the lifetime points reach.
+func TierFor(program models.LoyaltyProgram, lifetimePoints int64) *models.LoyaltyTier {
+	var best *models.LoyaltyTier
+	for i, tier := range program.Tiers {
+		if lifetimePoints >= tier.Min_points && (best == nil || tier.Min_points > best.Min_points) {
+			best = &program.Tiers[i]
+		}
+	}
+	return best
+}
+
+// AwardLoyaltyPoints credits the customer of a paid invoice with the points
+// it earned. An invoice only ever earns once, however often it is settled.
+func AwardLoyaltyPoints(ctx context.Context, invoice models.Invoice) error {
+	if invoice.Customer_id == nil || invoice.Points_earned != nil {
+		return nil
+	}
+	program := LoyaltyProgram(ctx)
+	if program == nil {
+		return nil
+	}
+
+	var customer models.Customer
+	if err := customerCollection.FindOne(ctx, bson.M{"customer_id": invoice.Customer_id}).Decode(&customer); err != nil {
+		return err
+	}
+	points := EarnedPoints(*program, invoice, TierFor(*program, customer.Lifetime_points))
+
+	result, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id, "points_earned": nil}, bson.D{{"$set", bson.D{{"points_earned", points}}}})
+	if err != nil || result.MatchedCount == 0 || points <= 0 {
+		return err
+	}
+
+	lot := newLoyaltyTransaction(customer.Customer_id, "EARN", points, &invoice.Invoice_id, "")
+	lot.Remaining = points
+	if program.Expiry_days != nil {
+		expiresAt := lot.Created_at.AddDate(0, 0, *program.Expiry_days)
+		lot.Expires_at = &expiresAt
+	}
+	if _, err = loyaltyTransactionCollection.InsertOne(ctx, lot); err != nil {
+		return err
+	}
+
+	after := options.After
+	err = customerCollection.FindOneAndUpdate(ctx, bson.M{"customer_id": customer.Customer_id},
+		bson.M{"$inc": bson.M{"points_balance": points, "lifetime_points": points}},
+		&options.FindOneAndUpdateOptions{ReturnDocument: &after},
+	).Decode(&customer)
+	if err != nil {
+		return err
+	}
+
+	if tier := TierFor(*program, customer.Lifetime_points); tier != nil && (customer.Tier == nil || *customer.Tier != tier.Name) {
+		_, err = customerCollection.UpdateOne(ctx, bson.M{"customer_id": customer.Customer_id}, bson.D{{"$set", bson.D{{"tier", tier.Name}}}})
+	}
+	return err
+}
+
+// SpendLoyaltyPoints takes points off a customer's balance. The balance
+// check and the deduction are one conditional update, and the points are
+// used up oldest first so expiry only hits points that were never spent.
+func SpendLoyaltyPoints(ctx context.Context, customerId string, points int64, invoiceId *string, note string) error {
+	result, err := customerCollection.UpdateOne(ctx,
+		bson.M{"customer_id": customerId, "points_balance": bson.M{"$gte": points}},
+		bson.M{"$inc": bson.M{"points_balance": -points}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotEnoughPoints
+	}
+
+	consumeLoyaltyLots(ctx, customerId, points)
+	_, err = loyaltyTransactionCollection.InsertOne(ctx, newLoyaltyTransaction(customerId, "REDEEM", -points, invoiceId, note))
+	return err
+}
+
+// ReturnLoyaltyPoints undoes the loyalty side of a voided or fully refunded
+// invoice: points spent on it go back to the customer and points it earned
+// are taken away again, never leaving the balance below zero.
+func ReturnLoyaltyPoints(ctx context.Context, invoice models.Invoice) error {
+	if invoice.Customer_id == nil {
+		return nil
+	}
+	result, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id, "loyalty_reversed": bson.M{"$ne": true}}, bson.D{{"$set", bson.D{{"loyalty_reversed", true}}}})
+	if err != nil || result.MatchedCount == 0 {
+		return err
+	}
+
+	if invoice.Points_redeemed > 0 {
+		if err = CreditLoyaltyPoints(ctx, *invoice.Customer_id, invoice.Points_redeemed, &invoice.Invoice_id, "RETURN"); err != nil {
+			return err
+		}
+	}
+
+	if invoice.Points_earned != nil && *invoice.Points_earned > 0 {
+		err = deductEarnedPoints(ctx, *invoice.Customer_id, invoice.Invoice_id, *invoice.Points_earned)
+	}
+	return err
+}
+
+// ReverseEarnedPoints takes back the points a paid invoice earned when one
+// of its payments is voided. The invoice forgets it earned them, so they
+// are awarded again once it is paid in full.
+func ReverseEarnedPoints(ctx context.Context, invoice models.Invoice) error {
+	if invoice.Customer_id == nil || invoice.Points_earned == nil {
+		return nil
+	}
+	earned := *invoice.Points_earned
+	result, err := invoiceCollection.UpdateOne(ctx, bson.M{"invoice_id": invoice.Invoice_id, "points_earned": earned}, bson.D{{"$set", bson.D{{"points_earned", nil}}}})
+	if err != nil || result.MatchedCount == 0 || earned <= 0 {
+		return err
+	}
+	return deductEarnedPoints(ctx, *invoice.Customer_id, invoice.Invoice_id, earned)
+}
+
+// deductEarnedPoints removes points an invoice earned from the customer,
+// never leaving the balance or lifetime points below zero.
+func deductEarnedPoints(ctx context.Context, customerId string, invoiceId string, earned int64) error {
+	_, err := customerCollection.UpdateOne(ctx, bson.M{"customer_id": customerId}, mongo.Pipeline{
+		{{"$set", bson.D{
+			{"points_balance", bson.D{{"$max", bson.A{0, bson.D{{"$subtract", bson.A{"$points_balance", earned}}}}}}},
+			{"lifetime_points", bson.D{{"$max", bson.A{0, bson.D{{"$subtract", bson.A{"$lifetime_points", earned}}}}}}},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	consumeLoyaltyLots(ctx, customerId, earned)
+	_, err = loyaltyTransactionCollection.InsertOne(ctx, newLoyaltyTransaction(customerId, "REVERSE", -earned, &invoiceId, ""))
+	return err
+}
+
+// CreditLoyaltyPoints gives points back to a customer as a new lot with the
+// program's usual expiry. It does not count towards the customer's tier.
+func CreditLoyaltyPoints(ctx context.Context, customerId string, points int64, invoiceId *string, kind string) error {
+	lot := newLoyaltyTransaction(customerId, kind, points, invoiceId, "")
+	lot.Remaining = points
+	if program := LoyaltyProgram(ctx); program != nil && program.Expiry_days != nil {
+		expiresAt := lot.Created_at.AddDate(0, 0, *program.Expiry_days)
+		lot.Expires_at = &expiresAt
+	}
+	if _, err := loyaltyTransactionCollection.InsertOne(ctx, lot); err != nil {
+		return err
+	}
+	_, err := customerCollection.UpdateOne(ctx, bson.M{"customer_id": customerId}, bson.M{"$inc": bson.M{"points_balance": points}})
+	return err
+}
+
+// ExpireLoyaltyPoints removes the unspent part of every earned lot past its
+// expiry date from the customer's balance.
+func ExpireLoyaltyPoints(ctx context.Context) (int, error) {
+	cursor, err := loyaltyTransactionCollection.Find(ctx, bson.M{"remaining": bson.M{"$gt": 0}, "expires_at": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	var lots []models.LoyaltyTransaction
+	if err = cursor.All(ctx, &lots); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, lot := range lots {
+		result, err := loyaltyTransactionCollection.UpdateOne(ctx,
+			bson.M{"loyalty_transaction_id": lot.Loyalty_transaction_id, "remaining": lot.Remaining},
+			bson.D{{"$set", bson.D{{"remaining", 0}}}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			continue
+		}
+		_, err = customerCollection.UpdateOne(ctx, bson.M{"customer_id": lot.Customer_id}, mongo.Pipeline{
+			{{"$set", bson.D{{"points_balance", bson.D{{"$max", bson.A{0, bson.D{{"$subtract", bson.A{"$points_balance", lot.Remaining}}}}}}}}}},
+		})
+		if err != nil {
+			log.Println("could not expire loyalty points", lot.Loyalty_transaction_id, err)
+			continue
+		}
+		loyaltyTransactionCollection.InsertOne(ctx, newLoyaltyTransaction(lot.Customer_id, "EXPIRE", -lot.Remaining, lot.Invoice_id, ""))
+		expired++
+	}
+	return expired, nil
+}
+
+// RunLoyaltyExpiry expires loyalty points once an hour until ctx is done.
+func RunLoyaltyExpiry(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if _, err := ExpireLoyaltyPoints(ctx); err != nil {
+			log.Println("loyalty expiry failed", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// consumeLoyaltyLots marks points as used on the customer's oldest lots
+// first.
+func consumeLoyaltyLots(ctx context.Context, customerId string, points int64) {
+	opts := options.Find().SetSort(bson.D{{"created_at", 1}})
+	cursor, err := loyaltyTransactionCollection.Find(ctx, bson.M{"customer_id": customerId, "remaining": bson.M{"$gt": 0}}, opts)
+	if err != nil {
+		return
+	}
+	var lots []models.LoyaltyTransaction
+	if err = cursor.All(ctx, &lots); err != nil {
+		return
+	}
+	for _, lot := range lots {
+		if points <= 0 {
+			return
+		}
+		used := lot.Remaining
+		if used > points {
+			used = points
+		}
+		result, err := loyaltyTransactionCollection.UpdateOne(ctx,
+			bson.M{"loyalty_transaction_id": lot.Loyalty_transaction_id, "remaining": lot.Remaining},
+			bson.M{"$inc": bson.M{"remaining": -used}},
+		)
+		if err == nil && result.MatchedCount == 1 {
+			points -= used
+		}
+	}
+}
+
+func newLoyaltyTransaction(customerId string, kind string, points int64, invoiceId *string, note string) models.LoyaltyTransaction {
+	transaction := models.LoyaltyTransaction{
+		ID:          primitive.NewObjectID(),
+		Customer_id: customerId,
+		Type:        kind,
+		Points:      points,
+		Invoice_id:  invoiceId,
+		Note:        note,
+	}
+	transaction.Loyalty_transaction_id = transaction.ID.Hex()
+	transaction.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return transaction
+}
+
+// applyLoyaltyRewards makes the order items redeemed as loyalty rewards free
+// and lists them next to the promotions on the invoice.
+func applyLoyaltyRewards(lines []models.InvoiceLine, applied []models.AppliedPromotion, rewardItemIds []string) ([]models.InvoiceLine, []models.AppliedPromotion) {
+	for _, id := range rewardItemIds {
+		for i := range lines {
+			if lines[i].Order_item_id != id {
+				continue
+			}
+			// A reward covers one unit of the item, not every unit ordered.
+			free := lines[i].Unit_price
+			if remaining := lines[i].Line_total.Sub(lines[i].Discount); remaining.Amount < free.Amount {
+				free = remaining
+			}
+			lines[i].Discount = lines[i].Discount.Add(free)
+			applied = append(applied, models.AppliedPromotion{
+				Name:          "Loyalty reward",
+				Type:          "LOYALTY_REWARD",
+				Order_item_id: id,
+				Amount:        free,
+			})
+		}
+	}
+	return lines, applied
+}
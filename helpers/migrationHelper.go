@@ -0,0 +1,124 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type moneyField struct {
+	collection *mongo.Collection
+	field      string
+}
+
+var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
+var orderitemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderitem")
+
+// MigrateMoneyFields rewrites legacy float prices into {amount, currency}
+// documents holding integer minor units. Documents that are already
+// migrated no longer match the filter, so it is safe to run on every start.
+func MigrateMoneyFields() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	fields := []moneyField{
+		{foodCollection, "price"},
+		{orderitemCollection, "unit_price"},
+	}
+
+	for _, f := range fields {
+		filter := bson.M{f.field: bson.M{"$type": bson.A{"double", "int", "long", "decimal"}}}
+		amount := bson.D{{"$toLong", bson.D{{"$round", bson.A{bson.D{{"$multiply", bson.A{bson.D{{"$toDecimal", "$" + f.field}}, 100}}}, 0}}}}}
+		update := mongo.Pipeline{
+			{{"$set", bson.D{{f.field, bson.D{{"amount", amount}, {"currency", models.DefaultCurrency}}}}}},
+		}
+
+		result, err := f.collection.UpdateMany(ctx, filter, update)
+		if err != nil {
+			log.Println("money migration failed for", f.field, err)
+			continue
+		}
+		if result.ModifiedCount > 0 {
+			log.Println("migrated", result.ModifiedCount, f.field, "values to minor units")
+		}
+	}
+}
+
+// MigrateOrderItemQuantities moves the S/M/L sizes that older order items
+// stored in quantity into size, leaving a numeric quantity of one.
+func MigrateOrderItemQuantities() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	update := mongo.Pipeline{
+		{{"$set", bson.D{{"size", "$quantity"}, {"quantity", bson.D{{"$toLong", 1}}}}}},
+	}
+	result, err := orderitemCollection.UpdateMany(ctx, bson.M{"quantity": bson.M{"$type": "string"}}, update)
+	if err != nil {
+		log.Println("quantity migration failed", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		log.Println("migrated", result.ModifiedCount, "order item sizes out of quantity")
+	}
+}
+
+// MigrateTaxRateCategories moves the single category that older tax rates
+// stored into tax_categories, which is the only field BuildInvoice reads.
+func MigrateTaxRateCategories() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	update := mongo.Pipeline{
+		{{"$set", bson.D{{"tax_categories", bson.D{{"$setUnion", bson.A{bson.D{{"$ifNull", bson.A{"$tax_categories", bson.A{}}}}, bson.A{"$category"}}}}}}}},
+		{{"$unset", "category"}},
+	}
+	result, err := taxRateCollection.UpdateMany(ctx, bson.M{"category": bson.M{"$type": "string"}}, update)
+	if err != nil {
+		log.Println("tax category migration failed", err)
+		return
+	}
+	if result.ModifiedCount > 0 {
+		log.Println("migrated", result.ModifiedCount, "tax rate categories into tax_categories")
+	}
+}
+
+// EnsureIndexes creates the unique indexes the numbering and locking code
+// relies on to turn concurrent writes into duplicate-key errors. Creating an
+// index that already exists is a no-op, so it is safe to run on every start.
+func EnsureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := invoiceCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"invoice_number", 1}},
+		Options: options.Index().SetName("invoice_number_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"invoice_number": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		log.Println("could not create invoice number index", err)
+	}
+
+	_, err = paymentCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{"invoice_id", 1}, {"idempotency_key", 1}},
+		Options: options.Index().SetName("payment_idempotency_key_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+	})
+	if err != nil {
+		log.Println("could not create payment idempotency index", err)
+	}
+
+	_, err = zReportCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{"business_date", 1}, {"location", 1}},
+		Options: options.Index().SetName("business_day_unique").SetUnique(true),
+	})
+	if err != nil {
+		log.Println("could not create business day index", err)
+	}
+}
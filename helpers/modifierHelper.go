@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrepareModifierGroups checks the modifier groups of a food and gives new
+// groups and options their ids. Existing ids are kept so order items that
+// already reference them stay valid.
+func PrepareModifierGroups(groups []models.ModifierGroup) error {
+	for i := range groups {
+		group := &groups[i]
+		if group.Required && group.Min_select < 1 {
+			group.Min_select = 1
+		}
+		if group.Max_select > 0 && group.Min_select > group.Max_select {
+			return fmt.Errorf("modifier group %s: min_select is above max_select", *group.Name)
+		}
+		if group.Min_select > len(group.Options) {
+			return fmt.Errorf("modifier group %s: min_select is above the number of options", *group.Name)
+		}
+		if group.Modifier_group_id == "" {
+			group.Modifier_group_id = primitive.NewObjectID().Hex()
+		}
+		for j := range group.Options {
+			if group.Options[j].Modifier_option_id == "" {
+				group.Options[j].Modifier_option_id = primitive.NewObjectID().Hex()
+			}
+			group.Options[j].Price_delta = models.NewMoney(group.Options[j].Price_delta.Amount, group.Options[j].Price_delta.Currency)
+		}
+	}
+	return nil
+}
+
+// ResolveModifiers matches the options picked for an order item against
+// its food. It rejects unknown or repeated options and selections that
+// break a group's limits, and returns the selection with names and price
+// deltas taken from the food.
+func ResolveModifiers(food models.Food, selected []models.SelectedModifier) ([]models.SelectedModifier, error) {
+	resolved := []models.SelectedModifier{}
+	picked := map[string]int{}
+	seen := map[string]bool{}
+
+	for _, choice := range selected {
+		group, option, ok := findModifier(food, choice.Modifier_group_id, choice.Modifier_option_id)
+		if !ok {
+			return nil, errors.New("modifier " + choice.Modifier_option_id + " is not offered with this food")
+		}
+		if seen[option.Modifier_option_id] {
+			return nil, errors.New("modifier " + *option.Name + " was selected twice")
+		}
+		seen[option.Modifier_option_id] = true
+		picked[group.Modifier_group_id]++
+		resolved = append(resolved, models.SelectedModifier{
+			Modifier_group_id:  group.Modifier_group_id,
+			Modifier_option_id: option.Modifier_option_id,
+			Group_name:         *group.Name,
+			Name:               *option.Name,
+			Price_delta:        option.Price_delta,
+		})
+	}
+
+	for _, group := range food.Modifier_groups {
+		count := picked[group.Modifier_group_id]
+		if count < group.Min_select {
+			return nil, fmt.Errorf("%s needs at least %d selection(s)", *group.Name, group.Min_select)
+		}
+		if group.Max_select > 0 && count > group.Max_select {
+			return nil, fmt.Errorf("%s allows at most %d selection(s)", *group.Name, group.Max_select)
+		}
+	}
+	return resolved, nil
+}
+
+// ModifiersTotal adds up the price deltas of a selection.
+func ModifiersTotal(modifiers []models.SelectedModifier, currency string) models.Money {
+	total := models.NewMoney(0, currency)
+	for _, modifier := range modifiers {
+		total = total.Add(modifier.Price_delta)
+	}
+	return total
+}
+
+func findModifier(food models.Food, groupId string, optionId string) (models.ModifierGroup, models.ModifierOption, bool) {
+	for _, group := range food.Modifier_groups {
+		if group.Modifier_group_id != groupId {
+			continue
+		}
+		for _, option := range group.Options {
+			if option.Modifier_option_id == optionId {
+				return group, option, true
+			}
+		}
+	}
+	return models.ModifierGroup{}, models.ModifierOption{}, false
+}
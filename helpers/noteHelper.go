@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var noteCollection *mongo.Collection = database.OpenCollection(database.Client, "note")
+
+// KitchenNotes collects the kitchen-visible notes for a ticket: those on
+// the order, its table and its customer, and those on each of its items
+// keyed by order item id.
+func KitchenNotes(ctx context.Context, order models.Order, orderItemIds []string) ([]string, map[string][]string, error) {
+	entities := bson.A{bson.M{"entity_type": "ORDER", "entity_id": order.Order_id}}
+	if order.Table_id != nil {
+		entities = append(entities, bson.M{"entity_type": "TABLE", "entity_id": *order.Table_id})
+	}
+	if order.Customer_id != nil {
+		entities = append(entities, bson.M{"entity_type": "CUSTOMER", "entity_id": *order.Customer_id})
+	}
+	if len(orderItemIds) > 0 {
+		entities = append(entities, bson.M{"entity_type": "ORDER_ITEM", "entity_id": bson.M{"$in": orderItemIds}})
+	}
+
+	cursor, err := noteCollection.Find(ctx, bson.M{"visibility": "KITCHEN", "$or": entities}, options.Find().SetSort(bson.D{{"created_at", 1}}))
+	if err != nil {
+		return nil, nil, err
+	}
+	var notes []models.Note
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, nil, err
+	}
+
+	ticketNotes := []string{}
+	itemNotes := map[string][]string{}
+	for _, note := range notes {
+		if *note.Entity_type == "ORDER_ITEM" {
+			itemNotes[*note.Entity_id] = append(itemNotes[*note.Entity_id], *note.Text)
+			continue
+		}
+		ticketNotes = append(ticketNotes, *note.Text)
+	}
+	return ticketNotes, itemNotes, nil
+}
@@ -0,0 +1,231 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ItemFlow is the path an order item takes through the kitchen.
+var ItemFlow = []string{"QUEUED", "FIRING", "READY", "DELIVERED"}
+
+var ErrOrderItemChanged = errors.New("order item status changed concurrently, please retry")
+
+// itemTimestamps names the field that records when an item reached a status.
+var itemTimestamps = map[string]string{
+	"QUEUED":    "queued_at",
+	"FIRING":    "fired_at",
+	"READY":     "ready_at",
+	"DELIVERED": "delivered_at",
+	"VOIDED":    "voided_at",
+}
+
+// OrderItemStatus returns the kitchen status of an item, treating items
+// created before statuses existed as QUEUED.
+func OrderItemStatus(item models.OrderItem) string {
+	if item.Status == nil {
+		return ItemFlow[0]
+	}
+	return *item.Status
+}
+
+// CanTransitionOrderItem reports whether an item may move one step forward
+// or back in the kitchen, or be voided before it reaches the table.
+func CanTransitionOrderItem(from string, to string) bool {
+	if from == "VOIDED" {
+		return false
+	}
+	if to == "VOIDED" {
+		return from != "DELIVERED"
+	}
+	next, hasNext := stepOrderItem(from, 1)
+	previous, hasPrevious := stepOrderItem(from, -1)
+	return (hasNext && next == to) || (hasPrevious && previous == to)
+}
+
+func NextOrderItemStatus(from string) (string, bool) {
+	return stepOrderItem(from, 1)
+}
+
+func PreviousOrderItemStatus(from string) (string, bool) {
+	return stepOrderItem(from, -1)
+}
+
+func stepOrderItem(from string, step int) (string, bool) {
+	for i, status := range ItemFlow {
+		if status == from && i+step >= 0 && i+step < len(ItemFlow) {
+			return ItemFlow[i+step], true
+		}
+	}
+	return "", false
+}
+
+// TransitionOrderItem moves an item to a new kitchen status and stamps the
+// time it got there. Moving an item back clears the timestamps of the steps
+// it is leaving so ticket times are measured from the last attempt.
+func TransitionOrderItem(ctx context.Context, orderItemId string, from string, to string) (time.Time, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	set := bson.D{{"status", to}, {"updated_at", now}, {itemTimestamps[to], now}}
+	unset := bson.D{}
+	if previous, ok := PreviousOrderItemStatus(from); ok && previous == to {
+		unset = append(unset, bson.E{itemTimestamps[from], ""})
+	}
+
+	current := bson.M{"$eq": from}
+	if from == ItemFlow[0] {
+		current = bson.M{"$in": bson.A{from, nil}}
+	}
+	update := bson.D{{"$set", set}}
+	if len(unset) > 0 {
+		update = append(update, bson.E{"$unset", unset})
+	}
+	result, err := orderitemCollection.UpdateOne(ctx, bson.M{"order_item_id": orderItemId, "status": current}, update)
+	if err != nil {
+		return now, err
+	}
+	if result.MatchedCount == 0 {
+		return now, ErrOrderItemChanged
+	}
+	PublishOrderItemEvent(ctx, "order_item.status", orderItemId)
+	return now, nil
+}
+
+// RollUpOrderStatus moves an order forward to match its items: IN_PROGRESS
+// once the kitchen has started on anything, READY when every item that is
+// not voided is ready, and SERVED when they have all been delivered. It
+// never moves an order backwards and leaves finished orders alone.
+func RollUpOrderStatus(ctx context.Context, orderId string, userId string) (string, error) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return "", err
+	}
+	current := OrderStatus(order)
+
+	cursor, err := orderitemCollection.Find(ctx, bson.M{"order_id": orderId, "status": bson.M{"$ne": "VOIDED"}})
+	if err != nil {
+		return current, err
+	}
+	var items []models.OrderItem
+	if err = cursor.All(ctx, &items); err != nil {
+		return current, err
+	}
+	target := itemsRollUp(items)
+	if target == "" {
+		return current, nil
+	}
+
+	for flowIndex(OrderFlow, current) >= 0 && flowIndex(OrderFlow, current) < flowIndex(OrderFlow, target) {
+		next, _ := NextOrderStatus(current)
+		_, err = TransitionOrder(ctx, orderId, current, next, userId, "kitchen items "+itemRollUpReason[target])
+		if err != nil {
+			if err == ErrOrderChanged {
+				return current, nil
+			}
+			return current, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+var itemRollUpReason = map[string]string{
+	"IN_PROGRESS": "started",
+	"READY":       "ready",
+	"SERVED":      "delivered",
+}
+
+// itemsRollUp returns the order status the items call for, or "" when the
+// kitchen has not started on any of them.
+func itemsRollUp(items []models.OrderItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	lowest, highest := len(ItemFlow)-1, 0
+	for _, item := range items {
+		i := flowIndex(ItemFlow, OrderItemStatus(item))
+		if i < lowest {
+			lowest = i
+		}
+		if i > highest {
+			highest = i
+		}
+	}
+	switch {
+	case ItemFlow[lowest] == "DELIVERED":
+		return "SERVED"
+	case ItemFlow[lowest] == "READY":
+		return "READY"
+	case highest > 0:
+		return "IN_PROGRESS"
+	}
+	return ""
+}
+
+func flowIndex(flow []string, status string) int {
+	for i, s := range flow {
+		if s == status {
+			return i
+		}
+	}
+	return -1
+}
+
+// DishTicketTimes averages preparation times per dish over the items that
+// were fired between from and to.
+func DishTicketTimes(ctx context.Context, from time.Time, to time.Time) ([]models.DishTicketTime, error) {
+	matchStage := bson.D{{"$match", bson.D{
+		{"fired_at", bson.D{{"$gte", from}, {"$lt", to}}},
+		{"ready_at", bson.D{{"$ne", nil}}},
+	}}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"food_id", 1},
+		{"name", "$food.name"},
+		{"prep", bson.D{{"$divide", bson.A{bson.D{{"$subtract", bson.A{"$ready_at", "$fired_at"}}}, 1000}}}},
+		{"total", bson.D{{"$cond", bson.A{
+			bson.D{{"$and", bson.A{"$delivered_at", "$queued_at"}}},
+			bson.D{{"$divide", bson.A{bson.D{{"$subtract", bson.A{"$delivered_at", "$queued_at"}}}, 1000}}},
+			nil,
+		}}}},
+	}}}
+	groupStage := bson.D{{"$group", bson.D{
+		{"_id", "$food_id"},
+		{"name", bson.D{{"$first", "$name"}}},
+		{"count", bson.D{{"$sum", 1}}},
+		{"avg_prep_seconds", bson.D{{"$avg", "$prep"}}},
+		{"max_prep_seconds", bson.D{{"$max", "$prep"}}},
+		{"avg_total_seconds", bson.D{{"$avg", "$total"}}},
+	}}}
+	projectStage2 := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"food_id", "$_id"},
+		{"name", bson.D{{"$ifNull", bson.A{"$name", ""}}}},
+		{"count", 1},
+		{"avg_prep_seconds", 1},
+		{"max_prep_seconds", 1},
+		{"avg_total_seconds", bson.D{{"$ifNull", bson.A{"$avg_total_seconds", 0}}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"avg_prep_seconds", -1}}}}
+
+	cursor, err := orderitemCollection.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		lookupStage,
+		unwindStage,
+		projectStage,
+		groupStage,
+		projectStage2,
+		sortStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	times := []models.DishTicketTime{}
+	err = cursor.All(ctx, &times)
+	return times, err
+}
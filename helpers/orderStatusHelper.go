@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OrderFlow is the normal life of an order, from the first item being taken
+// to the table being paid and cleared.
+var OrderFlow = []string{"OPEN", "SENT_TO_KITCHEN", "IN_PROGRESS", "READY", "SERVED", "CLOSED"}
+
+var ErrOrderChanged = errors.New("order status changed concurrently, please retry")
+
+// orderExits lists where an order may leave the normal flow. Orders can be
+// cancelled until the kitchen starts on them; after that they are voided.
+// Any order still going can be merged into another.
+var orderExits = map[string][]string{
+	"OPEN":            {"CANCELLED", "MERGED"},
+	"SENT_TO_KITCHEN": {"CANCELLED", "MERGED"},
+	"IN_PROGRESS":     {"VOID", "MERGED"},
+	"READY":           {"VOID", "MERGED"},
+	"SERVED":          {"VOID", "MERGED"},
+}
+
+// OrderStatus returns the status of an order, treating orders created before
+// statuses existed as OPEN.
+func OrderStatus(order models.Order) string {
+	if order.Status == nil {
+		return "OPEN"
+	}
+	return *order.Status
+}
+
+// CanTransitionOrder reports whether an order may move from one status to
+// another: one step forward or back along the flow, or out of it through
+// cancel, void or merge. CLOSED, CANCELLED, VOID and MERGED are final.
+func CanTransitionOrder(from string, to string) bool {
+	if from == "CLOSED" {
+		return false
+	}
+	if next, ok := NextOrderStatus(from); ok && next == to {
+		return true
+	}
+	if previous, ok := PreviousOrderStatus(from); ok && previous == to {
+		return true
+	}
+	for _, exit := range orderExits[from] {
+		if exit == to {
+			return true
+		}
+	}
+	return false
+}
+
+func NextOrderStatus(from string) (string, bool) {
+	for i, status := range OrderFlow {
+		if status == from && i+1 < len(OrderFlow) {
+			return OrderFlow[i+1], true
+		}
+	}
+	return "", false
+}
+
+// PreviousOrderStatus returns the status an order can be put back to. A
+// closed order cannot be reopened.
+func PreviousOrderStatus(from string) (string, bool) {
+	for i, status := range OrderFlow {
+		if status == from && i > 0 && from != "CLOSED" {
+			return OrderFlow[i-1], true
+		}
+	}
+	return "", false
+}
+
+// TransitionOrder moves an order from one status to another and appends the
+// change to its history. The update only applies while the order is still
+// in the expected status, so two people moving the same order cannot both
+// succeed. Finishing an order frees its table.
+func TransitionOrder(ctx context.Context, orderId string, from string, to string, userId string, reason string) (models.OrderStatusChange, error) {
+	change := models.OrderStatusChange{From: from, To: to, Reason: reason, Changed_by: userId}
+	change.Changed_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	current := bson.M{"$eq": from}
+	if from == "OPEN" {
+		current = bson.M{"$in": bson.A{"OPEN", nil}}
+	}
+	result, err := orderCollection.UpdateOne(ctx, bson.M{"order_id": orderId, "status": current}, bson.D{
+		{"$set", bson.D{{"status", to}, {"updated_at", change.Changed_at}}},
+		{"$push", bson.D{{"status_history", change}}},
+	})
+	if err != nil {
+		return change, err
+	}
+	if result.MatchedCount == 0 {
+		return change, ErrOrderChanged
+	}
+	PublishOrderEvent(ctx, "order.status", orderId, to)
+	if OrderFinished(to) {
+		releaseOrderTable(ctx, orderId)
+	}
+	return change, nil
+}
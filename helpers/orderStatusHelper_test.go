@@ -0,0 +1,22 @@
+package helpers
+
+import "testing"
+
+func TestOrdersCanBeMergedUntilFinished(t *testing.T) {
+	for _, status := range []string{"OPEN", "SENT_TO_KITCHEN", "IN_PROGRESS", "READY", "SERVED"} {
+		if !CanTransitionOrder(status, "MERGED") {
+			t.Errorf("%s order cannot be merged", status)
+		}
+	}
+	for _, status := range []string{"CLOSED", "CANCELLED", "VOID", "MERGED"} {
+		if CanTransitionOrder(status, "MERGED") {
+			t.Errorf("%s order can be merged", status)
+		}
+		if !OrderFinished(status) {
+			t.Errorf("%s order is not finished", status)
+		}
+	}
+	if CanTransitionOrder("MERGED", "OPEN") {
+		t.Error("a merged order can be reopened")
+	}
+}
@@ -0,0 +1,90 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OrderFinished reports whether an order has left the floor, so it no
+// longer holds a table and can no longer be moved or merged.
+func OrderFinished(status string) bool {
+	return status == "CLOSED" || status == "CANCELLED" || status == "VOID" || status == "MERGED"
+}
+
+// TableStatus returns the status of a table, treating tables created before
+// statuses existed as AVAILABLE.
+func TableStatus(table models.Table) string {
+	if table.Status == nil {
+		return "AVAILABLE"
+	}
+	return *table.Status
+}
+
+// RefreshTableStatus marks a table OCCUPIED while any order on it is still
+// going and AVAILABLE once the last one has finished.
+func RefreshTableStatus(ctx context.Context, tableId string) (string, error) {
+	count, err := orderCollection.CountDocuments(ctx, bson.M{
+		"table_id": tableId,
+		"status":   bson.M{"$nin": bson.A{"CLOSED", "CANCELLED", "VOID", "MERGED"}},
+	})
+	if err != nil {
+		return "", err
+	}
+	status := "AVAILABLE"
+	if count > 0 {
+		status = "OCCUPIED"
+	}
+	_, err = tableCollection.UpdateOne(ctx, bson.M{"table_id": tableId}, bson.D{{"$set", bson.D{{"status", status}}}})
+	return status, err
+}
+
+// releaseOrderTable frees the table of an order that has just finished,
+// unless another order is still using it.
+func releaseOrderTable(ctx context.Context, orderId string) {
+	var order models.Order
+	if err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil || order.Table_id == nil {
+		return
+	}
+	if _, err := RefreshTableStatus(ctx, *order.Table_id); err != nil {
+		log.Println("table status:", err)
+	}
+}
+
+// RecordOrderChange applies set to the order matching filter and appends
+// change to its history in the same update. It fails with ErrOrderChanged
+// when the order no longer matches, so a move based on a stale read does
+// not go through.
+func RecordOrderChange(ctx context.Context, filter bson.M, set bson.D, change models.OrderChange) error {
+	update := bson.D{{"$push", bson.D{{"history", change}}}}
+	if len(set) > 0 {
+		update = append(update, bson.E{"$set", set})
+	}
+	result, err := orderCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrOrderChanged
+	}
+	return nil
+}
+
+// MoveOrderItems moves items from one order to another and lets the kitchen
+// know where they went. Items that are not on the source order are left
+// alone.
+func MoveOrderItems(ctx context.Context, orderItemIds []string, fromOrderId string, toOrderId string) (int64, error) {
+	result, err := orderitemCollection.UpdateMany(ctx,
+		bson.M{"order_item_id": bson.M{"$in": orderItemIds}, "order_id": fromOrderId},
+		bson.D{{"$set", bson.D{{"order_id", toOrderId}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	for _, orderItemId := range orderItemIds {
+		PublishOrderItemEvent(ctx, "order_item.moved", orderItemId)
+	}
+	return result.ModifiedCount, nil
+}
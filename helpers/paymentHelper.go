@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+)
+
+// SettleInvoice derives the outstanding balance and payment status of an
+// invoice from the amounts paid and refunded so far. Refunds do not reopen
+// the balance; an invoice whose payments were all refunded is REFUNDED.
+// Invoices that have never had a payment recorded keep whatever status they
+// were given by hand, and voided invoices stay VOID.
+func SettleInvoice(invoice *models.Invoice) {
+	currency := ""
+	if invoice.Total != nil {
+		currency = invoice.Total.Currency
+	}
+	total := models.NewMoney(0, currency)
+	if invoice.Total != nil {
+		total = *invoice.Total
+	}
+	paid := models.NewMoney(0, currency)
+	if invoice.Amount_paid != nil {
+		paid = *invoice.Amount_paid
+	}
+
+	refunded := models.NewMoney(0, currency)
+	if invoice.Amount_refunded != nil {
+		refunded = *invoice.Amount_refunded
+	}
+
+	balance := total.Sub(paid)
+	if balance.Amount < 0 || (invoice.Payment_status != nil && *invoice.Payment_status == "VOID") {
+		balance = models.NewMoney(0, currency)
+	}
+
+	invoice.Balance_due = &balance
+	if invoice.Amount_paid == nil || (invoice.Payment_status != nil && *invoice.Payment_status == "VOID") {
+		return
+	}
+
+	status := "PENDING"
+	if paid.Amount > 0 && balance.Amount > 0 {
+		status = "PARTIALLY_PAID"
+	} else if paid.Amount > 0 {
+		status = "PAID"
+	}
+	if refunded.Amount > 0 && refunded.Amount >= paid.Amount {
+		status = "REFUNDED"
+	}
+	invoice.Payment_status = &status
+}
+
+// ApplyTender works out how much of a tender goes towards the balance. Cash
+// may exceed the balance and the difference is returned as change; any other
+// tender has to fit within what is still owed.
+func ApplyTender(balance models.Money, tender string, amount models.Money) (applied models.Money, change models.Money, ok bool) {
+	change = models.NewMoney(0, amount.Currency)
+	if amount.Amount <= 0 {
+		return amount, change, false
+	}
+	if amount.Amount <= balance.Amount {
+		return amount, change, true
+	}
+	if tender != "CASH" {
+		return amount, change, false
+	}
+	return balance, amount.Sub(balance), true
+}
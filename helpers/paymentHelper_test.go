@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestSettleInvoiceTransitions(t *testing.T) {
+	total := models.NewMoney(10000, "USD")
+	invoice := models.Invoice{Total: &total}
+
+	SettleInvoice(&invoice)
+	if invoice.Payment_status != nil {
+		t.Fatalf("unpaid invoice got status %s", *invoice.Payment_status)
+	}
+	if invoice.Balance_due.Amount != 10000 {
+		t.Fatalf("balance = %d, want 10000", invoice.Balance_due.Amount)
+	}
+
+	steps := []struct {
+		paid     int64
+		refunded int64
+		status   string
+		balance  int64
+	}{
+		{0, 0, "PENDING", 10000},
+		{4000, 0, "PARTIALLY_PAID", 6000},
+		{10000, 0, "PAID", 0},
+		{10000, 4000, "PAID", 0},
+		{10000, 10000, "REFUNDED", 0},
+	}
+	for _, step := range steps {
+		paid := models.NewMoney(step.paid, "USD")
+		refunded := models.NewMoney(step.refunded, "USD")
+		invoice.Amount_paid = &paid
+		invoice.Amount_refunded = &refunded
+
+		SettleInvoice(&invoice)
+		if *invoice.Payment_status != step.status {
+			t.Errorf("paid %d refunded %d: status = %s, want %s", step.paid, step.refunded, *invoice.Payment_status, step.status)
+		}
+		if invoice.Balance_due.Amount != step.balance {
+			t.Errorf("paid %d refunded %d: balance = %d, want %d", step.paid, step.refunded, invoice.Balance_due.Amount, step.balance)
+		}
+	}
+}
+
+func TestSettleInvoiceKeepsVoid(t *testing.T) {
+	total := models.NewMoney(10000, "USD")
+	paid := models.NewMoney(4000, "USD")
+	status := "VOID"
+	invoice := models.Invoice{Total: &total, Amount_paid: &paid, Payment_status: &status}
+
+	SettleInvoice(&invoice)
+	if *invoice.Payment_status != "VOID" || invoice.Balance_due.Amount != 0 {
+		t.Fatalf("void invoice settled to %s with balance %d", *invoice.Payment_status, invoice.Balance_due.Amount)
+	}
+}
+
+func TestApplyTender(t *testing.T) {
+	balance := models.NewMoney(2500, "USD")
+
+	applied, change, ok := ApplyTender(balance, "CASH", models.NewMoney(3000, "USD"))
+	if !ok || applied.Amount != 2500 || change.Amount != 500 {
+		t.Errorf("cash over the balance: applied %d change %d ok %v", applied.Amount, change.Amount, ok)
+	}
+	if _, _, ok = ApplyTender(balance, "CARD", models.NewMoney(3000, "USD")); ok {
+		t.Error("card over the balance was accepted")
+	}
+	if _, _, ok = ApplyTender(balance, "CARD", models.NewMoney(0, "USD")); ok {
+		t.Error("zero payment was accepted")
+	}
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"os"
+	"time"
+)
+
+var ErrPaymentDeclined = errors.New("payment declined")
+var ErrProviderTimeout = errors.New("payment provider timed out")
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+var ErrWebhookSecretMissing = errors.New("payment webhooks are disabled until PAYMENT_WEBHOOK_SECRET is set")
+
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, request AuthorizeRequest) (ProviderResult, error)
+	Capture(ctx context.Context, transactionId string, amount models.Money, idempotencyKey string) (ProviderResult, error)
+	Void(ctx context.Context, transactionId string, idempotencyKey string) (ProviderResult, error)
+	Refund(ctx context.Context, transactionId string, amount models.Money, idempotencyKey string) (ProviderResult, error)
+	VerifyWebhook(payload []byte, signature string) (WebhookEvent, error)
+}
+
+type AuthorizeRequest struct {
+	Amount          models.Money
+	Card_token      string
+	Idempotency_key string
+}
+
+type ProviderResult struct {
+	Transaction_id string
+	Status         string
+	Decline_reason string
+}
+
+type WebhookEvent struct {
+	Type           string `json:"type"`
+	Transaction_id string `json:"transaction_id"`
+	Amount         *models.Money
+}
+
+var PaymentGateway PaymentProvider = NewFakePaymentProvider(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+
+// WithProviderRetry calls fn until it succeeds, fails for a reason other
+// than a timeout, or attempts run out. Callers pass the same idempotency key
+// on every attempt so a retried call never charges twice.
+func WithProviderRetry(ctx context.Context, attempts int, fn func(ctx context.Context) (ProviderResult, error)) (ProviderResult, error) {
+	var result ProviderResult
+	var err error
+	for i := 0; i < attempts; i++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		result, err = fn(attemptCtx)
+		cancel()
+		if !errors.Is(err, ErrProviderTimeout) {
+			return result, err
+		}
+		if ctx.Err() != nil {
+			return result, err
+		}
+		time.Sleep(time.Duration(i+1) * 200 * time.Millisecond)
+	}
+	return result, err
+}
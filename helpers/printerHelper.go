@@ -0,0 +1,321 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const printJobMaxAttempts = 5
+
+// printJobClaimTimeout is how long a job may stay PRINTING before it is
+// assumed its worker died and it is handed out again.
+const printJobClaimTimeout = 2 * time.Minute
+
+// PrinterSpoolDir is the only directory FILE printers may write to. FILE
+// printers are disabled while it is unset.
+var PrinterSpoolDir = os.Getenv("PRINTER_SPOOL_DIR")
+
+// PrinterAllowedNetworks lists the CIDR ranges TCP printers may be reached
+// on, e.g. "10.1.0.0/16,192.168.5.0/24". When unset any private address is
+// allowed.
+var PrinterAllowedNetworks = os.Getenv("PRINTER_ALLOWED_NETWORKS")
+
+var ErrPrinterAddress = errors.New("printer address must be on the local printer network")
+var ErrPrinterFile = errors.New("file_path must be a file name inside the printer spool directory")
+var ErrFilePrintersDisabled = errors.New("FILE printers are disabled until PRINTER_SPOOL_DIR is set")
+
+type PrinterSink interface {
+	Print(ctx context.Context, payload []byte) error
+}
+
+// TCPPrinter sends raw bytes to a network printer, usually on port 9100.
+type TCPPrinter struct {
+	Address string
+}
+
+func (p TCPPrinter) Print(ctx context.Context, payload []byte) error {
+	// The address is checked again on the resolved IP as it is dialled, so a
+	// host name cannot be pointed somewhere else after the printer is saved.
+	dialer := net.Dialer{Control: func(network string, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if !PrinterIPAllowed(net.ParseIP(host)) {
+			return ErrPrinterAddress
+		}
+		return nil
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", printerHostPort(p.Address))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+	_, err = conn.Write(payload)
+	return err
+}
+
+func printerHostPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, "9100")
+	}
+	return address
+}
+
+// PrinterIPAllowed reports whether a TCP printer may be reached on ip: one
+// of PrinterAllowedNetworks when it is set, otherwise any private address.
+func PrinterIPAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if PrinterAllowedNetworks == "" {
+		return ip.IsPrivate()
+	}
+	for _, cidr := range strings.Split(PrinterAllowedNetworks, ",") {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPrinterAddress makes sure a TCP printer address resolves only to
+// addresses the printer policy allows.
+func CheckPrinterAddress(ctx context.Context, address string) error {
+	host, _, err := net.SplitHostPort(printerHostPort(address))
+	if err != nil {
+		return err
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if !PrinterIPAllowed(ip.IP) {
+			return ErrPrinterAddress
+		}
+	}
+	return nil
+}
+
+// FilePrinter appends every job to a file in a spool directory, for testing
+// without hardware.
+type FilePrinter struct {
+	Dir  string
+	Name string
+}
+
+// path returns where the printer writes, refusing names that would leave
+// the spool directory.
+func (p FilePrinter) path() (string, error) {
+	if p.Dir == "" {
+		return "", ErrFilePrintersDisabled
+	}
+	name := filepath.Clean(p.Name)
+	if p.Name == "" || filepath.IsAbs(p.Name) || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", ErrPrinterFile
+	}
+	return filepath.Join(p.Dir, name), nil
+}
+
+func (p FilePrinter) Print(ctx context.Context, payload []byte) error {
+	path, err := p.path()
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(payload)
+	return err
+}
+
+var printerCollection *mongo.Collection = database.OpenCollection(database.Client, "printer")
+var printJobCollection *mongo.Collection = database.OpenCollection(database.Client, "printjob")
+
+// SinkFor returns where a printer's jobs go. FILE printers are checked
+// against the spool directory here; TCP addresses are checked when the
+// printer is saved and again when it is dialled.
+func SinkFor(printer models.Printer) (PrinterSink, error) {
+	switch {
+	case printer.Connection != nil && *printer.Connection == "TCP" && printer.Address != nil:
+		return TCPPrinter{Address: *printer.Address}, nil
+	case printer.Connection != nil && *printer.Connection == "FILE" && printer.File_path != nil:
+		sink := FilePrinter{Dir: PrinterSpoolDir, Name: *printer.File_path}
+		if _, err := sink.path(); err != nil {
+			return nil, err
+		}
+		return sink, nil
+	}
+	return nil, errors.New("TCP printers need an address and FILE printers a file_path")
+}
+
+// CheckPrinter makes sure a printer can be saved: it has a usable
+// connection and a TCP address within the printer policy.
+func CheckPrinter(ctx context.Context, printer models.Printer) error {
+	if _, err := SinkFor(printer); err != nil {
+		return err
+	}
+	if *printer.Connection == "TCP" {
+		return CheckPrinterAddress(ctx, *printer.Address)
+	}
+	return nil
+}
+
+func PrinterWidth(printer models.Printer) int {
+	if printer.Width != nil {
+		return *printer.Width
+	}
+	return ReceiptWidth
+}
+
+// KitchenPrinters returns the kitchen printers that should receive an item
+// of the given menu category. Printers without categories take everything.
+func KitchenPrinters(printers []models.Printer, category string) []models.Printer {
+	matched := []models.Printer{}
+	for _, printer := range printers {
+		if printer.Role == nil || *printer.Role != "KITCHEN" {
+			continue
+		}
+		if len(printer.Categories) == 0 {
+			matched = append(matched, printer)
+			continue
+		}
+		for _, c := range printer.Categories {
+			if c == category {
+				matched = append(matched, printer)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func EnqueuePrintJob(ctx context.Context, printerId string, kind string, referenceId string, payload []byte) (models.PrintJob, error) {
+	var job models.PrintJob
+	job.ID = primitive.NewObjectID()
+	job.Print_job_id = job.ID.Hex()
+	job.Printer_id = printerId
+	job.Kind = kind
+	job.Reference_id = referenceId
+	job.Payload = payload
+	job.Status = "QUEUED"
+	job.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	job.Updated_at = job.Created_at
+	job.Next_attempt_at = job.Created_at
+
+	_, err := printJobCollection.InsertOne(ctx, job)
+	return job, err
+}
+
+// RunPrintQueue sends queued jobs to their printers until ctx is done. A
+// failed job is retried with exponential backoff and marked FAILED after
+// printJobMaxAttempts attempts. A job left PRINTING for longer than
+// printJobClaimTimeout is picked up again.
+func RunPrintQueue(ctx context.Context) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		for processNextPrintJob(ctx) {
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func processNextPrintJob(ctx context.Context) bool {
+	jobCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var job models.PrintJob
+	err := printJobCollection.FindOneAndUpdate(
+		jobCtx,
+		bson.M{"$or": bson.A{
+			bson.M{"status": "QUEUED", "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{"status": "PRINTING", "claimed_at": bson.M{"$lt": now.Add(-printJobClaimTimeout)}},
+		}},
+		bson.D{{"$set", bson.D{{"status", "PRINTING"}, {"claimed_at", now}, {"updated_at", now}}}},
+		options.FindOneAndUpdate().SetSort(bson.D{{"created_at", 1}}).SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Println("print queue:", err)
+		}
+		return false
+	}
+
+	claimedAt := job.Claimed_at
+	SettlePrintJob(&job, printJob(jobCtx, job), time.Now())
+
+	// Only the worker still holding the claim records the outcome, so a
+	// worker that was presumed dead cannot overwrite a later attempt.
+	_, err = printJobCollection.UpdateOne(jobCtx, bson.M{"print_job_id": job.Print_job_id, "claimed_at": claimedAt}, bson.D{{"$set", bson.D{
+		{"status", job.Status},
+		{"attempts", job.Attempts},
+		{"last_error", job.Last_error},
+		{"next_attempt_at", job.Next_attempt_at},
+		{"claimed_at", nil},
+		{"updated_at", job.Updated_at},
+	}}})
+	if err != nil {
+		log.Println("print queue:", err)
+	}
+	return true
+}
+
+// SettlePrintJob records the outcome of one attempt at a job: PRINTED on
+// success, QUEUED with exponential backoff after a failure, and FAILED once
+// printJobMaxAttempts attempts have failed.
+func SettlePrintJob(job *models.PrintJob, err error, now time.Time) {
+	job.Attempts++
+	job.Updated_at = now
+	job.Claimed_at = nil
+	switch {
+	case err == nil:
+		job.Status = "PRINTED"
+		job.Last_error = ""
+	case job.Attempts >= printJobMaxAttempts:
+		job.Status = "FAILED"
+		job.Last_error = err.Error()
+	default:
+		job.Status = "QUEUED"
+		job.Last_error = err.Error()
+		job.Next_attempt_at = now.Add(time.Duration(1<<uint(job.Attempts-1)) * 5 * time.Second)
+	}
+}
+
+func printJob(ctx context.Context, job models.PrintJob) error {
+	var printer models.Printer
+	if err := printerCollection.FindOne(ctx, bson.M{"printer_id": job.Printer_id}).Decode(&printer); err != nil {
+		return err
+	}
+	sink, err := SinkFor(printer)
+	if err != nil {
+		return err
+	}
+	return sink.Print(ctx, job.Payload)
+}
@@ -0,0 +1,155 @@
+package helpers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golang-restaurant-management/models"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func filePrinter(name string) models.Printer {
+	connection := "FILE"
+	return models.Printer{Connection: &connection, File_path: &name}
+}
+
+func useSpoolDir(t *testing.T) string {
+	dir := t.TempDir()
+	previous := PrinterSpoolDir
+	PrinterSpoolDir = dir
+	t.Cleanup(func() { PrinterSpoolDir = previous })
+	return dir
+}
+
+func TestKitchenTicketPrintsToSpool(t *testing.T) {
+	dir := useSpoolDir(t)
+	table := 12
+	seat := 2
+	ticket := KitchenTicket{
+		Order_id:     "6530f1c2a4b5c6d7e8f90123",
+		Table_number: &table,
+		Server:       "Sam",
+		Allergies:    []string{"peanuts"},
+		Printed_at:   time.Date(2026, 10, 19, 18, 30, 0, 0, time.UTC),
+		Items: []KitchenItem{
+			{Name: "Burger", Quantity: 2, Modifiers: []string{"no onion"}, Seat_number: &seat},
+		},
+	}
+
+	sink, err := SinkFor(filePrinter("kitchen.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := KitchenTicketEscPos(ticket, 42)
+	for i := 0; i < 2; i++ {
+		if err = sink.Print(context.Background(), payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	printed, err := os.ReadFile(filepath.Join(dir, "kitchen.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(printed, append(append([]byte{}, payload...), payload...)) {
+		t.Fatal("spool file does not hold both tickets in order")
+	}
+	if !bytes.HasPrefix(printed, []byte{0x1b, '@'}) {
+		t.Error("ticket does not start by initialising the printer")
+	}
+	for _, text := range []string{"TABLE 12", "Server: Sam", "ALLERGY: peanuts", "> no onion", "seat 2"} {
+		if !bytes.Contains(printed, []byte(text)) {
+			t.Errorf("ticket is missing %q", text)
+		}
+	}
+	if !bytes.HasSuffix(payload, []byte{0x1d, 'V', 'A', 0x03}) {
+		t.Error("ticket is not cut")
+	}
+}
+
+func TestFilePrinterStaysInSpool(t *testing.T) {
+	useSpoolDir(t)
+	for _, name := range []string{"../escape.bin", "/etc/passwd", "nested/ticket.bin", "..", ""} {
+		if _, err := SinkFor(filePrinter(name)); !errors.Is(err, ErrPrinterFile) {
+			t.Errorf("%q: got %v, want ErrPrinterFile", name, err)
+		}
+	}
+}
+
+func TestFilePrintersNeedSpoolDir(t *testing.T) {
+	previous := PrinterSpoolDir
+	PrinterSpoolDir = ""
+	defer func() { PrinterSpoolDir = previous }()
+
+	if _, err := SinkFor(filePrinter("kitchen.bin")); !errors.Is(err, ErrFilePrintersDisabled) {
+		t.Fatalf("got %v, want ErrFilePrintersDisabled", err)
+	}
+}
+
+func TestPrinterIPAllowed(t *testing.T) {
+	previous := PrinterAllowedNetworks
+	defer func() { PrinterAllowedNetworks = previous }()
+
+	PrinterAllowedNetworks = ""
+	for address, allowed := range map[string]bool{
+		"192.168.1.50":    true,
+		"10.0.0.7":        true,
+		"127.0.0.1":       false,
+		"169.254.169.254": false,
+		"8.8.8.8":         false,
+	} {
+		if got := PrinterIPAllowed(net.ParseIP(address)); got != allowed {
+			t.Errorf("%s: allowed = %v, want %v", address, got, allowed)
+		}
+	}
+
+	PrinterAllowedNetworks = "192.168.5.0/24"
+	if !PrinterIPAllowed(net.ParseIP("192.168.5.20")) || PrinterIPAllowed(net.ParseIP("192.168.1.50")) {
+		t.Error("allowed networks are not enforced")
+	}
+}
+
+func TestCheckPrinterAddress(t *testing.T) {
+	previous := PrinterAllowedNetworks
+	PrinterAllowedNetworks = ""
+	defer func() { PrinterAllowedNetworks = previous }()
+
+	if err := CheckPrinterAddress(context.Background(), "192.168.1.50"); err != nil {
+		t.Errorf("private printer rejected: %v", err)
+	}
+	if err := CheckPrinterAddress(context.Background(), "127.0.0.1:6379"); !errors.Is(err, ErrPrinterAddress) {
+		t.Errorf("loopback address: got %v", err)
+	}
+}
+
+func TestSettlePrintJob(t *testing.T) {
+	now := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+	claimed := now
+	job := models.PrintJob{Status: "PRINTING", Claimed_at: &claimed}
+
+	failure := errors.New("printer offline")
+	for attempt := 1; attempt < printJobMaxAttempts; attempt++ {
+		SettlePrintJob(&job, failure, now)
+		if job.Status != "QUEUED" || job.Attempts != attempt || job.Last_error != failure.Error() || job.Claimed_at != nil {
+			t.Fatalf("attempt %d: %+v", attempt, job)
+		}
+		if want := now.Add(time.Duration(1<<uint(attempt-1)) * 5 * time.Second); !job.Next_attempt_at.Equal(want) {
+			t.Fatalf("attempt %d: next attempt at %v, want %v", attempt, job.Next_attempt_at, want)
+		}
+	}
+
+	SettlePrintJob(&job, failure, now)
+	if job.Status != "FAILED" || job.Attempts != printJobMaxAttempts {
+		t.Fatalf("after %d failures: %+v", printJobMaxAttempts, job)
+	}
+
+	job = models.PrintJob{Status: "PRINTING", Attempts: 2, Last_error: "printer offline"}
+	SettlePrintJob(&job, nil, now)
+	if job.Status != "PRINTED" || job.Attempts != 3 || job.Last_error != "" {
+		t.Fatalf("after success: %+v", job)
+	}
+}
@@ -0,0 +1,464 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var promotionCollection *mongo.Collection = database.OpenCollection(database.Client, "promotion")
+
+var ErrInvalidCoupon = errors.New("coupon code is not valid")
+var ErrCouponExhausted = errors.New("coupon code has reached its usage limit")
+
+// PromotionActive reports whether a promotion can be used at the given
+// moment: it must be switched on, inside its start and end dates, on one of
+// its week days and inside its daily time window. A window whose end is
+// before its start runs past midnight.
+func PromotionActive(promotion models.Promotion, at time.Time) bool {
+	if promotion.Active != nil && !*promotion.Active {
+		return false
+	}
+	if promotion.Starts_at != nil && at.Before(*promotion.Starts_at) {
+		return false
+	}
+	if promotion.Ends_at != nil && !at.Before(*promotion.Ends_at) {
+		return false
+	}
+
+	local := at.In(time.Local)
+	if len(promotion.Days) > 0 {
+		found := false
+		for _, day := range promotion.Days {
+			if day == int(local.Weekday()) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if promotion.Start_time != nil && promotion.End_time != nil {
+		start, startErr := ClockMinutes(*promotion.Start_time)
+		end, endErr := ClockMinutes(*promotion.End_time)
+		if startErr != nil || endErr != nil {
+			return false
+		}
+		minutes := local.Hour()*60 + local.Minute()
+		if start <= end && (minutes < start || minutes >= end) {
+			return false
+		}
+		if start > end && minutes < start && minutes >= end {
+			return false
+		}
+	}
+	return true
+}
+
+// ClockMinutes turns a 24 hour HH:MM time into minutes after midnight.
+func ClockMinutes(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// ApplyPromotions works out which promotions a set of invoice lines gets and
+// records the discount of each on the lines. Promotions without a coupon
+// apply automatically; coupon promotions only when their code was entered.
+// Stackable promotions combine with each other, while a promotion that is
+// not stackable only ever applies on its own, so the customer gets either
+// every stackable promotion or the single best exclusive one, whichever
+// saves more. Item promotions apply before order promotions, and within
+// each group higher priorities go first.
+//
+// lines are all the lines of the order, so minimum subtotals, buy X get Y
+// and fixed order discounts are worked out once for the whole order. Only
+// the lines in orderItemIds, and their share of the order promotions, are
+// returned; an empty orderItemIds keeps every line.
+func ApplyPromotions(lines []models.InvoiceLine, promotions []models.Promotion, couponCodes []string, orderId string, orderItemIds []string, at time.Time) ([]models.InvoiceLine, []models.AppliedPromotion) {
+	var stackable []models.Promotion
+	var exclusive []models.Promotion
+	for _, promotion := range promotions {
+		if !couponEntered(promotion, couponCodes) || !promotionAvailable(promotion, orderId) {
+			continue
+		}
+		if promotion.Stackable != nil && *promotion.Stackable {
+			stackable = append(stackable, promotion)
+		} else {
+			exclusive = append(exclusive, promotion)
+		}
+	}
+
+	bestLines, bestApplied := applyPromotionSequence(lines, stackable, at)
+	bestSaving := promotionSaving(bestApplied)
+	for _, promotion := range exclusive {
+		candidateLines, candidateApplied := applyPromotionSequence(lines, []models.Promotion{promotion}, at)
+		if saving := promotionSaving(candidateApplied); saving > bestSaving {
+			bestLines, bestApplied, bestSaving = candidateLines, candidateApplied, saving
+		}
+	}
+	return filterInvoiceLines(bestLines, orderItemIds), invoicePromotions(bestApplied, orderItemIds)
+}
+
+// invoicePromotions keeps the promotions given to the lines in
+// orderItemIds. Item promotions stay one entry per line, while each order
+// promotion is summed into a single entry for the invoice.
+func invoicePromotions(applied []models.AppliedPromotion, orderItemIds []string) []models.AppliedPromotion {
+	wanted := map[string]bool{}
+	for _, id := range orderItemIds {
+		wanted[id] = true
+	}
+	result := []models.AppliedPromotion{}
+	orderEntries := map[string]int{}
+	for _, entry := range applied {
+		if len(orderItemIds) > 0 && !wanted[entry.Order_item_id] {
+			continue
+		}
+		if entry.Type != "PERCENT_ORDER" && entry.Type != "FIXED_ORDER" {
+			result = append(result, entry)
+			continue
+		}
+		if i, ok := orderEntries[entry.Promotion_id]; ok {
+			result[i].Amount = result[i].Amount.Add(entry.Amount)
+			continue
+		}
+		entry.Order_item_id = ""
+		orderEntries[entry.Promotion_id] = len(result)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// CheckCoupons makes sure every entered code belongs to a promotion that is
+// live and still has uses left for the order.
+func CheckCoupons(ctx context.Context, couponCodes []string, orderId string, at time.Time) error {
+	for _, code := range couponCodes {
+		var promotion models.Promotion
+		err := promotionCollection.FindOne(ctx, bson.M{"coupon_code": strings.ToUpper(code)}).Decode(&promotion)
+		if err != nil || !PromotionActive(promotion, at) {
+			return ErrInvalidCoupon
+		}
+		if !promotionAvailable(promotion, orderId) {
+			return ErrCouponExhausted
+		}
+	}
+	return nil
+}
+
+// RedeemCoupons counts the coupons applied to an invoice against their usage
+// limits. A coupon is used once per order, so splitting or recalculating the
+// bill of the same order does not use it again. The increment only happens
+// while uses are left, which keeps concurrent redemptions within the limit.
+// It returns the promotions whose use it took, for ReleaseRedeemedCoupons
+// to give back if the invoice is not saved, and gives them back itself when
+// a later coupon has run out.
+func RedeemCoupons(ctx context.Context, invoice models.Invoice) ([]string, error) {
+	if invoice.Order_id == nil {
+		return nil, nil
+	}
+	redeemed := []string{}
+	fail := func(err error) ([]string, error) {
+		if releaseErr := ReleaseRedeemedCoupons(ctx, *invoice.Order_id, redeemed); releaseErr != nil {
+			log.Println("could not release coupons of order", *invoice.Order_id, releaseErr)
+		}
+		return nil, err
+	}
+	for _, applied := range invoice.Promotions {
+		if applied.Coupon_code == "" {
+			continue
+		}
+		filter := bson.M{
+			"promotion_id":       applied.Promotion_id,
+			"redeemed_order_ids": bson.M{"$ne": *invoice.Order_id},
+			"$or": bson.A{
+				bson.M{"usage_limit": nil},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$usage_count", "$usage_limit"}}},
+			},
+		}
+		result, err := promotionCollection.UpdateOne(ctx, filter, bson.M{
+			"$inc":      bson.M{"usage_count": 1},
+			"$addToSet": bson.M{"redeemed_order_ids": *invoice.Order_id},
+		})
+		if err != nil {
+			return fail(err)
+		}
+		if result.MatchedCount == 0 {
+			count, err := promotionCollection.CountDocuments(ctx, bson.M{"promotion_id": applied.Promotion_id, "redeemed_order_ids": *invoice.Order_id})
+			if err != nil {
+				return fail(err)
+			}
+			if count == 0 {
+				return fail(ErrCouponExhausted)
+			}
+			continue
+		}
+		redeemed = append(redeemed, applied.Promotion_id)
+	}
+	return redeemed, nil
+}
+
+// ReleaseRedeemedCoupons gives back the uses RedeemCoupons took for an
+// order, leaving uses taken by its other invoices alone.
+func ReleaseRedeemedCoupons(ctx context.Context, orderId string, promotionIds []string) error {
+	if len(promotionIds) == 0 {
+		return nil
+	}
+	_, err := promotionCollection.UpdateMany(ctx, bson.M{"promotion_id": bson.M{"$in": promotionIds}, "redeemed_order_ids": orderId}, bson.M{
+		"$inc":  bson.M{"usage_count": -1},
+		"$pull": bson.M{"redeemed_order_ids": orderId},
+	})
+	return err
+}
+
+// ReleaseCoupons gives the coupon uses of an order back, for when the order
+// no longer has a live invoice.
+func ReleaseCoupons(ctx context.Context, orderId string) error {
+	_, err := promotionCollection.UpdateMany(ctx, bson.M{"redeemed_order_ids": orderId}, bson.M{
+		"$inc":  bson.M{"usage_count": -1},
+		"$pull": bson.M{"redeemed_order_ids": orderId},
+	})
+	return err
+}
+
+func loadPromotions(ctx context.Context) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	cursor, err := promotionCollection.Find(ctx, bson.M{"active": bson.M{"$ne": false}})
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &promotions)
+	return promotions, err
+}
+
+func couponEntered(promotion models.Promotion, couponCodes []string) bool {
+	if promotion.Coupon_code == nil {
+		return true
+	}
+	for _, code := range couponCodes {
+		if strings.EqualFold(code, *promotion.Coupon_code) {
+			return true
+		}
+	}
+	return false
+}
+
+func promotionAvailable(promotion models.Promotion, orderId string) bool {
+	if promotion.Usage_limit == nil || promotion.Usage_count < *promotion.Usage_limit {
+		return true
+	}
+	for _, id := range promotion.Redeemed_order_ids {
+		if id == orderId {
+			return true
+		}
+	}
+	return false
+}
+
+func isItemPromotion(promotion models.Promotion) bool {
+	return *promotion.Type != "PERCENT_ORDER" && *promotion.Type != "FIXED_ORDER"
+}
+
+func promotionSaving(applied []models.AppliedPromotion) int64 {
+	var saving int64
+	for _, a := range applied {
+		saving += a.Amount.Amount
+	}
+	return saving
+}
+
+func applyPromotionSequence(lines []models.InvoiceLine, promotions []models.Promotion, at time.Time) ([]models.InvoiceLine, []models.AppliedPromotion) {
+	result := make([]models.InvoiceLine, len(lines))
+	copy(result, lines)
+	var subtotal int64
+	for i := range result {
+		result[i].Discount = models.NewMoney(0, result[i].Line_total.Currency)
+		subtotal += result[i].Line_total.Amount
+	}
+
+	ordered := make([]models.Promotion, len(promotions))
+	copy(ordered, promotions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if isItemPromotion(ordered[i]) != isItemPromotion(ordered[j]) {
+			return isItemPromotion(ordered[i])
+		}
+		return promotionPriority(ordered[i]) > promotionPriority(ordered[j])
+	})
+
+	applied := []models.AppliedPromotion{}
+	for _, promotion := range ordered {
+		if promotion.Min_subtotal != nil && subtotal < promotion.Min_subtotal.Amount {
+			continue
+		}
+		if !isItemPromotion(promotion) && !PromotionActive(promotion, at) {
+			continue
+		}
+
+		discounts := promotionDiscounts(result, promotion, at)
+		entry := models.AppliedPromotion{Promotion_id: promotion.Promotion_id, Name: *promotion.Name, Type: *promotion.Type}
+		if promotion.Coupon_code != nil {
+			entry.Coupon_code = *promotion.Coupon_code
+		}
+		for i, discount := range discounts {
+			if discount <= 0 {
+				continue
+			}
+			amount := models.NewMoney(discount, result[i].Line_total.Currency)
+			result[i].Discount = result[i].Discount.Add(amount)
+			line := entry
+			line.Order_item_id = result[i].Order_item_id
+			line.Amount = amount
+			applied = append(applied, line)
+		}
+	}
+	return result, applied
+}
+
+// promotionDiscounts returns the discount one promotion gives each line,
+// never more than what is left of the line after earlier promotions.
+func promotionDiscounts(lines []models.InvoiceLine, promotion models.Promotion, at time.Time) []int64 {
+	discounts := make([]int64, len(lines))
+	remaining := make([]models.Money, len(lines))
+	matching := make([]bool, len(lines))
+	for i, line := range lines {
+		remaining[i] = line.Line_total.Sub(line.Discount)
+		orderedAt := line.Ordered_at
+		if orderedAt.IsZero() {
+			orderedAt = at
+		}
+		matching[i] = promotionCovers(promotion, line) && (!isItemPromotion(promotion) || PromotionActive(promotion, orderedAt))
+	}
+
+	percent := int64(10000)
+	if promotion.Percent_bps != nil {
+		percent = *promotion.Percent_bps
+	}
+
+	switch *promotion.Type {
+	case "PERCENT_ITEM":
+		for i := range lines {
+			if matching[i] {
+				discounts[i] = remaining[i].Percent(percent).Amount
+			}
+		}
+	case "FIXED_ITEM":
+		for i, line := range lines {
+			if matching[i] && promotion.Amount != nil {
+				discounts[i] = promotion.Amount.Mul(line.Quantity).Amount
+			}
+		}
+	case "BUY_X_GET_Y":
+		if promotion.Buy_quantity == nil || promotion.Get_quantity == nil {
+			break
+		}
+		type unit struct {
+			line  int
+			price models.Money
+		}
+		var units []unit
+		for i, line := range lines {
+			if !matching[i] {
+				continue
+			}
+			for q := int64(0); q < line.Quantity; q++ {
+				units = append(units, unit{line: i, price: line.Unit_price})
+			}
+		}
+		sort.SliceStable(units, func(i, j int) bool { return units[i].price.Amount < units[j].price.Amount })
+		free := int64(len(units)) / (*promotion.Buy_quantity + *promotion.Get_quantity) * *promotion.Get_quantity
+		for _, u := range units[:free] {
+			discounts[u.line] += u.price.Percent(percent).Amount
+		}
+	case "PERCENT_ORDER", "FIXED_ORDER":
+		base := models.Money{}
+		weights := make([]int64, len(lines))
+		for i := range lines {
+			if matching[i] {
+				weights[i] = remaining[i].Amount
+				base = base.Add(remaining[i])
+			}
+		}
+		discount := base.Percent(percent)
+		if *promotion.Type == "FIXED_ORDER" {
+			if promotion.Amount == nil {
+				break
+			}
+			discount = *promotion.Amount
+		}
+		discounts = spreadAmount(discount.Amount, weights)
+	}
+
+	for i := range discounts {
+		if discounts[i] > remaining[i].Amount {
+			discounts[i] = remaining[i].Amount
+		}
+	}
+	return discounts
+}
+
+func promotionCovers(promotion models.Promotion, line models.InvoiceLine) bool {
+	if len(promotion.Food_ids) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, id := range promotion.Food_ids {
+		if id == line.Food_id {
+			return true
+		}
+	}
+	for _, category := range promotion.Categories {
+		if strings.EqualFold(category, line.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+func promotionPriority(promotion models.Promotion) int {
+	if promotion.Priority == nil {
+		return 0
+	}
+	return *promotion.Priority
+}
+
+// spreadAmount splits amount over the weights pro rata, giving the rounding
+// remainder to the last weighted entry.
+func spreadAmount(amount int64, weights []int64) []int64 {
+	parts := make([]int64, len(weights))
+	var total int64
+	last := -1
+	for i, weight := range weights {
+		total += weight
+		if weight > 0 {
+			last = i
+		}
+	}
+	if total <= 0 || amount <= 0 {
+		return parts
+	}
+	if amount > total {
+		amount = total
+	}
+	remaining := amount
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		if i == last {
+			parts[i] = remaining
+			break
+		}
+		parts[i] = models.NewMoney(amount, "").Scale(weight, total).Amount
+		remaining -= parts[i]
+	}
+	return parts
+}
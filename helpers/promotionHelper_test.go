@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+	"time"
+)
+
+func orderLine(orderItemId string, amount int64) models.InvoiceLine {
+	price := models.NewMoney(amount, "USD")
+	return models.InvoiceLine{Order_item_id: orderItemId, Quantity: 1, Unit_price: price, Line_total: price}
+}
+
+func TestOrderPromotionsAreSharedAcrossSplits(t *testing.T) {
+	name, kind := "Ten off", "FIXED_ORDER"
+	amount := models.NewMoney(1000, "USD")
+	minimum := models.NewMoney(8000, "USD")
+	promotions := []models.Promotion{{Name: &name, Type: &kind, Amount: &amount, Min_subtotal: &minimum, Promotion_id: "p1"}}
+	lines := []models.InvoiceLine{orderLine("a", 6000), orderLine("b", 4000)}
+	at := time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)
+
+	var total int64
+	for _, split := range []struct {
+		item     string
+		discount int64
+	}{{"a", 600}, {"b", 400}} {
+		splitLines, applied := ApplyPromotions(lines, promotions, nil, "order", []string{split.item}, at)
+		if len(splitLines) != 1 || splitLines[0].Order_item_id != split.item {
+			t.Fatalf("split %s got lines %+v", split.item, splitLines)
+		}
+		if splitLines[0].Discount.Amount != split.discount {
+			t.Errorf("split %s discount %d, want %d", split.item, splitLines[0].Discount.Amount, split.discount)
+		}
+		if len(applied) != 1 || applied[0].Amount.Amount != split.discount || applied[0].Order_item_id != "" {
+			t.Errorf("split %s applied %+v", split.item, applied)
+		}
+		total += splitLines[0].Discount.Amount
+	}
+	if total != amount.Amount {
+		t.Fatalf("splits got %d off in total, want %d", total, amount.Amount)
+	}
+
+	wholeLines, applied := ApplyPromotions(lines, promotions, nil, "order", nil, at)
+	if len(wholeLines) != 2 || len(applied) != 1 || applied[0].Amount.Amount != 1000 {
+		t.Fatalf("whole order got %+v", applied)
+	}
+}
@@ -0,0 +1,283 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"golang-restaurant-management/models"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const ReceiptWidth = 42
+
+var accentColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{3,6}$`)
+
+// ValidAccentColor reports whether a branding accent colour is a plain hex
+// colour such as #c0392b, the only form the HTML receipt accepts.
+func ValidAccentColor(color string) bool {
+	return accentColorPattern.MatchString(color)
+}
+
+type Receipt struct {
+	Branding     models.Branding
+	Invoice      models.Invoice
+	Table_number *int
+	Payments     []models.Payment
+}
+
+type receiptRow struct {
+	Label  string
+	Amount string
+	Strong bool
+}
+
+// ReceiptText renders a fixed-width receipt suitable for a till printer or
+// a plain-text email.
+func ReceiptText(receipt Receipt, width int) string {
+	if width < 24 {
+		width = ReceiptWidth
+	}
+	var b strings.Builder
+	rule := strings.Repeat("-", width) + "\n"
+
+	for _, line := range receiptHeader(receipt.Branding) {
+		b.WriteString(center(line, width) + "\n")
+	}
+	b.WriteString(rule)
+	for _, line := range receiptMeta(receipt) {
+		b.WriteString(truncate(line, width) + "\n")
+	}
+	b.WriteString(rule)
+	for _, line := range receipt.Invoice.Line_items {
+		b.WriteString(columns(lineLabel(line), fmt.Sprintf("%d x %s", line.Quantity, line.Unit_price.Decimal()), line.Line_total.Decimal(), width) + "\n")
+		for _, modifier := range line.Modifiers {
+			b.WriteString(columns("  "+modifierLabel(modifier), "", "", width) + "\n")
+		}
+	}
+	b.WriteString(rule)
+	for _, row := range receiptTotals(receipt.Invoice) {
+		b.WriteString(columns(row.Label, "", row.Amount, width) + "\n")
+	}
+	if rows := receiptPayments(receipt); len(rows) > 0 {
+		b.WriteString(rule)
+		for _, row := range rows {
+			b.WriteString(columns(row.Label, "", row.Amount, width) + "\n")
+		}
+	}
+	if receipt.Branding.Footer != "" {
+		b.WriteString(rule)
+		for _, line := range strings.Split(receipt.Branding.Footer, "\n") {
+			b.WriteString(center(line, width) + "\n")
+		}
+	}
+	return b.String()
+}
+
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{"line": lineLabel, "modifier": modifierLabel}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; max-width: 480px; margin: 2em auto; color: #222; }
+header { text-align: center; border-bottom: 3px solid {{.Accent}}; padding-bottom: 1em; }
+header img { max-height: 80px; }
+table { width: 100%; border-collapse: collapse; margin: 1em 0; }
+td { padding: 4px 0; }
+td.amount { text-align: right; white-space: nowrap; }
+tr.strong td { font-weight: bold; border-top: 1px solid #ccc; }
+.meta, footer { color: #666; font-size: 0.9em; }
+footer { text-align: center; margin-top: 2em; }
+</style>
+</head>
+<body>
+<header>
+{{if .Branding.Logo_url}}<img src="{{.Branding.Logo_url}}" alt="">{{end}}
+{{range .Header}}<div>{{.}}</div>{{end}}
+</header>
+<div class="meta">{{range .Meta}}<div>{{.}}</div>{{end}}</div>
+<table>
+{{range .Lines}}<tr><td>{{line .}}{{range .Modifiers}}<br><small>{{modifier .}}</small>{{end}}</td><td>{{.Quantity}} &times; {{.Unit_price.Decimal}}</td><td class="amount">{{.Line_total.Decimal}}</td></tr>
+{{end}}</table>
+<table>
+{{range .Totals}}<tr{{if .Strong}} class="strong"{{end}}><td>{{.Label}}</td><td class="amount">{{.Amount}}</td></tr>
+{{end}}</table>
+{{if .Payments}}<table>
+{{range .Payments}}<tr><td>{{.Label}}</td><td class="amount">{{.Amount}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Branding.Footer}}<footer>{{.Branding.Footer}}</footer>{{end}}
+</body>
+</html>
+`))
+
+// ReceiptHTML renders the receipt as a standalone HTML page using the
+// restaurant branding from the settings.
+func ReceiptHTML(receipt Receipt) ([]byte, error) {
+	accent := receipt.Branding.Accent_color
+	if !ValidAccentColor(accent) {
+		accent = "#222"
+	}
+	data := map[string]interface{}{
+		"Title":    "Invoice " + receiptNumber(receipt.Invoice),
+		"Accent":   accent,
+		"Branding": receipt.Branding,
+		"Header":   receiptHeader(receipt.Branding),
+		"Meta":     receiptMeta(receipt),
+		"Lines":    receipt.Invoice.Line_items,
+		"Totals":   receiptTotals(receipt.Invoice),
+		"Payments": receiptPayments(receipt),
+	}
+	var out bytes.Buffer
+	err := receiptTemplate.Execute(&out, data)
+	return out.Bytes(), err
+}
+
+// ReceiptPDF lays the text receipt out on A4 pages in Courier, which every
+// PDF reader ships with, so no font embedding is needed.
+func ReceiptPDF(receipt Receipt) []byte {
+	lines := strings.Split(strings.TrimRight(ReceiptText(receipt, 64), "\n"), "\n")
+	return textPDF(lines)
+}
+
+func receiptHeader(branding models.Branding) []string {
+	header := []string{}
+	for _, line := range []string{branding.Restaurant_name, branding.Address, branding.Phone} {
+		if line != "" {
+			header = append(header, line)
+		}
+	}
+	if branding.Tax_id != "" {
+		header = append(header, "Tax ID: "+branding.Tax_id)
+	}
+	return header
+}
+
+func receiptMeta(receipt Receipt) []string {
+	meta := []string{"Invoice: " + receiptNumber(receipt.Invoice)}
+	if !receipt.Invoice.Created_at.IsZero() {
+		meta = append(meta, "Date: "+receipt.Invoice.Created_at.Format("2006-01-02 15:04"))
+	}
+	if receipt.Table_number != nil {
+		meta = append(meta, "Table: "+strconv.Itoa(*receipt.Table_number))
+	}
+	if receipt.Invoice.Payment_status != nil {
+		meta = append(meta, "Status: "+*receipt.Invoice.Payment_status)
+	}
+	return meta
+}
+
+func receiptNumber(invoice models.Invoice) string {
+	if invoice.Invoice_number != nil {
+		return *invoice.Invoice_number
+	}
+	return invoice.Invoice_id
+}
+
+func receiptTotals(invoice models.Invoice) []receiptRow {
+	rows := []receiptRow{}
+	add := func(label string, m *models.Money, always bool) {
+		if m != nil && (always || !m.IsZero()) {
+			rows = append(rows, receiptRow{Label: label, Amount: m.Decimal()})
+		}
+	}
+	add("Subtotal", invoice.Subtotal, true)
+	if invoice.Discount_total != nil && !invoice.Discount_total.IsZero() {
+		discount := invoice.Discount_total.Neg()
+		add("Discount", &discount, false)
+	}
+	add("Service charge", invoice.Service_charge, false)
+	for _, tax := range invoice.Taxes {
+		label := tax.Name + " " + formatRate(tax.Rate_bps)
+		if tax.Inclusive {
+			label += " (incl.)"
+		}
+		amount := tax.Amount
+		add(label, &amount, true)
+	}
+	add("Tip", invoice.Tip, false)
+	if invoice.Total != nil {
+		rows = append(rows, receiptRow{Label: "TOTAL " + invoice.Total.Currency, Amount: invoice.Total.Decimal(), Strong: true})
+	}
+	for _, adjustment := range invoice.Adjustments {
+		amount := adjustment.Amount
+		label := adjustment.Type[:1] + strings.ToLower(adjustment.Type[1:])
+		add(label+" ("+adjustment.Reason_code+")", &amount, true)
+	}
+	return rows
+}
+
+func receiptPayments(receipt Receipt) []receiptRow {
+	rows := []receiptRow{}
+	for _, payment := range receipt.Payments {
+		if payment.Status == "VOIDED" || payment.Status == "PENDING" || payment.Tender == nil || payment.Amount == nil {
+			continue
+		}
+		label := "Paid " + strings.ReplaceAll(*payment.Tender, "_", " ")
+		amount := *payment.Amount
+		if payment.Tendered != nil && payment.Tendered.Amount > amount.Amount {
+			amount = *payment.Tendered
+		}
+		rows = append(rows, receiptRow{Label: label, Amount: amount.Decimal()})
+		if payment.Change_given != nil && !payment.Change_given.IsZero() {
+			rows = append(rows, receiptRow{Label: "Change", Amount: payment.Change_given.Decimal()})
+		}
+	}
+	if receipt.Invoice.Balance_due != nil && len(rows) > 0 {
+		rows = append(rows, receiptRow{Label: "Balance due", Amount: receipt.Invoice.Balance_due.Decimal(), Strong: true})
+	}
+	return rows
+}
+
+func formatRate(bps int64) string {
+	rate := strconv.FormatInt(bps/100, 10)
+	if fraction := bps % 100; fraction != 0 {
+		rate += "." + strings.TrimRight(fmt.Sprintf("%02d", fraction), "0")
+	}
+	return rate + "%"
+}
+
+// lineLabel names a receipt line with its variant, e.g. "Pizza (Large)".
+func lineLabel(line models.InvoiceLine) string {
+	if line.Variant_name == "" {
+		return line.Name
+	}
+	return line.Name + " (" + line.Variant_name + ")"
+}
+
+// modifierLabel describes a modifier on a receipt line, with its price
+// delta when it changes the price.
+func modifierLabel(modifier models.SelectedModifier) string {
+	if modifier.Price_delta.IsZero() {
+		return "+ " + modifier.Name
+	}
+	return "+ " + modifier.Name + " (" + modifier.Price_delta.Decimal() + ")"
+}
+
+func columns(left string, middle string, right string, width int) string {
+	rightPart := right
+	if middle != "" {
+		rightPart = middle + "  " + right
+	}
+	space := width - len([]rune(rightPart)) - 1
+	if space < 1 {
+		space = 1
+	}
+	left = truncate(left, space)
+	return left + strings.Repeat(" ", width-len([]rune(left))-len([]rune(rightPart))) + rightPart
+}
+
+func center(s string, width int) string {
+	s = truncate(s, width)
+	pad := (width - len([]rune(s))) / 2
+	return strings.Repeat(" ", pad) + s
+}
+
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width])
+}
@@ -0,0 +1,25 @@
+package helpers
+
+import (
+	"bytes"
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestReceiptHTMLAccentColor(t *testing.T) {
+	total := models.NewMoney(1000, "USD")
+	for accent, want := range map[string]string{
+		"#c0392b":                         "solid #c0392b;",
+		"red;}</style><script>x</script>": "solid #222;",
+		"":                                "solid #222;",
+	} {
+		receipt := Receipt{Branding: models.Branding{Accent_color: accent}, Invoice: models.Invoice{Total: &total}}
+		page, err := ReceiptHTML(receipt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(page, []byte(want)) || bytes.Contains(page, []byte("<script>")) {
+			t.Errorf("accent %q: header border is not %q", accent, want)
+		}
+	}
+}
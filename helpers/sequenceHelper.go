@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"log"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type counter struct {
+	Counter_id string `bson:"_id"`
+	Seq        int64  `bson:"seq"`
+}
+
+var counterCollection *mongo.Collection = database.OpenCollection(database.Client, "counter")
+var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
+
+// InsertInvoice gives a new invoice the next fiscal number for its location
+// and year and stores it. The counter is bumped with a single atomic update,
+// so concurrent calls never share a number, and the number is taken before
+// the insert so an invoice is never stored without one. A number that is
+// already taken is skipped for the next one. Numbers are never handed out
+// twice, so the series stays in chronological order; when the insert fails
+// for any other reason the number is recorded as a VOID invoice instead, so
+// the series also stays gapless.
+func InsertInvoice(ctx context.Context, invoice *models.Invoice) (*mongo.InsertOneResult, error) {
+	prefix := "INV"
+	var setting models.Setting
+	if err := settingCollection.FindOne(ctx, bson.M{"setting_id": models.DefaultSettingId}).Decode(&setting); err == nil && setting.Invoice_prefix != nil {
+		prefix = *setting.Invoice_prefix
+	}
+
+	location := ""
+	if invoice.Location != nil {
+		location = *invoice.Location
+	}
+	year := invoice.Created_at.Year()
+	if invoice.Created_at.IsZero() {
+		year = time.Now().Year()
+	}
+	counterId := location + ":" + strconv.Itoa(year)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		seq, err := nextSequence(ctx, counterId)
+		if err != nil {
+			return nil, err
+		}
+
+		number := FormatInvoiceNumber(prefix, location, year, seq)
+		invoice.Invoice_number = &number
+		invoice.Fiscal_year = year
+		invoice.Sequence = seq
+
+		result, err := invoiceCollection.InsertOne(ctx, invoice)
+		if err == nil {
+			return result, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			voidInvoiceNumber(ctx, invoice)
+			invoice.Invoice_number, invoice.Fiscal_year, invoice.Sequence = nil, 0, 0
+			return nil, err
+		}
+	}
+	invoice.Invoice_number, invoice.Fiscal_year, invoice.Sequence = nil, 0, 0
+	return nil, ErrInvoiceNumberTaken
+}
+
+// voidInvoiceNumber stores a VOID placeholder carrying the number of an
+// invoice that could not be inserted, so the number still shows up in the
+// series.
+func voidInvoiceNumber(ctx context.Context, invoice *models.Invoice) {
+	status := "VOID"
+	reason := "NUMBER_NOT_USED"
+	placeholder := models.Invoice{
+		ID:             primitive.NewObjectID(),
+		Invoice_number: invoice.Invoice_number,
+		Fiscal_year:    invoice.Fiscal_year,
+		Sequence:       invoice.Sequence,
+		Payment_status: &status,
+		Location:       invoice.Location,
+		Void_reason:    &reason,
+		Created_at:     invoice.Created_at,
+		Updated_at:     time.Now(),
+	}
+	placeholder.Invoice_id = placeholder.ID.Hex()
+	if placeholder.Created_at.IsZero() {
+		placeholder.Created_at = placeholder.Updated_at
+	}
+
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if _, err = invoiceCollection.InsertOne(ctx, placeholder); err == nil || mongo.IsDuplicateKeyError(err) {
+			return
+		}
+	}
+	log.Println("could not record unused invoice number", *invoice.Invoice_number, err)
+}
+
+var ErrInvoiceNumberTaken = errors.New("could not allocate a free invoice number, please retry")
+
+func FormatInvoiceNumber(prefix string, location string, year int, seq int64) string {
+	if location == "" {
+		return fmt.Sprintf("%s-%d-%06d", prefix, year, seq)
+	}
+	return fmt.Sprintf("%s-%s-%d-%06d", prefix, location, year, seq)
+}
+
+func nextSequence(ctx context.Context, counterId string) (int64, error) {
+	var next counter
+	err := counterCollection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": counterId},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&next)
+	return next.Seq, err
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var shiftCollection *mongo.Collection = database.OpenCollection(database.Client, "shift")
+var paymentCollection *mongo.Collection = database.OpenCollection(database.Client, "payment")
+var refundCollection *mongo.Collection = database.OpenCollection(database.Client, "refund")
+
+// OpenShiftFor returns the shift the user currently has open, or nil when
+// they are not working a drawer. Cash taken or paid out by the user is
+// booked against this shift.
+func OpenShiftFor(ctx context.Context, userId string) *models.Shift {
+	var shift models.Shift
+	err := shiftCollection.FindOne(ctx, bson.M{"opened_by": userId, "status": "OPEN"}).Decode(&shift)
+	if err != nil {
+		return nil
+	}
+	return &shift
+}
+
+// SummarizeShift works out how much cash should be in the drawer: the
+// opening float plus cash sales, less cash refunds, drops to the safe and
+// payouts. Voided cash payments never reached the drawer and are skipped.
+func SummarizeShift(ctx context.Context, shift models.Shift) (models.ShiftSummary, error) {
+	currency := shift.Opening_float.Currency
+	summary := models.ShiftSummary{
+		Opening_float: *shift.Opening_float,
+		Cash_sales:    models.NewMoney(0, currency),
+		Cash_refunds:  models.NewMoney(0, currency),
+		Drops:         models.NewMoney(0, currency),
+		Payouts:       models.NewMoney(0, currency),
+	}
+
+	result, err := paymentCollection.Find(ctx, bson.M{"shift_id": shift.Shift_id, "tender": "CASH", "status": "CAPTURED"})
+	if err != nil {
+		return summary, err
+	}
+	var payments []models.Payment
+	if err = result.All(ctx, &payments); err != nil {
+		return summary, err
+	}
+	for _, payment := range payments {
+		summary.Cash_sales = summary.Cash_sales.Add(*payment.Amount)
+	}
+
+	result, err = refundCollection.Find(ctx, bson.M{"shift_id": shift.Shift_id, "tender": "CASH", "status": bson.M{"$ne": "FAILED"}})
+	if err != nil {
+		return summary, err
+	}
+	var refunds []models.Refund
+	if err = result.All(ctx, &refunds); err != nil {
+		return summary, err
+	}
+	for _, refund := range refunds {
+		summary.Cash_refunds = summary.Cash_refunds.Add(*refund.Amount)
+	}
+
+	for _, movement := range shift.Cash_movements {
+		if movement.Type == "DROP" {
+			summary.Drops = summary.Drops.Add(*movement.Amount)
+		} else {
+			summary.Payouts = summary.Payouts.Add(*movement.Amount)
+		}
+	}
+
+	summary.Expected_cash = summary.Opening_float.
+		Add(summary.Cash_sales).
+		Sub(summary.Cash_refunds).
+		Sub(summary.Drops).
+		Sub(summary.Payouts)
+	return summary, nil
+}
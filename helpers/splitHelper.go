@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+)
+
+// ApplySplitShare reduces a fully calculated invoice to the shares it covers
+// when the bill was split evenly. Every amount is allocated on its own with
+// remainder cents going to the lowest share indexes, and the total is rebuilt
+// from the allocated parts so the split invoices always add up to the bill.
+// Tips are left alone because each payer tips on their own invoice.
+func ApplySplitShare(invoice *models.Invoice) {
+	if invoice.Split_count <= 1 || len(invoice.Split_shares) == 0 {
+		return
+	}
+
+	share := func(m *models.Money) models.Money {
+		if m == nil {
+			return models.Money{}
+		}
+		parts := m.Allocate(invoice.Split_count)
+		result := models.NewMoney(0, m.Currency)
+		for _, index := range invoice.Split_shares {
+			if index >= 0 && index < len(parts) {
+				result = result.Add(parts[index])
+			}
+		}
+		return result
+	}
+
+	subtotal := share(invoice.Subtotal)
+	discount := share(invoice.Discount_total)
+	service := share(invoice.Service_charge)
+	tip := models.NewMoney(0, subtotal.Currency)
+	if invoice.Tip != nil {
+		tip = *invoice.Tip
+	}
+
+	taxTotal := models.NewMoney(0, subtotal.Currency)
+	exclusiveTax := models.NewMoney(0, subtotal.Currency)
+	for i := range invoice.Taxes {
+		invoice.Taxes[i].Taxable = share(&invoice.Taxes[i].Taxable)
+		invoice.Taxes[i].Amount = share(&invoice.Taxes[i].Amount)
+		taxTotal = taxTotal.Add(invoice.Taxes[i].Amount)
+		if !invoice.Taxes[i].Inclusive {
+			exclusiveTax = exclusiveTax.Add(invoice.Taxes[i].Amount)
+		}
+	}
+
+	total := subtotal.Sub(discount).Add(service).Add(exclusiveTax).Add(tip)
+
+	invoice.Subtotal = &subtotal
+	invoice.Discount_total = &discount
+	invoice.Service_charge = &service
+	invoice.Tax_total = &taxTotal
+	invoice.Total = &total
+}
+
+func filterInvoiceLines(lines []models.InvoiceLine, orderItemIds []string) []models.InvoiceLine {
+	if len(orderItemIds) == 0 {
+		return lines
+	}
+	wanted := map[string]bool{}
+	for _, id := range orderItemIds {
+		wanted[id] = true
+	}
+	filtered := []models.InvoiceLine{}
+	for _, line := range lines {
+		if wanted[line.Order_item_id] {
+			filtered = append(filtered, line)
+		}
+	}
+	return filtered
+}
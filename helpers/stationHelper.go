@@ -0,0 +1,139 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var stationCollection *mongo.Collection = database.OpenCollection(database.Client, "station")
+var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
+
+// StationFor picks the station that prepares a food. A station set on the
+// food wins over one set on its menu, which wins over an active station
+// that lists the menu's category. It returns nil when nothing matches.
+func StationFor(ctx context.Context, foodId string) *string {
+	var food models.Food
+	var menu models.Menu
+	if err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err != nil {
+		return nil
+	}
+	if food.Station_id != nil {
+		return food.Station_id
+	}
+	if food.Menu_id == nil {
+		return nil
+	}
+	if err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err != nil {
+		return nil
+	}
+	if menu.Station_id != nil {
+		return menu.Station_id
+	}
+	if menu.Category == "" {
+		return nil
+	}
+
+	var station models.Station
+	err := stationCollection.FindOne(ctx, bson.M{"categories": menu.Category, "active": bson.M{"$ne": false}}).Decode(&station)
+	if err != nil {
+		return nil
+	}
+	return &station.Station_id
+}
+
+// StationsById loads every station keyed by its id.
+func StationsById(ctx context.Context) (map[string]models.Station, error) {
+	cursor, err := stationCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var stations []models.Station
+	if err = cursor.All(ctx, &stations); err != nil {
+		return nil, err
+	}
+	byId := map[string]models.Station{}
+	for _, station := range stations {
+		byId[station.Station_id] = station
+	}
+	return byId, nil
+}
+
+// StationPrinters returns the kitchen printers assigned to a station.
+func StationPrinters(printers []models.Printer, station models.Station) []models.Printer {
+	matched := []models.Printer{}
+	for _, printer := range printers {
+		if printer.Role == nil || *printer.Role != "KITCHEN" {
+			continue
+		}
+		for _, id := range station.Printer_ids {
+			if id == printer.Printer_id {
+				matched = append(matched, printer)
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// StationQueue lists the items a station still has to prepare, highest
+// priority first and then in the order they were queued.
+func StationQueue(ctx context.Context, stationId string) ([]primitive.M, error) {
+	matchStage := bson.D{{"$match", bson.D{
+		{"station_id", stationId},
+		{"status", bson.D{{"$in", bson.A{"QUEUED", "FIRING", nil}}}},
+		{"hold", bson.D{{"$ne", true}}},
+	}}}
+	sortStage := bson.D{{"$sort", bson.D{{"priority", -1}, {"queued_at", 1}, {"created_at", 1}}}}
+	lookupStage := bson.D{{"$lookup", bson.D{{"from", "food"}, {"localField", "food_id"}, {"foreignField", "food_id"}, {"as", "food"}}}}
+	unwindStage := bson.D{{"$unwind", bson.D{{"path", "$food"}, {"preserveNullAndEmptyArrays", true}}}}
+	lookupOrderStage := bson.D{{"$lookup", bson.D{{"from", "order"}, {"localField", "order_id"}, {"foreignField", "order_id"}, {"as", "order"}}}}
+	unwindOrderStage := bson.D{{"$unwind", bson.D{{"path", "$order"}, {"preserveNullAndEmptyArrays", true}}}}
+	lookupTableStage := bson.D{{"$lookup", bson.D{{"from", "table"}, {"localField", "order.table_id"}, {"foreignField", "table_id"}, {"as", "table"}}}}
+	unwindTableStage := bson.D{{"$unwind", bson.D{{"path", "$table"}, {"preserveNullAndEmptyArrays", true}}}}
+	lookupNoteStage := bson.D{{"$lookup", bson.D{{"from", "note"}, {"localField", "order_item_id"}, {"foreignField", "entity_id"}, {"as", "notes"}}}}
+	projectStage := bson.D{{"$project", bson.D{
+		{"_id", 0},
+		{"order_item_id", 1},
+		{"order_id", 1},
+		{"food_id", 1},
+		{"name", "$food.name"},
+		{"quantity", bson.D{{"$ifNull", bson.A{"$quantity", 1}}}},
+		{"variant_name", 1},
+		{"modifiers", "$modifiers.name"},
+		{"notes", bson.D{{"$map", bson.D{
+			{"input", bson.D{{"$filter", bson.D{{"input", "$notes"}, {"cond", bson.D{{"$eq", bson.A{"$$this.visibility", "KITCHEN"}}}}}}}},
+			{"in", "$$this.text"},
+		}}}},
+		{"seat_number", 1},
+		{"table_number", "$table.table_number"},
+		{"priority", bson.D{{"$ifNull", bson.A{"$priority", 0}}}},
+		{"course", bson.D{{"$ifNull", bson.A{"$course", 1}}}},
+		{"status", bson.D{{"$ifNull", bson.A{"$status", "QUEUED"}}}},
+		{"queued_at", bson.D{{"$ifNull", bson.A{"$queued_at", "$created_at"}}}},
+		{"fired_at", 1},
+	}}}
+
+	cursor, err := orderitemCollection.Aggregate(ctx, mongo.Pipeline{
+		matchStage,
+		sortStage,
+		lookupStage,
+		unwindStage,
+		lookupOrderStage,
+		unwindOrderStage,
+		lookupTableStage,
+		unwindTableStage,
+		lookupNoteStage,
+		projectStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	queue := []primitive.M{}
+	err = cursor.All(ctx, &queue)
+	return queue, err
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"sort"
+	"time"
+)
+
+// ApplicableTaxRates keeps the rates that apply to a sale at location, for
+// the given order type and date, ordered by priority so compound taxes are
+// applied after the taxes they compound on.
+func ApplicableTaxRates(rates []models.TaxRate, location string, orderType string, at time.Time) []models.TaxRate {
+	applicable := []models.TaxRate{}
+	for _, rate := range rates {
+		if rate.Location != nil && *rate.Location != "" && *rate.Location != location {
+			continue
+		}
+		if rate.Order_type != nil && *rate.Order_type != "" && *rate.Order_type != orderType {
+			continue
+		}
+		if rate.Effective_from != nil && at.Before(*rate.Effective_from) {
+			continue
+		}
+		if rate.Effective_to != nil && !at.Before(*rate.Effective_to) {
+			continue
+		}
+		applicable = append(applicable, rate)
+	}
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return ratePriority(applicable[i]) < ratePriority(applicable[j])
+	})
+	return applicable
+}
+
+// CalculateTaxes works out the tax summary for lines whose discounted
+// amounts are given in net. Inclusive taxes are backed out of the line
+// amount, exclusive taxes are added on top and compound taxes are charged on
+// the line plus the exclusive taxes applied before them. Amounts are kept in
+// 1/10000 of a minor unit until the per-rate totals are rounded.
+func CalculateTaxes(lines []models.InvoiceLine, net []models.Money, rates []models.TaxRate, currency string) (taxes []models.InvoiceTax, exclusive models.Money, total models.Money) {
+	const scale = 10000
+	taxable := make([]int64, len(rates))
+	amounts := make([]int64, len(rates))
+
+	for i, line := range lines {
+		var inclusiveBps int64
+		for _, rate := range rates {
+			if isInclusive(rate) && appliesTo(rate, line) {
+				inclusiveBps += *rate.Rate_bps
+			}
+		}
+
+		base := net[i].Amount * scale * 10000 / (10000 + inclusiveBps)
+		var prior int64
+		for r, rate := range rates {
+			if !appliesTo(rate, line) {
+				continue
+			}
+			lineBase := base
+			if isCompound(rate) && !isInclusive(rate) {
+				lineBase += prior
+			}
+			tax := lineBase * *rate.Rate_bps / 10000
+			taxable[r] += lineBase
+			amounts[r] += tax
+			if !isInclusive(rate) {
+				prior += tax
+			}
+		}
+	}
+
+	taxes = []models.InvoiceTax{}
+	exclusive = models.NewMoney(0, currency)
+	total = models.NewMoney(0, currency)
+	for r, rate := range rates {
+		if taxable[r] == 0 {
+			continue
+		}
+		amount := models.NewMoney(amounts[r], currency).Scale(1, scale)
+		tax := models.InvoiceTax{
+			Tax_rate_id: rate.Tax_rate_id,
+			Name:        *rate.Name,
+			Rate_bps:    *rate.Rate_bps,
+			Inclusive:   isInclusive(rate),
+			Compound:    isCompound(rate),
+			Taxable:     models.NewMoney(taxable[r], currency).Scale(1, scale),
+			Amount:      amount,
+		}
+		if rate.Location != nil {
+			tax.Location = *rate.Location
+		}
+		taxes = append(taxes, tax)
+		total = total.Add(amount)
+		if !tax.Inclusive {
+			exclusive = exclusive.Add(amount)
+		}
+	}
+	return taxes, exclusive, total
+}
+
+func appliesTo(rate models.TaxRate, line models.InvoiceLine) bool {
+	if len(rate.Tax_categories) == 0 {
+		return true
+	}
+	for _, category := range rate.Tax_categories {
+		if category == line.Tax_category {
+			return true
+		}
+	}
+	return false
+}
+
+func isInclusive(rate models.TaxRate) bool {
+	return rate.Inclusive != nil && *rate.Inclusive
+}
+
+func isCompound(rate models.TaxRate) bool {
+	return rate.Compound != nil && *rate.Compound
+}
+
+func ratePriority(rate models.TaxRate) int {
+	if rate.Priority == nil {
+		return 0
+	}
+	return *rate.Priority
+}
@@ -91,21 +91,24 @@ func UpdateAllToken(signedToken string, signedrefreshToken string, userId string
 func ValidateAllToken(signedToken string) (claims *signedDetails, msg string) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
-		signedDetails{},
+		&signedDetails{},
 		func(token *jwt.Token) (interface{}, error) {
 			return []byte(SECRETKEY), nil
 		},
 	)
 
+	if err != nil {
+		msg = err.Error()
+		return
+	}
+
 	claims, ok := token.Claims.(*signedDetails)
 	if !ok {
 		msg = fmt.Sprint("invlaid Token")
-		msg = err.Error()
 		return
 	}
 	if claims.ExpiresAt < time.Now().Local().Unix() {
 		msg = fmt.Sprint("Token is Expired")
-		msg = err.Error()
 		return
 	}
 	return claims, msg
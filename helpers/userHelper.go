@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"context"
+	"golang-restaurant-management/models"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SeedAdmin creates the first ADMIN from ADMIN_EMAIL and ADMIN_PASSWORD when
+// no admin exists yet. Only admins can create managers and other admins, so
+// a fresh install needs one to start from. It does nothing once an admin
+// exists, so it is safe to run on every start.
+func SeedAdmin() {
+	email := os.Getenv("ADMIN_EMAIL")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+	if len(password) < 6 {
+		log.Println("ADMIN_PASSWORD must be at least 6 characters, not seeding an admin")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	count, err := userCollection.CountDocuments(ctx, bson.M{"role": "ADMIN"})
+	if err != nil {
+		log.Println("could not check for an admin", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	if err != nil {
+		log.Println("could not hash the admin password", err)
+		return
+	}
+	hashed := string(hash)
+	firstName := "Admin"
+	lastName := "User"
+	phone := os.Getenv("ADMIN_PHONE")
+	role := "ADMIN"
+
+	user := models.User{
+		First_name: &firstName,
+		Last_name:  &lastName,
+		Email:      &email,
+		Password:   &hashed,
+		Phone:      &phone,
+		Role:       &role,
+	}
+	user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	user.ID = primitive.NewObjectID()
+	user.User_id = user.ID.Hex()
+
+	if _, err = userCollection.InsertOne(ctx, user); err != nil {
+		log.Println("could not seed the admin", err)
+		return
+	}
+	log.Println("seeded admin", email)
+}
@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"errors"
+	"golang-restaurant-management/models"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PrepareVariants checks the variants of a food and gives new ones their
+// ids. Sizes and SKUs must be unique within the food.
+func PrepareVariants(variants []models.FoodVariant) error {
+	sizes := map[string]bool{}
+	skus := map[string]bool{}
+	for i := range variants {
+		variant := &variants[i]
+		if variant.Size != nil {
+			size := strings.ToUpper(strings.TrimSpace(*variant.Size))
+			if sizes[size] {
+				return errors.New("variant size " + size + " is used twice")
+			}
+			sizes[size] = true
+			variant.Size = &size
+		}
+		if variant.Sku != nil {
+			if skus[*variant.Sku] {
+				return errors.New("variant sku " + *variant.Sku + " is used twice")
+			}
+			skus[*variant.Sku] = true
+		}
+		if variant.Variant_id == "" {
+			variant.Variant_id = primitive.NewObjectID().Hex()
+		}
+		price := models.NewMoney(variant.Price.Amount, variant.Price.Currency)
+		variant.Price = &price
+	}
+	return nil
+}
+
+// ResolveVariant finds the variant of a food an order item asks for, by id
+// or by size. A food with a single variant needs no choice. It returns nil
+// for foods without variants.
+func ResolveVariant(food models.Food, variantId *string, size *string) (*models.FoodVariant, error) {
+	if len(food.Variants) == 0 {
+		if variantId != nil || size != nil {
+			return nil, errors.New("this food has no variants")
+		}
+		return nil, nil
+	}
+	for i, variant := range food.Variants {
+		if variantId != nil && variant.Variant_id == *variantId {
+			return &food.Variants[i], nil
+		}
+		if variantId == nil && size != nil && variant.Size != nil && strings.EqualFold(*variant.Size, strings.TrimSpace(*size)) {
+			return &food.Variants[i], nil
+		}
+	}
+	if variantId != nil || size != nil {
+		return nil, errors.New("variant is not offered with this food")
+	}
+	if len(food.Variants) == 1 {
+		return &food.Variants[0], nil
+	}
+	return nil, errors.New("choose a variant of this food")
+}
+
+// PriceOrderItem fills in the variant, unit price and modifiers of an
+// order item from its food. A unit price already on the item is kept as an
+// override; otherwise the variant price, or the food price, is used.
+// Modifiers have to be priced in the same currency as the item.
+func PriceOrderItem(food models.Food, item *models.OrderItem) error {
+	variant, err := ResolveVariant(food, item.Variant_id, item.Size)
+	if err != nil {
+		return err
+	}
+	item.Variant_id, item.Variant_name, item.Sku = nil, nil, nil
+	price := food.Price
+	if variant != nil {
+		item.Variant_id = &variant.Variant_id
+		item.Variant_name = variant.Name
+		item.Sku = variant.Sku
+		item.Size = variant.Size
+		price = variant.Price
+	}
+	if item.Unit_price == nil {
+		if price == nil {
+			return errors.New("food has no price")
+		}
+		item.Unit_price = price
+	}
+	unitPrice := models.NewMoney(item.Unit_price.Amount, item.Unit_price.Currency)
+	item.Unit_price = &unitPrice
+
+	if item.Quantity == nil {
+		quantity := int64(1)
+		item.Quantity = &quantity
+	}
+
+	item.Modifiers, err = ResolveModifiers(food, item.Modifiers)
+	if err != nil {
+		return err
+	}
+	for _, modifier := range item.Modifiers {
+		if modifier.Price_delta.Currency != unitPrice.Currency {
+			return errors.New("modifier " + modifier.Name + " is priced in " + modifier.Price_delta.Currency + ", not " + unitPrice.Currency)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestPriceOrderItemChecksModifierCurrency(t *testing.T) {
+	groupName, optionName := "Extras", "Cheese"
+	price := models.NewMoney(1200, "USD")
+	food := models.Food{
+		Price: &price,
+		Modifier_groups: []models.ModifierGroup{{
+			Modifier_group_id: "g1",
+			Name:              &groupName,
+			Options:           []models.ModifierOption{{Modifier_option_id: "o1", Name: &optionName, Price_delta: models.NewMoney(150, "EUR")}},
+		}},
+	}
+	selected := []models.SelectedModifier{{Modifier_group_id: "g1", Modifier_option_id: "o1"}}
+
+	item := models.OrderItem{Modifiers: selected}
+	if err := PriceOrderItem(food, &item); err == nil {
+		t.Fatal("a EUR modifier was accepted on a USD item")
+	}
+
+	food.Modifier_groups[0].Options[0].Price_delta = models.NewMoney(150, "USD")
+	item = models.OrderItem{Modifiers: selected}
+	if err := PriceOrderItem(food, &item); err != nil {
+		t.Fatal(err)
+	}
+	if item.Unit_price.Amount != 1200 || len(item.Modifiers) != 1 {
+		t.Fatalf("priced %+v", item)
+	}
+}
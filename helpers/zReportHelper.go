@@ -0,0 +1,254 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"golang-restaurant-management/database"
+	"golang-restaurant-management/models"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const BusinessDateLayout = "2006-01-02"
+
+var zReportCollection *mongo.Collection = database.OpenCollection(database.Client, "zreport")
+
+var ErrInvoicesOpen = errors.New("settle or void the day's open invoices before closing it")
+
+// BusinessDay returns the local start and end of a business date such as
+// 2026-10-19.
+func BusinessDay(date string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(BusinessDateLayout, date, time.Local)
+	if err != nil {
+		return start, start, err
+	}
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+// BusinessDayClosed reports whether the Z report for the business day that
+// contains at has already been run for the location.
+func BusinessDayClosed(ctx context.Context, location *string, at time.Time) bool {
+	count, err := zReportCollection.CountDocuments(ctx, bson.M{
+		"business_date": at.In(time.Local).Format(BusinessDateLayout),
+		"location":      locationKey(location),
+	})
+	return err == nil && count > 0
+}
+
+// InvoiceLocked reports whether the invoice belongs to a closed business day
+// and must no longer be edited.
+func InvoiceLocked(invoice models.Invoice) bool {
+	return invoice.Z_report_id != nil
+}
+
+// BuildZReport totals a business day for one location. Sales, taxes and
+// categories come from the invoices raised that day, tenders from payments
+// taken that day and refunds from refunds issued that day, whichever day
+// the refunded invoice belongs to. It also returns the ids of the day's
+// invoices so the caller can lock them. Invoices still waiting for payment
+// are counted in Open_count; closing the day has to refuse them.
+func BuildZReport(ctx context.Context, date string, location string) (models.ZReport, []string, error) {
+	var report models.ZReport
+	start, end, err := BusinessDay(date)
+	if err != nil {
+		return report, nil, err
+	}
+	window := bson.M{"$gte": start, "$lt": end}
+
+	locationFilter := interface{}(location)
+	if location == "" {
+		locationFilter = nil
+	}
+
+	var invoices []models.Invoice
+	cursor, err := invoiceCollection.Find(ctx, bson.M{"created_at": window, "location": locationFilter})
+	if err != nil {
+		return report, nil, err
+	}
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return report, nil, err
+	}
+
+	locationInvoiceIds, err := invoiceCollection.Distinct(ctx, "invoice_id", bson.M{"location": locationFilter})
+	if err != nil {
+		return report, nil, err
+	}
+
+	var payments []models.Payment
+	cursor, err = paymentCollection.Find(ctx, bson.M{"created_at": window, "status": "CAPTURED", "invoice_id": bson.M{"$in": locationInvoiceIds}})
+	if err != nil {
+		return report, nil, err
+	}
+	if err = cursor.All(ctx, &payments); err != nil {
+		return report, nil, err
+	}
+
+	var refunds []models.Refund
+	cursor, err = refundCollection.Find(ctx, bson.M{"created_at": window, "invoice_id": bson.M{"$in": locationInvoiceIds}, "status": bson.M{"$ne": "FAILED"}})
+	if err != nil {
+		return report, nil, err
+	}
+	if err = cursor.All(ctx, &refunds); err != nil {
+		return report, nil, err
+	}
+
+	var shifts []models.Shift
+	cursor, err = shiftCollection.Find(ctx, bson.M{"status": "CLOSED", "closed_at": window, "location": locationFilter})
+	if err != nil {
+		return report, nil, err
+	}
+	if err = cursor.All(ctx, &shifts); err != nil {
+		return report, nil, err
+	}
+
+	report = SummarizeDay(invoices, payments, refunds, shifts)
+	report.Business_date = date
+	report.Location = location
+
+	invoiceIds := []string{}
+	for _, invoice := range invoices {
+		invoiceIds = append(invoiceIds, invoice.Invoice_id)
+	}
+	return report, invoiceIds, nil
+}
+
+// SummarizeDay adds up the day's documents into a Z report. Voided invoices
+// are only counted, and unpaid ones are also counted in Open_count. Line
+// items of evenly split invoices are reduced to the shares the invoice
+// covers so categories are not counted once per payer.
+func SummarizeDay(invoices []models.Invoice, payments []models.Payment, refunds []models.Refund, shifts []models.Shift) models.ZReport {
+	zero := models.Money{}
+	report := models.ZReport{
+		Gross_sales:     zero,
+		Discounts:       zero,
+		Service_charges: zero,
+		Tax_total:       zero,
+		Tips:            zero,
+		Refunds:         zero,
+	}
+
+	tenders := map[string]*models.ZReportTender{}
+	tender := func(name string) *models.ZReportTender {
+		if tenders[name] == nil {
+			tenders[name] = &models.ZReportTender{Tender: name, Amount: zero, Refunded: zero}
+		}
+		return tenders[name]
+	}
+	taxes := map[string]*models.ZReportTax{}
+	categories := map[string]*models.ZReportCategory{}
+
+	add := func(total *models.Money, m *models.Money) {
+		if m != nil {
+			*total = total.Add(*m)
+		}
+	}
+
+	for _, invoice := range invoices {
+		if invoice.Payment_status != nil && *invoice.Payment_status == "VOID" {
+			report.Void_count++
+			continue
+		}
+		report.Invoice_count++
+		if invoice.Payment_status != nil && (*invoice.Payment_status == "PENDING" || *invoice.Payment_status == "PARTIALLY_PAID") {
+			report.Open_count++
+		}
+		add(&report.Gross_sales, invoice.Subtotal)
+		add(&report.Discounts, invoice.Discount_total)
+		add(&report.Service_charges, invoice.Service_charge)
+		add(&report.Tax_total, invoice.Tax_total)
+		add(&report.Tips, invoice.Tip)
+
+		for _, tax := range invoice.Taxes {
+			if taxes[tax.Tax_rate_id] == nil {
+				taxes[tax.Tax_rate_id] = &models.ZReportTax{Tax_rate_id: tax.Tax_rate_id, Name: tax.Name, Taxable: zero, Amount: zero}
+			}
+			taxes[tax.Tax_rate_id].Taxable = taxes[tax.Tax_rate_id].Taxable.Add(tax.Taxable)
+			taxes[tax.Tax_rate_id].Amount = taxes[tax.Tax_rate_id].Amount.Add(tax.Amount)
+		}
+
+		for _, line := range invoice.Line_items {
+			if categories[line.Category] == nil {
+				categories[line.Category] = &models.ZReportCategory{Category: line.Category, Amount: zero}
+			}
+			amount, quantity := splitLineShare(invoice, line)
+			categories[line.Category].Quantity += quantity
+			categories[line.Category].Amount = categories[line.Category].Amount.Add(amount)
+		}
+	}
+
+	for _, payment := range payments {
+		t := tender(*payment.Tender)
+		t.Count++
+		t.Amount = t.Amount.Add(*payment.Amount)
+	}
+	for _, refund := range refunds {
+		report.Refunds = report.Refunds.Add(*refund.Amount)
+		name := "UNKNOWN"
+		if refund.Tender != nil {
+			name = *refund.Tender
+		}
+		t := tender(name)
+		t.Refunded = t.Refunded.Add(*refund.Amount)
+	}
+
+	report.Net_sales = report.Gross_sales.Sub(report.Discounts).Sub(report.Refunds)
+
+	for _, t := range tenders {
+		report.Tenders = append(report.Tenders, *t)
+	}
+	sort.Slice(report.Tenders, func(i, j int) bool { return report.Tenders[i].Tender < report.Tenders[j].Tender })
+	for _, t := range taxes {
+		report.Taxes = append(report.Taxes, *t)
+	}
+	sort.Slice(report.Taxes, func(i, j int) bool { return report.Taxes[i].Name < report.Taxes[j].Name })
+	for _, c := range categories {
+		report.Categories = append(report.Categories, *c)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool { return report.Categories[i].Category < report.Categories[j].Category })
+
+	for _, shift := range shifts {
+		entry := models.ZReportShift{Shift_id: shift.Shift_id}
+		if shift.Drawer != nil {
+			entry.Drawer = *shift.Drawer
+		}
+		if shift.Expected_cash != nil {
+			entry.Expected_cash = *shift.Expected_cash
+		}
+		if shift.Counted_cash != nil {
+			entry.Counted_cash = *shift.Counted_cash
+		}
+		if shift.Variance != nil {
+			entry.Variance = *shift.Variance
+		}
+		report.Shifts = append(report.Shifts, entry)
+	}
+	return report
+}
+
+func splitLineShare(invoice models.Invoice, line models.InvoiceLine) (models.Money, int64) {
+	if invoice.Split_count <= 1 || len(invoice.Split_shares) == 0 {
+		return line.Line_total, line.Quantity
+	}
+	parts := line.Line_total.Allocate(invoice.Split_count)
+	amount := models.NewMoney(0, line.Line_total.Currency)
+	var quantity int64
+	for _, index := range invoice.Split_shares {
+		if index >= 0 && index < len(parts) {
+			amount = amount.Add(parts[index])
+		}
+		if index == 0 {
+			quantity = line.Quantity
+		}
+	}
+	return amount, quantity
+}
+
+func locationKey(location *string) string {
+	if location == nil {
+		return ""
+	}
+	return *location
+}
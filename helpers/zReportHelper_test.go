@@ -0,0 +1,31 @@
+package helpers
+
+import (
+	"golang-restaurant-management/models"
+	"testing"
+)
+
+func TestSummarizeDayInOtherCurrency(t *testing.T) {
+	subtotal := models.NewMoney(5000, "EUR")
+	tax := models.NewMoney(950, "EUR")
+	pending := "PARTIALLY_PAID"
+	tender := "CARD"
+	paid := models.NewMoney(5950, "EUR")
+	refunded := models.NewMoney(500, "EUR")
+
+	report := SummarizeDay(
+		[]models.Invoice{{Subtotal: &subtotal, Tax_total: &tax, Payment_status: &pending}},
+		[]models.Payment{{Tender: &tender, Amount: &paid}},
+		[]models.Refund{{Tender: &tender, Amount: &refunded}},
+		nil,
+	)
+	if report.Gross_sales != subtotal || report.Tax_total != tax {
+		t.Fatalf("gross %v tax %v", report.Gross_sales, report.Tax_total)
+	}
+	if report.Open_count != 1 {
+		t.Fatalf("open count %d, want 1", report.Open_count)
+	}
+	if want := models.NewMoney(4500, "EUR"); report.Net_sales != want {
+		t.Fatalf("net sales %v, want %v", report.Net_sales, want)
+	}
+}
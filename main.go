@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"golang-restaurant-management/database"
+	"golang-restaurant-management/helpers"
 	middleware "golang-restaurant-management/middleware"
 	routes "golang-restaurant-management/routes"
 
@@ -21,8 +23,21 @@ func main() {
 		port = "8000"
 	}
 
+	helpers.EnsureIndexes()
+	helpers.SeedAdmin()
+	helpers.MigrateMoneyFields()
+	helpers.MigrateOrderItemQuantities()
+	helpers.MigrateTaxRateCategories()
+	go helpers.RunPrintQueue(context.Background())
+	go helpers.RunGiftCardExpiry(context.Background())
+	go helpers.RunLoyaltyExpiry(context.Background())
+
 	router := gin.New()
 	router.Use(gin.Logger())
+	router.Use(gin.Recovery())
+	routes.WebhookRoutes(router)
+	routes.LoginRoutes(router)
+	routes.KitchenDisplayRoutes(router)
 	router.Use(middleware.Authentication())
 	routes.UserRoutes(router)
 
@@ -32,6 +47,17 @@ func main() {
 	routes.InvoiceRoutes(router)
 	routes.OrderRoutes(router)
 	routes.OrderItemRoutes(router)
+	routes.TaxRateRoutes(router)
+	routes.SettingRoutes(router)
+	routes.PrinterRoutes(router)
+	routes.ShiftRoutes(router)
+	routes.ReportRoutes(router)
+	routes.PromotionRoutes(router)
+	routes.GiftCardRoutes(router)
+	routes.CustomerRoutes(router)
+	routes.StationRoutes(router)
+	routes.KitchenRoutes(router)
+	routes.NoteRoutes(router)
 
 	router.Run(":" + port)
 
@@ -16,17 +16,40 @@ func Authentication() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		claims, err := helpers.ValidateAllToken(clientToken)
-		if err != "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		authenticate(c, clientToken)
+	}
+}
+
+// DisplayAuthentication is Authentication for the kitchen display streams.
+// Browsers cannot set headers on EventSource or WebSocket requests, so the
+// token may also come as a token query parameter. Only mount it on those
+// streams: query strings end up in access logs.
+func DisplayAuthentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientToken := c.Request.Header.Get("token")
+		if clientToken == "" {
+			clientToken = c.Query("token")
+		}
+		if clientToken == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "no token header or query parameter provided"})
 			c.Abort()
+			return
 		}
-		c.Set("email", claims.Email)
-		c.Set("first_name", claims.First_name)
-		c.Set("last_name", claims.Last_name)
-		c.Set("user_id", claims.User_id)
-
-		c.Next()
+		authenticate(c, clientToken)
+	}
+}
 
+func authenticate(c *gin.Context, clientToken string) {
+	claims, err := helpers.ValidateAllToken(clientToken)
+	if err != "" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+		c.Abort()
+		return
 	}
+	c.Set("email", claims.Email)
+	c.Set("first_name", claims.First_name)
+	c.Set("last_name", claims.Last_name)
+	c.Set("user_id", claims.User_id)
+
+	c.Next()
 }
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Customer struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	First_name         *string            `json:"first_name" validate:"required,min=2,max=100"`
+	Last_name          *string            `json:"last_name" validate:"omitempty,max=100"`
+	Email              *string            `json:"email" validate:"omitempty,email"`
+	Phone              *string            `json:"phone" validate:"omitempty,min=6,max=20"`
+	Birthday           *string            `json:"birthday" validate:"omitempty,datetime=2006-01-02"`
+	Preferences        []string           `json:"preferences"`
+	Allergies          []string           `json:"allergies"`
+	Marketing_consent  *bool              `json:"marketing_consent"`
+	Consent_updated_at *time.Time         `json:"consent_updated_at"`
+	Visit_count        int64              `json:"visit_count"`
+	Lifetime_spend     *Money             `json:"lifetime_spend"`
+	Last_visit_at      *time.Time         `json:"last_visit_at"`
+	Points_balance     int64              `json:"points_balance"`
+	Lifetime_points    int64              `json:"lifetime_points"`
+	Tier               *string            `json:"tier"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	Customer_id        string             `json:"customer_id"`
+}
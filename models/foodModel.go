@@ -7,12 +7,26 @@ import (
 )
 
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required, min=2, max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Menu_id    *string            `json:"menu_id"`
-	Food_id    string             `json:"food_id"`
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required, min=2, max=100"`
+	Price           *Money             `json:"price" validate:"required"`
+	Food_image      *string            `json:"food_image" validate:"required"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Menu_id         *string            `json:"menu_id"`
+	Tax_category    *string            `json:"tax_category"`
+	Station_id      *string            `json:"station_id"`
+	Modifier_groups []ModifierGroup    `json:"modifier_groups" validate:"omitempty,dive"`
+	Variants        []FoodVariant      `json:"variants" validate:"omitempty,dive"`
+	Food_id         string             `json:"food_id"`
+}
+
+// FoodVariant is a portion or size of a food with its own price and SKU.
+// Size is the short code servers pick it by, such as S, M or L.
+type FoodVariant struct {
+	Variant_id string  `json:"variant_id"`
+	Name       *string `json:"name" validate:"required,min=1,max=100"`
+	Size       *string `json:"size"`
+	Sku        *string `json:"sku"`
+	Price      *Money  `json:"price" validate:"required"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type GiftCard struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Code            string             `json:"code"`
+	Initial_balance *Money             `json:"initial_balance" validate:"required"`
+	Balance         *Money             `json:"balance"`
+	Status          *string            `json:"status" validate:"omitempty,eq=ACTIVE|eq=DISABLED|eq=EXPIRED"`
+	Expires_at      *time.Time         `json:"expires_at"`
+	Manager_token   *string            `json:"manager_token" bson:"-"`
+	Issued_by       string             `json:"issued_by"`
+	Approved_by     string             `json:"approved_by"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Gift_card_id    string             `json:"gift_card_id"`
+}
+
+type GiftCardTransaction struct {
+	ID                       primitive.ObjectID `bson:"_id"`
+	Gift_card_id             string             `json:"gift_card_id"`
+	Type                     string             `json:"type"`
+	Amount                   Money              `json:"amount"`
+	Balance_after            Money              `json:"balance_after"`
+	Invoice_id               *string            `json:"invoice_id"`
+	Payment_id               *string            `json:"payment_id"`
+	Created_by               string             `json:"created_by"`
+	Created_at               time.Time          `json:"created_at"`
+	Gift_card_transaction_id string             `json:"gift_card_transaction_id"`
+}
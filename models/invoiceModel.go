@@ -7,12 +7,82 @@ import (
 )
 
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_id       string             `json:"invoice_id"`
-	Order_id         *string            `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"required, eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required, eq=PENDING|eq=PAID"`
-	Payment_due_date time.Time          `json:"payment_due_date" validate:"required"`
-	Created_at       time.Time          `json:"created_at"`
-	Updated_at       time.Time          `json:"updated_at"`
+	ID               primitive.ObjectID  `bson:"_id"`
+	Invoice_id       string              `json:"invoice_id"`
+	Invoice_number   *string             `json:"invoice_number"`
+	Fiscal_year      int                 `json:"fiscal_year"`
+	Sequence         int64               `json:"sequence"`
+	Order_id         *string             `json:"order_id"`
+	Payment_method   *string             `json:"payment_method" validate:"omitempty,eq=CARD|eq=CASH"`
+	Payment_status   *string             `json:"payment_status" validate:"omitempty,eq=PENDING|eq=PARTIALLY_PAID|eq=PAID|eq=REFUNDED|eq=VOID"`
+	Payment_due_date time.Time           `json:"payment_due_date"`
+	Location         *string             `json:"location"`
+	Customer_id      *string             `json:"customer_id"`
+	Order_item_ids   []string            `json:"order_item_ids"`
+	Split_group_id   *string             `json:"split_group_id"`
+	Split_count      int                 `json:"split_count"`
+	Split_shares     []int               `json:"split_shares"`
+	Discount_bps     *int64              `json:"discount_bps" validate:"omitempty,min=0,max=10000"`
+	Discount_amount  *Money              `json:"discount_amount"`
+	Tip              *Money              `json:"tip"`
+	Coupon_codes     []string            `json:"coupon_codes"`
+	Promotions       []AppliedPromotion  `json:"promotions"`
+	Points_redeemed  int64               `json:"points_redeemed"`
+	Points_discount  *Money              `json:"points_discount"`
+	Reward_item_ids  []string            `json:"reward_item_ids"`
+	Points_earned    *int64              `json:"points_earned"`
+	Loyalty_reversed bool                `json:"loyalty_reversed"`
+	Spend_recorded   *Money              `json:"-"`
+	Line_items       []InvoiceLine       `json:"line_items"`
+	Taxes            []InvoiceTax        `json:"taxes"`
+	Subtotal         *Money              `json:"subtotal"`
+	Discount_total   *Money              `json:"discount_total"`
+	Service_charge   *Money              `json:"service_charge"`
+	Tax_total        *Money              `json:"tax_total"`
+	Total            *Money              `json:"total"`
+	Amount_paid      *Money              `json:"amount_paid"`
+	Balance_due      *Money              `json:"balance_due"`
+	Amount_refunded  *Money              `json:"amount_refunded"`
+	Adjustments      []InvoiceAdjustment `json:"adjustments"`
+	Void_reason      *string             `json:"void_reason"`
+	Voided_by        *string             `json:"voided_by"`
+	Z_report_id      *string             `json:"z_report_id"`
+	Created_at       time.Time           `json:"created_at"`
+	Updated_at       time.Time           `json:"updated_at"`
+}
+
+type InvoiceLine struct {
+	Order_item_id string             `json:"order_item_id"`
+	Food_id       string             `json:"food_id"`
+	Name          string             `json:"name"`
+	Variant_name  string             `json:"variant_name"`
+	Sku           string             `json:"sku"`
+	Category      string             `json:"category"`
+	Tax_category  string             `json:"tax_category"`
+	Quantity      int64              `json:"quantity"`
+	Unit_price    Money              `json:"unit_price"`
+	Modifiers     []SelectedModifier `json:"modifiers"`
+	Line_total    Money              `json:"line_total"`
+	Discount      Money              `json:"discount"`
+	Ordered_at    time.Time          `json:"ordered_at"`
+}
+
+type InvoiceTax struct {
+	Tax_rate_id string `json:"tax_rate_id"`
+	Name        string `json:"name"`
+	Location    string `json:"location"`
+	Rate_bps    int64  `json:"rate_bps"`
+	Inclusive   bool   `json:"inclusive"`
+	Compound    bool   `json:"compound"`
+	Taxable     Money  `json:"taxable"`
+	Amount      Money  `json:"amount"`
+}
+
+type InvoiceAdjustment struct {
+	Type        string    `json:"type"`
+	Reason_code string    `json:"reason_code"`
+	Amount      Money     `json:"amount"`
+	Refund_id   string    `json:"refund_id"`
+	Approved_by string    `json:"approved_by"`
+	Created_at  time.Time `json:"created_at"`
 }
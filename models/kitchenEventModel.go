@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KitchenEvent is one change pushed to kitchen displays. Event_id increases
+// with every event so a display can resume from the last one it saw.
+// Events without a station are shown on every screen.
+type KitchenEvent struct {
+	ID            primitive.ObjectID `bson:"_id" json:"-"`
+	Event_id      int64              `json:"event_id"`
+	Type          string             `json:"type"`
+	Order_id      string             `json:"order_id"`
+	Order_item_id *string            `json:"order_item_id,omitempty"`
+	Food_id       *string            `json:"food_id,omitempty"`
+	Station       *string            `json:"station,omitempty"`
+	Status        string             `json:"status"`
+	Created_at    time.Time          `json:"created_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoyaltyProgram struct {
+	Points_per_unit *int64          `json:"points_per_unit" validate:"omitempty,min=0"`
+	Point_value     *Money          `json:"point_value"`
+	Expiry_days     *int            `json:"expiry_days" validate:"omitempty,min=1"`
+	Bonus_items     []LoyaltyBonus  `json:"bonus_items"`
+	Rewards         []LoyaltyReward `json:"rewards"`
+	Tiers           []LoyaltyTier   `json:"tiers"`
+}
+
+type LoyaltyBonus struct {
+	Food_id string `json:"food_id"`
+	Points  int64  `json:"points"`
+}
+
+type LoyaltyReward struct {
+	Food_id string `json:"food_id"`
+	Points  int64  `json:"points"`
+}
+
+type LoyaltyTier struct {
+	Name           string `json:"name"`
+	Min_points     int64  `json:"min_points"`
+	Multiplier_bps int64  `json:"multiplier_bps"`
+}
+
+type LoyaltyTransaction struct {
+	ID                     primitive.ObjectID `bson:"_id"`
+	Customer_id            string             `json:"customer_id"`
+	Type                   string             `json:"type"`
+	Points                 int64              `json:"points"`
+	Remaining              int64              `json:"remaining"`
+	Expires_at             *time.Time         `json:"expires_at"`
+	Invoice_id             *string            `json:"invoice_id"`
+	Note                   string             `json:"note"`
+	Created_at             time.Time          `json:"created_at"`
+	Loyalty_transaction_id string             `json:"loyalty_transaction_id"`
+}
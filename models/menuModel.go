@@ -10,6 +10,7 @@ type Menu struct {
 	ID         primitive.ObjectID `bson:"_id"`
 	Name       string             `json:"name" validate:"required, min=2, max=100"`
 	Category   string             `json:"category"`
+	Station_id *string            `json:"station_id"`
 	Start_Date *time.Time         `json:"start_date"`
 	End_Date   *time.Time         `json:"end_date"`
 	Created_at time.Time          `json:"created_at"`
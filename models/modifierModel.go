@@ -0,0 +1,31 @@
+package models
+
+// ModifierGroup is a choice offered with a food, such as "Cooking" or
+// "Extras". A required group needs at least one option; Min_select and
+// Max_select bound how many options can be picked, with 0 meaning no limit
+// for Max_select.
+type ModifierGroup struct {
+	Modifier_group_id string           `json:"modifier_group_id"`
+	Name              *string          `json:"name" validate:"required,min=1,max=100"`
+	Required          bool             `json:"required"`
+	Min_select        int              `json:"min_select" validate:"min=0"`
+	Max_select        int              `json:"max_select" validate:"min=0"`
+	Options           []ModifierOption `json:"options" validate:"required,min=1,dive"`
+}
+
+type ModifierOption struct {
+	Modifier_option_id string  `json:"modifier_option_id"`
+	Name               *string `json:"name" validate:"required,min=1,max=100"`
+	Price_delta        Money   `json:"price_delta"`
+}
+
+// SelectedModifier is an option chosen for an order item. Names and the
+// price delta are copied from the food when the item is ordered so later
+// menu changes do not alter what was sold.
+type SelectedModifier struct {
+	Modifier_group_id  string `json:"modifier_group_id" validate:"required"`
+	Modifier_option_id string `json:"modifier_option_id" validate:"required"`
+	Group_name         string `json:"group_name"`
+	Name               string `json:"name"`
+	Price_delta        Money  `json:"price_delta"`
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "USD"
+
+const MinorUnits = 2
+
+// ErrCurrencyMismatch is the panic value of arithmetic between amounts in
+// different currencies. Amounts without a currency, such as the zero
+// Money{} used to start a sum, take the other side's.
+var ErrCurrencyMismatch = errors.New("money: currency mismatch")
+
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// NewMoney builds an amount from outside input, defaulting a missing
+// currency. Start sums from Money{} instead so they adopt the currency of
+// what is added to them.
+func NewMoney(amount int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Add and Sub panic with ErrCurrencyMismatch rather than mixing currencies.
+func (m Money) Add(other Money) Money {
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currencyWith(other)}
+}
+
+func (m Money) Sub(other Money) Money {
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currencyWith(other)}
+}
+
+func (m Money) Mul(quantity int64) Money {
+	return Money{Amount: m.Amount * quantity, Currency: m.Currency}
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Percent applies a rate expressed in basis points (1/100 of a percent),
+// rounding half away from zero to the nearest minor unit.
+func (m Money) Percent(basisPoints int64) Money {
+	return m.Scale(basisPoints, 10000)
+}
+
+// Scale returns m * numerator / denominator rounded half away from zero.
+func (m Money) Scale(numerator int64, denominator int64) Money {
+	if denominator == 0 {
+		return Money{Currency: m.Currency}
+	}
+	return Money{Amount: divRound(m.Amount*numerator, denominator), Currency: m.Currency}
+}
+
+// Allocate splits m into n parts whose sum is exactly m. Remainder minor
+// units go to the first parts so the result is deterministic.
+func (m Money) Allocate(n int) []Money {
+	if n <= 0 {
+		return nil
+	}
+	parts := make([]Money, n)
+	share := m.Amount / int64(n)
+	remainder := m.Amount % int64(n)
+	step := int64(1)
+	if remainder < 0 {
+		remainder = -remainder
+		step = -1
+	}
+	for i := range parts {
+		parts[i] = Money{Amount: share, Currency: m.Currency}
+		if int64(i) < remainder {
+			parts[i].Amount += step
+		}
+	}
+	return parts
+}
+
+// AllocateBy splits m in proportion to weights so the parts sum exactly to
+// m. Parts are rounded toward zero and the remaining minor units go to the
+// first parts with a positive weight. When no weight is positive m is split
+// evenly instead.
+func (m Money) AllocateBy(weights []int64) []Money {
+	var total int64
+	for _, weight := range weights {
+		if weight > 0 {
+			total += weight
+		}
+	}
+	if total == 0 {
+		return m.Allocate(len(weights))
+	}
+
+	parts := make([]Money, len(weights))
+	remainder := m.Amount
+	for i, weight := range weights {
+		parts[i] = Money{Currency: m.Currency}
+		if weight > 0 {
+			parts[i].Amount = m.Amount * weight / total
+			remainder -= parts[i].Amount
+		}
+	}
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if weights[i] > 0 {
+			parts[i].Amount += step
+			remainder -= step
+		}
+	}
+	return parts
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// Decimal formats the amount without its currency, e.g. "-12.50".
+func (m Money) Decimal() string {
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return sign + strconv.FormatInt(amount/minorUnitFactor, 10) + "." + leftPad(strconv.FormatInt(amount%minorUnitFactor, 10), MinorUnits)
+}
+
+// SameCurrency reports whether m and other can be added together.
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == "" || other.Currency == "" || m.Currency == other.Currency
+}
+
+func (m Money) currencyWith(other Money) string {
+	if !m.SameCurrency(other) {
+		panic(ErrCurrencyMismatch)
+	}
+	if m.Currency == "" {
+		return other.Currency
+	}
+	return m.Currency
+}
+
+// UnmarshalJSON accepts either {"amount": 1250, "currency": "USD"} or a
+// plain decimal such as 12.50, which is parsed exactly into minor units.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "null" {
+		return nil
+	}
+	if strings.HasPrefix(trimmed, "{") {
+		type plain Money
+		var p plain
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		*m = NewMoney(p.Amount, p.Currency)
+		return nil
+	}
+	amount, err := ParseMinorUnits(strings.Trim(trimmed, `"`))
+	if err != nil {
+		return err
+	}
+	*m = NewMoney(amount, DefaultCurrency)
+	return nil
+}
+
+// ParseMinorUnits converts a decimal string into integer minor units
+// without going through float64.
+func ParseMinorUnits(s string) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, errors.New("invalid money amount")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, errors.New("invalid money amount")
+		}
+	}
+	roundUp := false
+	if len(frac) > MinorUnits {
+		roundUp = frac[MinorUnits] >= '5'
+		frac = frac[:MinorUnits]
+	}
+	for len(frac) < MinorUnits {
+		frac += "0"
+	}
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid money amount")
+	}
+	if roundUp {
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+// minorUnitFactor is the number of minor units in one major unit.
+var minorUnitFactor = func() int64 {
+	factor := int64(1)
+	for i := 0; i < MinorUnits; i++ {
+		factor *= 10
+	}
+	return factor
+}()
+
+func divRound(numerator int64, denominator int64) int64 {
+	negative := (numerator < 0) != (denominator < 0)
+	if numerator < 0 {
+		numerator = -numerator
+	}
+	if denominator < 0 {
+		denominator = -denominator
+	}
+	quotient := numerator / denominator
+	if (numerator%denominator)*2 >= denominator {
+		quotient++
+	}
+	if negative {
+		return -quotient
+	}
+	return quotient
+}
+
+func leftPad(s string, width int) string {
+	for len(s) < width {
+		s = "0" + s
+	}
+	return s
+}
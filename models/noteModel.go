@@ -6,11 +6,19 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Note is free text attached to an order, order item, table, customer or
+// invoice. KITCHEN notes are printed on kitchen tickets and shown on
+// kitchen screens; INTERNAL notes stay with front-of-house staff.
 type Note struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Text       *string            `json:"text"`
-	Title      *string            `json:"title"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Note_id    *string            `json:"note_id"`
+	ID          primitive.ObjectID `bson:"_id"`
+	Text        *string            `json:"text" validate:"required,min=1,max=1000"`
+	Title       *string            `json:"title" validate:"omitempty,max=100"`
+	Entity_type *string            `json:"entity_type" validate:"required,eq=ORDER|eq=ORDER_ITEM|eq=TABLE|eq=CUSTOMER|eq=INVOICE"`
+	Entity_id   *string            `json:"entity_id" validate:"required"`
+	Visibility  *string            `json:"visibility" validate:"omitempty,eq=KITCHEN|eq=INTERNAL"`
+	Author_id   string             `json:"author_id"`
+	Author_name string             `json:"author_name"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Note_id     *string            `json:"note_id"`
 }
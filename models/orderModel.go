@@ -7,10 +7,44 @@ import (
 )
 
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID  `bson:"_id"`
+	Order_date     time.Time           `json:"order_date" validate:"required"`
+	Created_at     time.Time           `json:"created_at"`
+	Updated_at     time.Time           `json:"updated_at"`
+	Order_id       string              `json:"order_id"`
+	Table_id       *string             `json:"table_id" validate:"required"`
+	Order_type     *string             `json:"order_type" validate:"omitempty,eq=DINE_IN|eq=TAKEAWAY"`
+	Customer_id    *string             `json:"customer_id"`
+	Server_id      *string             `json:"server_id"`
+	Status         *string             `json:"status" validate:"omitempty,eq=OPEN|eq=SENT_TO_KITCHEN|eq=IN_PROGRESS|eq=READY|eq=SERVED|eq=CLOSED|eq=CANCELLED|eq=VOID|eq=MERGED"`
+	Status_history []OrderStatusChange `json:"status_history"`
+	History        []OrderChange       `json:"history"`
+	Fired_courses  []CourseFire        `json:"fired_courses"`
+}
+
+type CourseFire struct {
+	Course   int       `json:"course"`
+	Fired_by string    `json:"fired_by"`
+	Fired_at time.Time `json:"fired_at"`
+}
+
+type OrderStatusChange struct {
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Reason     string    `json:"reason"`
+	Changed_by string    `json:"changed_by"`
+	Changed_at time.Time `json:"changed_at"`
+}
+
+// OrderChange records a change to where an order sits or who looks after
+// it: a move to another table, a merge, items moved in or out, or a new
+// server. From and To hold the table, order or server ids involved.
+type OrderChange struct {
+	Action         string    `json:"action"`
+	From           string    `json:"from"`
+	To             string    `json:"to"`
+	Order_item_ids []string  `json:"order_item_ids"`
+	Reason         string    `json:"reason"`
+	Changed_by     string    `json:"changed_by"`
+	Changed_at     time.Time `json:"changed_at"`
 }
@@ -8,11 +8,88 @@ import (
 
 type OrderItem struct {
 	ID            primitive.ObjectID `bson:"_id"`
-	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
-	Unit_price    *float64           `json:"unit_price" validate:"required"`
+	Quantity      *int64             `json:"quantity" validate:"omitempty,min=1,max=999"`
+	Unit_price    *Money             `json:"unit_price"`
+	Price_set_by  *string            `json:"price_set_by"`
+	Manager_token *string            `json:"manager_token" bson:"-"`
+	Manager_id    *string            `json:"manager_id" bson:"-"`
+	Manager_pin   *string            `json:"manager_pin" bson:"-"`
 	Food_id       *string            `json:"food_id" validate:"required"`
+	Variant_id    *string            `json:"variant_id"`
+	Size          *string            `json:"size"`
+	Variant_name  *string            `json:"variant_name"`
+	Sku           *string            `json:"sku"`
+	Seat_number   *int               `json:"seat_number" validate:"omitempty,min=1"`
 	Created_at    time.Time          `json:"created_at"`
 	Updated_at    time.Time          `json:"updated_at"`
 	Order_item_id string             `json:"order_item_id"`
 	Order_id      *string            `json:"order_id" validate:"required"`
+	Modifiers     []SelectedModifier `json:"modifiers" validate:"omitempty,dive"`
+	Station_id    *string            `json:"station_id"`
+	Priority      *int               `json:"priority" validate:"omitempty,min=0,max=10"`
+	Course        *int               `json:"course" validate:"omitempty,min=1,max=9"`
+	Hold          *bool              `json:"hold"`
+	Released_at   *time.Time         `json:"released_at"`
+	Status        *string            `json:"status" validate:"omitempty,eq=QUEUED|eq=FIRING|eq=READY|eq=DELIVERED|eq=VOIDED"`
+	Queued_at     *time.Time         `json:"queued_at"`
+	Fired_at      *time.Time         `json:"fired_at"`
+	Ready_at      *time.Time         `json:"ready_at"`
+	Delivered_at  *time.Time         `json:"delivered_at"`
+	Voided_at     *time.Time         `json:"voided_at"`
+	Adjustment    *ItemAdjustment    `json:"adjustment"`
+}
+
+// ItemAdjustment records why an item was voided before the kitchen started
+// on it or comped after, who asked for it and which manager approved it.
+// Amount is what the item would have been billed at.
+type ItemAdjustment struct {
+	Kind         string    `json:"kind"`
+	Reason_code  string    `json:"reason_code"`
+	Reason_label string    `json:"reason_label"`
+	Comment      string    `json:"comment"`
+	Amount       Money     `json:"amount"`
+	Requested_by string    `json:"requested_by"`
+	Approved_by  string    `json:"approved_by"`
+	Created_at   time.Time `json:"created_at"`
+}
+
+// DishTicketTime is how long the kitchen takes over one dish: from firing
+// to ready on the pass, and from being queued to reaching the table.
+type DishTicketTime struct {
+	Food_id           string  `json:"food_id"`
+	Name              string  `json:"name"`
+	Count             int     `json:"count"`
+	Avg_prep_seconds  float64 `json:"avg_prep_seconds"`
+	Max_prep_seconds  float64 `json:"max_prep_seconds"`
+	Avg_total_seconds float64 `json:"avg_total_seconds"`
+}
+
+// VoidCompReport lists the voids and comps over a period for loss
+// prevention, totalled by reason and by the staff who asked for them.
+type VoidCompReport struct {
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Voids       AdjustmentTotal       `json:"voids"`
+	Comps       AdjustmentTotal       `json:"comps"`
+	By_reason   []AdjustmentTotal     `json:"by_reason"`
+	By_staff    []AdjustmentTotal     `json:"by_staff"`
+	Adjustments []OrderItemAdjustment `json:"adjustments"`
+}
+
+type AdjustmentTotal struct {
+	Kind         string `json:"kind"`
+	Reason_code  string `json:"reason_code,omitempty"`
+	Reason_label string `json:"reason_label,omitempty"`
+	Requested_by string `json:"requested_by,omitempty"`
+	Count        int    `json:"count"`
+	Amount       Money  `json:"amount"`
+}
+
+type OrderItemAdjustment struct {
+	Order_item_id string         `json:"order_item_id"`
+	Order_id      string         `json:"order_id"`
+	Food_id       string         `json:"food_id"`
+	Name          string         `json:"name"`
+	Quantity      int64          `json:"quantity"`
+	Adjustment    ItemAdjustment `json:"adjustment"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Payment struct {
+	ID                      primitive.ObjectID `bson:"_id"`
+	Invoice_id              string             `json:"invoice_id"`
+	Tender                  *string            `json:"tender" validate:"required,eq=CASH|eq=CARD|eq=GIFT_CARD|eq=VOUCHER"`
+	Amount                  *Money             `json:"amount" validate:"required"`
+	Tendered                *Money             `json:"tendered"`
+	Change_given            *Money             `json:"change_given"`
+	Reference               *string            `json:"reference"`
+	Card_token              *string            `json:"card_token" bson:"-"`
+	Gift_card_code          *string            `json:"gift_card_code" bson:"-"`
+	Gift_card_id            *string            `json:"gift_card_id"`
+	Status                  string             `json:"status"`
+	Refunded_amount         *Money             `json:"refunded_amount"`
+	Void_reason             *string            `json:"void_reason"`
+	Voided_by               *string            `json:"voided_by"`
+	Provider                *string            `json:"provider"`
+	Provider_transaction_id *string            `json:"provider_transaction_id"`
+	Provider_status         *string            `json:"provider_status"`
+	Idempotency_key         *string            `json:"idempotency_key"`
+	Shift_id                *string            `json:"shift_id"`
+	Created_by              string             `json:"created_by"`
+	Created_at              time.Time          `json:"created_at"`
+	Updated_at              time.Time          `json:"updated_at"`
+	Payment_id              string             `json:"payment_id"`
+}
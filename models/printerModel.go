@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Printer struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required,min=2,max=100"`
+	Role       *string            `json:"role" validate:"required,eq=KITCHEN|eq=RECEIPT"`
+	Connection *string            `json:"connection" validate:"required,eq=TCP|eq=FILE"`
+	Address    *string            `json:"address"`
+	File_path  *string            `json:"file_path"`
+	Categories []string           `json:"categories"`
+	Width      *int               `json:"width" validate:"omitempty,min=24,max=64"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Printer_id string             `json:"printer_id"`
+}
+
+type PrintJob struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Printer_id      string             `json:"printer_id"`
+	Kind            string             `json:"kind"`
+	Reference_id    string             `json:"reference_id"`
+	Payload         []byte             `json:"-"`
+	Status          string             `json:"status"`
+	Attempts        int                `json:"attempts"`
+	Last_error      string             `json:"last_error"`
+	Next_attempt_at time.Time          `json:"next_attempt_at"`
+	Claimed_at      *time.Time         `json:"claimed_at"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Print_job_id    string             `json:"print_job_id"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Promotion struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	Name               *string            `json:"name" validate:"required,min=2,max=100"`
+	Type               *string            `json:"type" validate:"required,eq=PERCENT_ITEM|eq=FIXED_ITEM|eq=PERCENT_ORDER|eq=FIXED_ORDER|eq=BUY_X_GET_Y"`
+	Percent_bps        *int64             `json:"percent_bps" validate:"omitempty,min=0,max=10000"`
+	Amount             *Money             `json:"amount"`
+	Buy_quantity       *int64             `json:"buy_quantity" validate:"omitempty,min=1"`
+	Get_quantity       *int64             `json:"get_quantity" validate:"omitempty,min=1"`
+	Food_ids           []string           `json:"food_ids"`
+	Categories         []string           `json:"categories"`
+	Min_subtotal       *Money             `json:"min_subtotal"`
+	Days               []int              `json:"days" validate:"omitempty,dive,min=0,max=6"`
+	Start_time         *string            `json:"start_time"`
+	End_time           *string            `json:"end_time"`
+	Starts_at          *time.Time         `json:"starts_at"`
+	Ends_at            *time.Time         `json:"ends_at"`
+	Coupon_code        *string            `json:"coupon_code" validate:"omitempty,alphanum,min=3,max=30"`
+	Usage_limit        *int64             `json:"usage_limit" validate:"omitempty,min=1"`
+	Usage_count        int64              `json:"usage_count"`
+	Redeemed_order_ids []string           `json:"-"`
+	Stackable          *bool              `json:"stackable"`
+	Priority           *int               `json:"priority"`
+	Active             *bool              `json:"active"`
+	Created_at         time.Time          `json:"created_at"`
+	Updated_at         time.Time          `json:"updated_at"`
+	Promotion_id       string             `json:"promotion_id"`
+}
+
+type AppliedPromotion struct {
+	Promotion_id  string `json:"promotion_id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	Coupon_code   string `json:"coupon_code"`
+	Order_item_id string `json:"order_item_id"`
+	Amount        Money  `json:"amount"`
+}
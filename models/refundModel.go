@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Refund struct {
+	ID                      primitive.ObjectID `bson:"_id"`
+	Invoice_id              string             `json:"invoice_id"`
+	Payment_id              *string            `json:"payment_id" validate:"required"`
+	Amount                  *Money             `json:"amount" validate:"required"`
+	Reason_code             *string            `json:"reason_code" validate:"required,eq=CUSTOMER_COMPLAINT|eq=OVERCHARGE|eq=WRONG_ITEM|eq=QUALITY|eq=CANCELLED_ORDER|eq=DUPLICATE_PAYMENT|eq=OTHER"`
+	Note                    *string            `json:"note"`
+	Tender                  *string            `json:"tender"`
+	Status                  string             `json:"status"`
+	Shift_id                *string            `json:"shift_id"`
+	Manager_token           *string            `json:"manager_token" bson:"-"`
+	Requested_by            string             `json:"requested_by"`
+	Approved_by             *string            `json:"approved_by"`
+	Provider_transaction_id *string            `json:"provider_transaction_id"`
+	Created_at              time.Time          `json:"created_at"`
+	Updated_at              time.Time          `json:"updated_at"`
+	Refund_id               string             `json:"refund_id"`
+}
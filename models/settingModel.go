@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const DefaultSettingId = "default"
+
+type Setting struct {
+	ID                        primitive.ObjectID `bson:"_id"`
+	Service_charge_bps        *int64             `json:"service_charge_bps" validate:"omitempty,min=0,max=10000"`
+	Service_charge_min_guests *int               `json:"service_charge_min_guests" validate:"omitempty,min=1"`
+	Location                  *string            `json:"location"`
+	Invoice_prefix            *string            `json:"invoice_prefix" validate:"omitempty,alphanum,max=10"`
+	Refund_approval_threshold *Money             `json:"refund_approval_threshold"`
+	Branding                  *Branding          `json:"branding"`
+	Loyalty                   *LoyaltyProgram    `json:"loyalty"`
+	Reason_codes              []ReasonCode       `json:"reason_codes" validate:"omitempty,dive"`
+	Updated_at                time.Time          `json:"updated_at"`
+	Setting_id                string             `json:"setting_id"`
+}
+
+type Branding struct {
+	Restaurant_name string `json:"restaurant_name"`
+	Address         string `json:"address"`
+	Phone           string `json:"phone"`
+	Tax_id          string `json:"tax_id"`
+	Logo_url        string `json:"logo_url"`
+	Accent_color    string `json:"accent_color"`
+	Footer          string `json:"footer"`
+}
+
+// ReasonCode is one of the reasons staff can pick when voiding or comping
+// an item.
+type ReasonCode struct {
+	Code  string `json:"code" validate:"required,max=30"`
+	Label string `json:"label" validate:"required,max=100"`
+	Kind  string `json:"kind" validate:"eq=VOID|eq=COMP"`
+}
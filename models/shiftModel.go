@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Shift struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Drawer         *string            `json:"drawer" validate:"required,min=1,max=50"`
+	Location       *string            `json:"location"`
+	Opening_float  *Money             `json:"opening_float" validate:"required"`
+	Cash_movements []CashMovement     `json:"cash_movements"`
+	Status         string             `json:"status"`
+	Expected_cash  *Money             `json:"expected_cash"`
+	Counted_cash   *Money             `json:"counted_cash"`
+	Variance       *Money             `json:"variance"`
+	Opened_by      string             `json:"opened_by"`
+	Closed_by      *string            `json:"closed_by"`
+	Opened_at      time.Time          `json:"opened_at"`
+	Closed_at      *time.Time         `json:"closed_at"`
+	Shift_id       string             `json:"shift_id"`
+}
+
+type CashMovement struct {
+	Type       string    `json:"type" validate:"required,eq=DROP|eq=PAYOUT"`
+	Amount     *Money    `json:"amount" validate:"required"`
+	Reason     string    `json:"reason"`
+	Created_by string    `json:"created_by"`
+	Created_at time.Time `json:"created_at"`
+}
+
+type ShiftSummary struct {
+	Opening_float Money `json:"opening_float"`
+	Cash_sales    Money `json:"cash_sales"`
+	Cash_refunds  Money `json:"cash_refunds"`
+	Drops         Money `json:"drops"`
+	Payouts       Money `json:"payouts"`
+	Expected_cash Money `json:"expected_cash"`
+}
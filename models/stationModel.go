@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Station is a part of the kitchen with its own screen and printers, such
+// as the grill, fryer, cold section or bar. Items reach a station through
+// their food, their menu, or a menu category listed here.
+type Station struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Categories  []string           `json:"categories"`
+	Printer_ids []string           `json:"printer_ids"`
+	Active      *bool              `json:"active"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Station_id  string             `json:"station_id"`
+}
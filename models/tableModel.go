@@ -10,6 +10,7 @@ type Table struct {
 	ID               primitive.ObjectID `bson:"_id"`
 	Number_of_guests *int               `json:"number_of_guests" validate:"required"`
 	Table_number     *int               `json:"table_number" validate:"required"`
+	Status           *string            `json:"status" validate:"omitempty,eq=AVAILABLE|eq=OCCUPIED"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
 	Table_id         string             `json:"table_id"`
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TaxRate struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Rate_bps       *int64             `json:"rate_bps" validate:"required,min=0,max=10000"`
+	Inclusive      *bool              `json:"inclusive"`
+	Compound       *bool              `json:"compound"`
+	Priority       *int               `json:"priority"`
+	Location       *string            `json:"location"`
+	Order_type     *string            `json:"order_type" validate:"omitempty,eq=DINE_IN|eq=TAKEAWAY"`
+	Tax_categories []string           `json:"tax_categories"`
+	Effective_from *time.Time         `json:"effective_from"`
+	Effective_to   *time.Time         `json:"effective_to"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Tax_rate_id    string             `json:"tax_rate_id"`
+}
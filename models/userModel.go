@@ -14,8 +14,12 @@ type User struct {
 	Password      *string            `json:"password" validate:"required,min=6"`
 	Phone         *string            `json:"phone" validate:"required"`
 	Avatar        *string            `json:"avatar"`
+	Role          *string            `json:"role" validate:"omitempty,eq=ADMIN|eq=MANAGER|eq=STAFF"`
 	Token         *string            `json:"token"`
 	Refresh_token *string            `json:"refresh_token"`
+	Pin           *string            `json:"-"`
+	Pin_failures  int                `json:"-"`
+	Pin_locked_at *time.Time         `json:"-"`
 	Created_at    time.Time          `json:"created_at"`
 	Updated_at    time.Time          `json:"updated_at"`
 	User_id       string             `json:"user_id"`
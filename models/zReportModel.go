@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ZReport struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Business_date   string             `json:"business_date"`
+	Location        string             `json:"location"`
+	Invoice_count   int                `json:"invoice_count"`
+	Void_count      int                `json:"void_count"`
+	Open_count      int                `json:"open_count"`
+	Gross_sales     Money              `json:"gross_sales"`
+	Discounts       Money              `json:"discounts"`
+	Service_charges Money              `json:"service_charges"`
+	Tax_total       Money              `json:"tax_total"`
+	Tips            Money              `json:"tips"`
+	Refunds         Money              `json:"refunds"`
+	Net_sales       Money              `json:"net_sales"`
+	Tenders         []ZReportTender    `json:"tenders"`
+	Taxes           []ZReportTax       `json:"taxes"`
+	Categories      []ZReportCategory  `json:"categories"`
+	Shifts          []ZReportShift     `json:"shifts"`
+	Closed_by       string             `json:"closed_by"`
+	Closed_at       time.Time          `json:"closed_at"`
+	Z_report_id     string             `json:"z_report_id"`
+}
+
+type ZReportTender struct {
+	Tender   string `json:"tender"`
+	Count    int    `json:"count"`
+	Amount   Money  `json:"amount"`
+	Refunded Money  `json:"refunded"`
+}
+
+type ZReportTax struct {
+	Tax_rate_id string `json:"tax_rate_id"`
+	Name        string `json:"name"`
+	Taxable     Money  `json:"taxable"`
+	Amount      Money  `json:"amount"`
+}
+
+type ZReportCategory struct {
+	Category string `json:"category"`
+	Quantity int64  `json:"quantity"`
+	Amount   Money  `json:"amount"`
+}
+
+type ZReportShift struct {
+	Shift_id      string `json:"shift_id"`
+	Drawer        string `json:"drawer"`
+	Expected_cash Money  `json:"expected_cash"`
+	Counted_cash  Money  `json:"counted_cash"`
+	Variance      Money  `json:"variance"`
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/customers", controllers.GetCustomers())
+	incomingRoutes.GET("/customers/:customer_id", controllers.GetCustomer())
+	incomingRoutes.POST("/customers", controllers.CreateCustomer())
+	incomingRoutes.PATCH("/customers/:customer_id", controllers.UpdateCustomer())
+	incomingRoutes.GET("/customers/:customer_id/visits", controllers.GetCustomerVisits())
+	incomingRoutes.GET("/customers/:customer_id/loyalty", controllers.GetCustomerLoyalty())
+	incomingRoutes.POST("/invoices/:invoice_id/loyalty/redeem", controllers.RedeemLoyalty())
+
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GiftCardRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/giftcards", controllers.GetGiftCards())
+	incomingRoutes.GET("/giftcards/:code", controllers.GetGiftCard())
+	incomingRoutes.POST("/giftcards", controllers.IssueGiftCard())
+	incomingRoutes.POST("/giftcards/:code/reload", controllers.ReloadGiftCard())
+	incomingRoutes.PATCH("/giftcards/:code", controllers.UpdateGiftCard())
+
+}
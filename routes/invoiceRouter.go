@@ -10,7 +10,19 @@ func InvoiceRoutes(incomingRoutes *gin.Engine) {
 
 	incomingRoutes.GET("/invoices", controllers.GetInvoices())
 	incomingRoutes.GET("/invoices/:invoice_id", controllers.GetInvoice())
+	incomingRoutes.GET("/invoices/:invoice_id/receipt", controllers.GetInvoiceReceipt())
+	incomingRoutes.GET("/invoices/number/:invoice_number", controllers.GetInvoiceByNumber())
 	incomingRoutes.POST("/invoices", controllers.CreateInvoice())
 	incomingRoutes.PATCH("/invoices/:invoice_id", controllers.UpdateInvoice())
+	incomingRoutes.POST("/invoices/:invoice_id/calculate", controllers.RecalculateInvoice())
+	incomingRoutes.POST("/invoices/split", controllers.SplitInvoice())
+	incomingRoutes.POST("/invoices/merge", controllers.MergeInvoices())
+	incomingRoutes.GET("/invoices/:invoice_id/payments", controllers.GetPayments())
+	incomingRoutes.POST("/invoices/:invoice_id/payments", controllers.CreatePayment())
+	incomingRoutes.POST("/invoices/:invoice_id/payments/:payment_id/void", controllers.VoidPayment())
+	incomingRoutes.GET("/invoices/:invoice_id/refunds", controllers.GetRefunds())
+	incomingRoutes.POST("/invoices/:invoice_id/refunds", controllers.CreateRefund())
+	incomingRoutes.POST("/invoices/:invoice_id/void", controllers.VoidInvoice())
+	incomingRoutes.GET("/refunds", controllers.GetRefunds())
 
 }
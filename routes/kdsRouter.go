@@ -0,0 +1,22 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+	"golang-restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KitchenDisplayRoutes holds the display streams, which authenticate on
+// their own so browser displays can pass the token in the query.
+func KitchenDisplayRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kds/events", middleware.DisplayAuthentication(), controllers.KitchenEventStream())
+	incomingRoutes.GET("/kds/ws", middleware.DisplayAuthentication(), controllers.KitchenEventSocket())
+}
+
+func KitchenRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.POST("/kds/orders/:order_id/bump", controllers.BumpKitchenOrder())
+	incomingRoutes.POST("/kds/orders/:order_id/recall", controllers.RecallKitchenOrder())
+
+}
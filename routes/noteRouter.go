@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NoteRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/notes", controllers.GetNotes())
+	incomingRoutes.GET("/notes/:note_id", controllers.GetNote())
+	incomingRoutes.POST("/notes", controllers.CreateNote())
+	incomingRoutes.PATCH("/notes/:note_id", controllers.UpdateNote())
+	incomingRoutes.DELETE("/notes/:note_id", controllers.DeleteNote())
+
+}
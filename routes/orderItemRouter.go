@@ -13,5 +13,8 @@ func OrderItemRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orderItems-order/:order_id", controllers.GetOrderItemsByOrder())
 	incomingRoutes.POST("/orderItems", controllers.CreateOrderItem())
 	incomingRoutes.PATCH("/orderItems/:orderItem_id", controllers.UpdateOrderItem())
+	incomingRoutes.POST("/orderItems/:orderItem_id/status", controllers.ChangeOrderItemStatus())
+	incomingRoutes.POST("/orderItems/:orderItem_id/void", controllers.VoidOrderItem())
+	incomingRoutes.POST("/orderItems/:orderItem_id/comp", controllers.CompOrderItem())
 
 }
@@ -12,5 +12,11 @@ func OrderRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/orders/:order_id", controllers.GetOrder())
 	incomingRoutes.POST("/orders", controllers.CreateOrder())
 	incomingRoutes.PATCH("/orders/:order_id", controllers.UpdateOrder())
+	incomingRoutes.POST("/orders/:order_id/status", controllers.ChangeOrderStatus())
+	incomingRoutes.POST("/orders/:order_id/courses/:course/fire", controllers.FireCourse())
+	incomingRoutes.POST("/orders/:order_id/table", controllers.MoveOrderTable())
+	incomingRoutes.POST("/orders/:order_id/merge", controllers.MergeOrders())
+	incomingRoutes.POST("/orders/:order_id/items/move", controllers.MoveOrderItemsBetweenOrders())
+	incomingRoutes.POST("/orders/:order_id/server", controllers.ReassignOrderServer())
 
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PrinterRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/printers", controllers.GetPrinters())
+	incomingRoutes.POST("/printers", controllers.CreatePrinter())
+	incomingRoutes.PATCH("/printers/:printer_id", controllers.UpdatePrinter())
+	incomingRoutes.GET("/printjobs", controllers.GetPrintJobs())
+	incomingRoutes.POST("/printjobs/:print_job_id/retry", controllers.RetryPrintJob())
+	incomingRoutes.POST("/orders/:order_id/kitchen-ticket", controllers.PrintKitchenTicket())
+	incomingRoutes.POST("/invoices/:invoice_id/print", controllers.PrintReceipt())
+
+}
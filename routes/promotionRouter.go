@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PromotionRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/promotions", controllers.GetPromotions())
+	incomingRoutes.GET("/promotions/:promotion_id", controllers.GetPromotion())
+	incomingRoutes.POST("/promotions", controllers.CreatePromotion())
+	incomingRoutes.PATCH("/promotions/:promotion_id", controllers.UpdatePromotion())
+	incomingRoutes.GET("/orders/:order_id/promotions", controllers.PreviewPromotions())
+
+}
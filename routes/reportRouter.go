@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReportRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/reports/x", controllers.GetXReport())
+	incomingRoutes.GET("/reports/z", controllers.GetZReports())
+	incomingRoutes.POST("/reports/z", controllers.CloseBusinessDay())
+	incomingRoutes.GET("/reports/ticket-times", controllers.GetTicketTimes())
+	incomingRoutes.GET("/reports/voids", controllers.GetVoidCompReport())
+
+}
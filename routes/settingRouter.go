@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SettingRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/settings", controllers.GetSettings())
+	incomingRoutes.PATCH("/settings", controllers.UpdateSettings())
+	incomingRoutes.GET("/settings/reason-codes", controllers.GetReasonCodes())
+
+}
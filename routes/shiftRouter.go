@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ShiftRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/shifts", controllers.GetShifts())
+	incomingRoutes.GET("/shifts/:shift_id", controllers.GetShift())
+	incomingRoutes.POST("/shifts", controllers.OpenShift())
+	incomingRoutes.POST("/shifts/:shift_id/movements", controllers.AddCashMovement())
+	incomingRoutes.POST("/shifts/:shift_id/close", controllers.CloseShift())
+
+}
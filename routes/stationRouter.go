@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StationRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/stations", controllers.GetStations())
+	incomingRoutes.GET("/stations/:station_id", controllers.GetStation())
+	incomingRoutes.POST("/stations", controllers.CreateStation())
+	incomingRoutes.PATCH("/stations/:station_id", controllers.UpdateStation())
+	incomingRoutes.GET("/stations/:station_id/queue", controllers.GetStationQueue())
+
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRateRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.GET("/taxrates", controllers.GetTaxRates())
+	incomingRoutes.GET("/taxrates/:tax_rate_id", controllers.GetTaxRate())
+	incomingRoutes.POST("/taxrates", controllers.CreateTaxRate())
+	incomingRoutes.PATCH("/taxrates/:tax_rate_id", controllers.UpdateTaxRate())
+
+}
@@ -6,11 +6,16 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// LoginRoutes holds the user routes that are reached without a token.
+func LoginRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/users/login", controllers.Login())
+}
+
 func UserRoutes(incomingRoutes *gin.Engine) {
 
 	incomingRoutes.GET("/users", controllers.GetUsers())
 	incomingRoutes.GET("/users/:user_id", controllers.GetUser())
-	incomingRoutes.POST("/users/login", controllers.Login())
 	incomingRoutes.GET("/users/signup", controllers.Signup())
+	incomingRoutes.POST("/users/:user_id/pin", controllers.SetUserPin())
 
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controllers "golang-restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoutes(incomingRoutes *gin.Engine) {
+
+	incomingRoutes.POST("/payments/webhook", controllers.PaymentWebhook())
+
+}